@@ -0,0 +1,202 @@
+package apperror
+
+import (
+	"Cloud/i18n"
+	"Cloud/logger"
+	"Cloud/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Стабильные коды ошибок, на которые могут опираться клиенты
+const (
+	CodeBadRequest            = "bad_request"
+	CodeNotFound              = "not_found"
+	CodeMethodNotAllowed      = "method_not_allowed"
+	CodeNotAcceptable         = "not_acceptable"
+	CodeInvalidJSON           = "invalid_json"
+	CodeInvalidID             = "invalid_id"
+	CodeValidationFailed      = "validation_failed"
+	CodeUnauthorized          = "unauthorized"
+	CodeInvalidToken          = "invalid_token"
+	CodeTokenExpired          = "token_expired"
+	CodeInvalidCredentials    = "invalid_credentials"
+	CodeUserNotFound          = "user_not_found"
+	CodeUserBanned            = "user_banned"
+	CodeUserDeleted           = "user_deleted"
+	CodeEmailTaken            = "email_taken"
+	CodePhoneTaken            = "phone_taken"
+	CodePreconditionFailed    = "precondition_failed"
+	CodePreconditionRequired  = "precondition_required"
+	CodeUnsupportedMediaType  = "unsupported_media_type"
+	CodePatchTestFailed       = "patch_test_failed"
+	CodeFieldForbidden        = "field_forbidden"
+	CodeConfirmationMissing   = "confirmation_not_found"
+	CodeConfirmationExpired   = "confirmation_expired"
+	CodeConfirmationInvalid   = "confirmation_invalid"
+	CodeEmailSendFailed       = "email_send_failed"
+	CodeForbidden             = "forbidden"
+	CodeOutboxNotFound        = "outbox_message_not_found"
+	CodeOutboxAlreadySent     = "outbox_message_already_sent"
+	CodeEmailSuppressed       = "email_suppressed"
+	CodeSuppressionNotFound   = "suppression_not_found"
+	CodeExportNotFound        = "export_not_found"
+	CodeExportNotReady        = "export_not_ready"
+	CodeExportExpired         = "export_expired"
+	CodeExportLinkInvalid     = "export_link_invalid"
+	CodeLogStorageUnavailable = "log_storage_unavailable"
+	CodeInternal              = "internal_error"
+)
+
+// AppError — ошибка приложения со стабильным кодом и HTTP-статусом.
+// Key — ключ каталога сообщений для клиента, Err — внутренняя причина, которая пишется только в лог.
+type AppError struct {
+	Status int
+	Code   string
+	Key    string
+	Args   []any
+	Err    error
+}
+
+// ErrorBody — содержимое конверта ошибки
+type ErrorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// ErrorResponse — JSON-конверт, в котором возвращаются все ошибки API
+type ErrorResponse struct {
+	Error ErrorBody `json:"error"`
+}
+
+// Типовые ошибки приложения
+var (
+	ErrRouteNotFound         = New(http.StatusNotFound, CodeNotFound)
+	ErrMethodNotAllowed      = New(http.StatusMethodNotAllowed, CodeMethodNotAllowed)
+	ErrNotAcceptable         = New(http.StatusNotAcceptable, CodeNotAcceptable)
+	ErrInvalidJSON           = New(http.StatusBadRequest, CodeInvalidJSON)
+	ErrInvalidID             = New(http.StatusBadRequest, CodeInvalidID)
+	ErrUnauthorized          = New(http.StatusUnauthorized, CodeUnauthorized)
+	ErrInvalidToken          = New(http.StatusUnauthorized, CodeInvalidToken)
+	ErrTokenExpired          = New(http.StatusUnauthorized, CodeTokenExpired)
+	ErrInvalidCredentials    = New(http.StatusUnauthorized, CodeInvalidCredentials)
+	ErrUserNotFound          = New(http.StatusNotFound, CodeUserNotFound)
+	ErrUserBanned            = New(http.StatusForbidden, CodeUserBanned)
+	ErrUserDeleted           = New(http.StatusForbidden, CodeUserDeleted)
+	ErrEmailTaken            = New(http.StatusConflict, CodeEmailTaken)
+	ErrPhoneTaken            = New(http.StatusConflict, CodePhoneTaken)
+	ErrPreconditionFailed    = New(http.StatusPreconditionFailed, CodePreconditionFailed)
+	ErrPreconditionRequired  = New(http.StatusPreconditionRequired, CodePreconditionRequired)
+	ErrUnsupportedMediaType  = New(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType)
+	ErrPatchTestFailed       = New(http.StatusConflict, CodePatchTestFailed)
+	ErrFieldForbidden        = New(http.StatusForbidden, CodeFieldForbidden)
+	ErrConfirmationMissing   = New(http.StatusNotFound, CodeConfirmationMissing)
+	ErrConfirmationExpired   = New(http.StatusGone, CodeConfirmationExpired)
+	ErrConfirmationInvalid   = New(http.StatusUnauthorized, CodeConfirmationInvalid)
+	ErrEmailSendFailed       = New(http.StatusBadGateway, CodeEmailSendFailed)
+	ErrForbidden             = New(http.StatusForbidden, CodeForbidden)
+	ErrOutboxMessageNotFound = New(http.StatusNotFound, CodeOutboxNotFound)
+	ErrOutboxAlreadySent     = New(http.StatusConflict, CodeOutboxAlreadySent)
+	ErrEmailSuppressed       = New(http.StatusUnprocessableEntity, CodeEmailSuppressed)
+	ErrSuppressionNotFound   = New(http.StatusNotFound, CodeSuppressionNotFound)
+	ErrExportNotFound        = New(http.StatusNotFound, CodeExportNotFound)
+	ErrExportNotReady        = New(http.StatusConflict, CodeExportNotReady)
+	ErrExportExpired         = New(http.StatusGone, CodeExportExpired)
+	ErrExportLinkInvalid     = New(http.StatusForbidden, CodeExportLinkInvalid)
+	ErrLogStorageUnavailable = New(http.StatusServiceUnavailable, CodeLogStorageUnavailable)
+	ErrInternal              = New(http.StatusInternalServerError, CodeInternal)
+)
+
+// New создаёт ошибку приложения; сообщение для клиента берётся из каталога по ключу "error.<code>"
+func New(status int, code string) *AppError {
+	return &AppError{Status: status, Code: code, Key: "error." + code}
+}
+
+// Validation создаёт ошибку валидации.
+// Если валидатор вернул i18n.Message, клиент получит его перевод, иначе — общее сообщение.
+func Validation(err error) *AppError {
+	appErr := &AppError{Status: http.StatusBadRequest, Code: CodeValidationFailed, Key: "error." + CodeValidationFailed, Err: err}
+
+	var msg *i18n.Message
+	if errors.As(err, &msg) {
+		appErr.Key = msg.Key
+		appErr.Args = msg.Args
+	}
+	return appErr
+}
+
+// Message возвращает сообщение для клиента на указанном языке
+func (e *AppError) Message(lang string) string {
+	return i18n.T(lang, e.Key, e.Args...)
+}
+
+// Error реализует интерфейс error
+func (e *AppError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Message(i18n.Default), e.Err)
+	}
+	return e.Code + ": " + e.Message(i18n.Default)
+}
+
+// Unwrap возвращает внутреннюю причину ошибки
+func (e *AppError) Unwrap() error {
+	return e.Err
+}
+
+// Is сравнивает ошибки приложения по коду, чтобы errors.Is работал с обёрнутыми копиями
+func (e *AppError) Is(target error) bool {
+	var t *AppError
+	if errors.As(target, &t) {
+		return e.Code == t.Code
+	}
+	return false
+}
+
+// Wrap возвращает копию ошибки с внутренней причиной
+func (e *AppError) Wrap(err error) *AppError {
+	wrapped := *e
+	wrapped.Err = err
+	return &wrapped
+}
+
+// WithArgs возвращает копию ошибки с аргументами сообщения для клиента
+func (e *AppError) WithArgs(args ...any) *AppError {
+	copied := *e
+	copied.Args = args
+	return &copied
+}
+
+// Write пишет ошибку в ответ в виде JSON-конверта.
+// Любая ошибка, не являющаяся AppError, превращается в internal_error, а её текст попадает только в лог.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var appErr *AppError
+	if !errors.As(err, &appErr) {
+		appErr = ErrInternal.Wrap(err)
+	}
+
+	requestID := utils.RequestIDFromContext(r.Context())
+	utils.SetErrorCode(r.Context(), appErr.Code)
+
+	// Логируем причину; идентификатор запроса, пользователь и маршрут добавляются из контекста
+	args := []any{"method", r.Method, "path", r.URL.Path, "status", appErr.Status, "code", appErr.Code}
+	if appErr.Err != nil {
+		args = append(args, "error", appErr.Err.Error())
+	}
+	if appErr.Status >= http.StatusInternalServerError {
+		logger.ErrorContext(r.Context(), "Ошибка обработки запроса", args...)
+	} else {
+		logger.WarningContext(r.Context(), "Запрос отклонён", args...)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(appErr.Status)
+	json.NewEncoder(w).Encode(ErrorResponse{Error: ErrorBody{
+		Code:      appErr.Code,
+		Message:   appErr.Message(i18n.FromContext(r.Context())),
+		RequestID: requestID,
+	}})
+}
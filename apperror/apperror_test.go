@@ -0,0 +1,64 @@
+package apperror
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Каждый код ошибки должен иметь перевод на всех языках: иначе клиент получит вместо сообщения ключ каталога
+func TestErrorCodesTranslated(t *testing.T) {
+	file, err := parser.ParseFile(token.NewFileSet(), "apperror.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var codes []string
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			value := spec.(*ast.ValueSpec)
+			for i, name := range value.Names {
+				if !strings.HasPrefix(name.Name, "Code") {
+					continue
+				}
+				code, err := strconv.Unquote(value.Values[i].(*ast.BasicLit).Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				codes = append(codes, code)
+			}
+		}
+	}
+	if len(codes) == 0 {
+		t.Fatal("в apperror.go не найдены коды ошибок")
+	}
+
+	locales, err := filepath.Glob("../i18n/locales/*.json")
+	if err != nil || len(locales) == 0 {
+		t.Fatalf("не найдены каталоги сообщений: %v", err)
+	}
+	for _, locale := range locales {
+		data, err := os.ReadFile(locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			t.Fatal(err)
+		}
+		for _, code := range codes {
+			if messages["error."+code] == "" {
+				t.Errorf("%s: нет перевода error.%s", filepath.Base(locale), code)
+			}
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"Cloud/dataBase"
+	"Cloud/logger"
+	"Cloud/models"
+	"Cloud/utils"
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Event — действие, которое нужно записать в журнал аудита
+type Event struct {
+	Action   string
+	ActorID  int // кто выполнил действие; 0 — определить по запросу
+	TargetID int // ID пользователя, над которым выполнено действие
+	Changes  map[string]models.FieldChange
+}
+
+// Auditor записывает действия с пользователями в журнал аудита и проверяет его целостность
+type Auditor struct {
+	db *sql.DB
+}
+
+// New создаёт Auditor
+func New(db *sql.DB) *Auditor {
+	return &Auditor{db: db}
+}
+
+// Record добавляет запись в журнал. Исполнитель, если не указан, — пользователь, которого JWTMiddleware проверил и записал в контекст запроса;
+// без него запись анонимная.
+// Действие уже выполнено, поэтому ошибка записи не прерывает запрос, а попадает в лог с уровнем ERROR.
+// nil Auditor ничего не записывает, что позволяет тестировать обработчики без базы данных.
+func (a *Auditor) Record(r *http.Request, event Event) {
+	if a == nil {
+		return
+	}
+
+	// Запись не должна теряться, если клиент уже отключился
+	ctx := context.WithoutCancel(r.Context())
+	if err := dataBase.DBAppendAuditEntry(ctx, a.db, newEntry(r, event)); err != nil {
+		logger.ErrorContext(ctx, "Не удалось записать событие аудита", "action", event.Action, "target_id", event.TargetID, "error", err.Error())
+	}
+}
+
+// RecordTx добавляет запись в журнал в транзакции tx: действие и запись о нём фиксируются или откатываются вместе.
+// Ошибка записи должна прервать транзакцию.
+func (a *Auditor) RecordTx(r *http.Request, tx *dataBase.Tx, event Event) error {
+	if a == nil {
+		return nil
+	}
+	return tx.AppendAuditEntry(r.Context(), newEntry(r, event))
+}
+
+// newEntry создаёт запись журнала для события в рамках запроса r
+func newEntry(r *http.Request, event Event) *models.AuditEntry {
+	entry := &models.AuditEntry{
+		Time:      time.Now(),
+		ActorID:   event.ActorID,
+		ActorIP:   utils.ClientIP(r),
+		Action:    event.Action,
+		TargetID:  event.TargetID,
+		Changes:   event.Changes,
+		RequestID: utils.RequestIDFromContext(r.Context()),
+	}
+	if entry.ActorID == 0 {
+		entry.ActorID = actorFromRequest(r)
+	}
+	return entry
+}
+
+// actorFromRequest возвращает ID пользователя, выполняющего запрос; 0 — анонимный запрос.
+// Доверять можно только ID, который JWTMiddleware положил в контекст после проверки подписи токена:
+// содержимое заголовка Authorization без проверки подделывается кем угодно.
+func actorFromRequest(r *http.Request) int {
+	if userID, ok := utils.UserIDFromContext(r.Context()); ok {
+		return userID
+	}
+	return 0
+}
+
+// errChainBroken прекращает обход журнала на первой повреждённой записи
+var errChainBroken = errors.New("цепочка хешей нарушена")
+
+// Verify проверяет цепочку хешей журнала от первой записи до последней
+func (a *Auditor) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	result := &models.AuditVerification{Valid: true}
+	prevHash := ""
+
+	err := dataBase.DBEachAuditEntry(ctx, a.db, func(entry *models.AuditEntry) error {
+		switch {
+		case entry.PrevHash != prevHash:
+			result.Reason = "prev_hash записи не совпадает с хешем предыдущей записи"
+		case entry.ComputeHash() != entry.Hash:
+			result.Reason = "содержимое записи не соответствует её хешу"
+		default:
+			result.Checked++
+			prevHash = entry.Hash
+			return nil
+		}
+		result.Valid = false
+		result.BrokenAt = entry.ID
+		return errChainBroken
+	})
+	if err != nil && !errors.Is(err, errChainBroken) {
+		return nil, err
+	}
+	return result, nil
+}
+
+// userFields возвращает поля пользователя, которые попадают в журнал. Пароль не сохраняется даже в виде хеша.
+func userFields(u *models.User) map[string]any {
+	return map[string]any{
+		"name":       u.Name,
+		"phone":      u.Phone,
+		"email":      u.Email,
+		"is_deleted": u.IsDeleted,
+		"is_banned":  u.IsBanned,
+		"locale":     u.Locale,
+		"role":       u.Role,
+	}
+}
+
+// UserChanges возвращает изменившиеся поля пользователя. before = nil — пользователь создан.
+// Смена пароля отмечается значением REDACTED без самих значений.
+func UserChanges(before, after *models.User, passwordChanged bool) map[string]models.FieldChange {
+	changes := map[string]models.FieldChange{}
+
+	afterFields := userFields(after)
+	if before == nil {
+		for name, value := range afterFields {
+			changes[name] = models.FieldChange{After: value}
+		}
+	} else {
+		beforeFields := userFields(before)
+		for name, value := range afterFields {
+			if beforeFields[name] != value {
+				changes[name] = models.FieldChange{Before: beforeFields[name], After: value}
+			}
+		}
+	}
+
+	if passwordChanged {
+		changes["password"] = models.FieldChange{Before: utils.RedactedValue, After: utils.RedactedValue}
+	}
+	return changes
+}
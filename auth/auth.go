@@ -1,13 +1,15 @@
 package auth
 
 import (
+	"Cloud/apperror"
+	"Cloud/audit"
 	"Cloud/dataBase"
 	"Cloud/email"
-	"Cloud/logger"
+	"Cloud/i18n"
 	"Cloud/models"
 	"Cloud/utils"
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"net/http"
@@ -41,10 +43,9 @@ func ValidateJWT(tokenStr string) (*models.Claims, error) {
 	})
 
 	if err != nil {
-		logger.Error("Ошибка при парсинге токена: " + err.Error())
+		return nil, err
 	}
 	if !token.Valid {
-		logger.Error("Токен недействителен")
 		return nil, fmt.Errorf("токен недействителен")
 	}
 
@@ -69,17 +70,15 @@ func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	// Извлекаем рефреш токен из куки запроса
 	cookie, err := r.Cookie("refresh_token")
 	if err != nil {
-		logger.Error("Отсутствует рефреш токен: " + err.Error())
-		http.Error(w, "Отсутствует рефреш токен", http.StatusUnauthorized)
+		apperror.Write(w, r, apperror.ErrUnauthorized.Wrap(err))
 		return
 	}
 
 	// Валидация рефреш токена
 	// Если токен недействителен или истёк, сервер снова возвращает ошибку 401 и логирует информацию о проблеме.
 	claims, err := ValidateJWT(cookie.Value)
-	if err != nil || claims == nil {
-		logger.Error("Недействительный рефреш токен: " + err.Error())
-		http.Error(w, "Недействительный рефреш токен", http.StatusUnauthorized)
+	if err != nil {
+		apperror.Write(w, r, apperror.ErrInvalidToken.Wrap(err))
 		return
 	}
 
@@ -89,8 +88,7 @@ func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	user := models.User{Email: claims.Email}
 	accessToken, _, err := GenerateAccessToken(user)
 	if err != nil {
-		logger.Error("Ошибка генерации access токена: " + err.Error())
-		http.Error(w, "Ошибка генерации токена", http.StatusInternalServerError)
+		apperror.Write(w, r, fmt.Errorf("ошибка генерации access токена: %w", err))
 		return
 	}
 
@@ -98,7 +96,7 @@ func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"accessToken": accessToken})
 }
 
-func ConfirmEmailHandler(db *sql.DB) http.HandlerFunc {
+func ConfirmEmailHandler(transactor dataBase.Transactor, auditor *audit.Auditor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var request struct {
 			Email string `json:"email"`
@@ -108,31 +106,22 @@ func ConfirmEmailHandler(db *sql.DB) http.HandlerFunc {
 		// Декодируем запрос
 		err := json.NewDecoder(r.Body).Decode(&request)
 		if err != nil {
-			logger.Error("Ошибка декодирования JSON: " + err.Error())
-			http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
 			return
 		}
 
-		// Проверяем, существует ли код для данного email
-		storedData, exists := models.TemporaryStore[request.Email]
-		if !exists {
-			logger.Error("Email не найден или код подтверждения просрочен: " + request.Email)
-			http.Error(w, "Email не найден или код подтверждения просрочен", http.StatusNotFound)
+		// Код одноразовый: проверка и изъятие атомарны, поэтому параллельный запрос с тем же кодом получит ошибку
+		// и не создаст второго пользователя
+		storedData, status := models.TemporaryStore.Claim(request.Email, request.Code, email.ConfirmationCodeTTL)
+		switch status {
+		case models.ClaimMissing:
+			apperror.Write(w, r, apperror.ErrConfirmationMissing.Wrap(fmt.Errorf("email %s", request.Email)))
 			return
-		}
-
-		// Проверяем, истек ли срок действия кода подтверждения
-		if time.Since(storedData.CreatedAt) > time.Hour {
-			logger.Error("Код подтверждения для email просрочен: " + request.Email)
-			delete(models.TemporaryStore, request.Email) // Удаляем просроченный код
-			http.Error(w, "Код подтверждения просрочен", http.StatusUnauthorized)
+		case models.ClaimExpired:
+			apperror.Write(w, r, apperror.ErrConfirmationExpired.Wrap(fmt.Errorf("email %s", request.Email)))
 			return
-		}
-
-		// Проверяем соответствие кода
-		if storedData.Code != request.Code {
-			logger.Error("Неверный код подтверждения для email: " + request.Email)
-			http.Error(w, "Неверный код подтверждения", http.StatusUnauthorized)
+		case models.ClaimInvalid:
+			apperror.Write(w, r, apperror.ErrConfirmationInvalid.Wrap(fmt.Errorf("email %s", request.Email)))
 			return
 		}
 
@@ -146,29 +135,40 @@ func ConfirmEmailHandler(db *sql.DB) http.HandlerFunc {
 		// Хеширование пароля перед сохранением
 		user.Password, err = utils.HashPassword(user.Password)
 		if err != nil {
-			logger.Error("Ошибка хеширования пароля: " + err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			models.TemporaryStore.Restore(request.Email, storedData)
+			apperror.Write(w, r, fmt.Errorf("ошибка хеширования пароля: %w", err))
 			return
 		}
 
-		// Сохраняем пользователя в базе данных
-		err = dataBase.DBCreateUser(db, &user)
+		// Пользователь и запись аудита о его создании сохраняются в одной транзакции
+		err = transactor.WithTx(r.Context(), func(tx *dataBase.Tx) error {
+			if err := tx.Users.Create(r.Context(), &user); err != nil {
+				return fmt.Errorf("ошибка создания пользователя: %w", err)
+			}
+			return auditor.RecordTx(r, tx, audit.Event{
+				Action:   models.AuditUserCreate,
+				ActorID:  user.ID,
+				TargetID: user.ID,
+				Changes:  audit.UserChanges(nil, &user, false),
+			})
+		})
 		if err != nil {
-			logger.Error("Ошибка создания пользователя: " + err.Error())
-			http.Error(w, "Не удалось создать пользователя", http.StatusInternalServerError)
+			// Пользователь не создан, поэтому код снова можно использовать — если только email или телефон не заняли,
+			// пока ждали подтверждения: тогда повтор не поможет
+			if !errors.Is(err, apperror.ErrEmailTaken) && !errors.Is(err, apperror.ErrPhoneTaken) {
+				models.TemporaryStore.Restore(request.Email, storedData)
+			}
+			apperror.Write(w, r, err)
 			return
 		}
 
-		// Удаляем код подтверждения из временного хранилища
-		delete(models.TemporaryStore, request.Email)
-
 		// Успешное подтверждение
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Email " + request.Email + " успешно подтвержден!"})
+		json.NewEncoder(w).Encode(map[string]string{"message": i18n.T(i18n.FromContext(r.Context()), "confirm.success", request.Email)})
 	}
 }
 
-func ResendConfirmationEmailHandler() http.HandlerFunc {
+func ResendConfirmationEmailHandler(mailer email.Mailer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var request struct {
 			Email string `json:"email"`
@@ -177,35 +177,32 @@ func ResendConfirmationEmailHandler() http.HandlerFunc {
 		// Декодируем запрос
 		err := json.NewDecoder(r.Body).Decode(&request)
 		if err != nil {
-			logger.Error("Ошибка декодирования JSON: " + err.Error())
-			http.Error(w, "Неверный формат данных", http.StatusBadRequest)
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
 			return
 		}
 
 		// Генерируем новый код подтверждения
 		code := utils.GenRandCode()
 
-		// Сохраняем новый код в TemporaryStore
-		models.TemporaryStore[request.Email] = struct {
-			Code      string
-			User      models.User
-			CreatedAt time.Time
-		}{
-			Code:      code,
-			User:      models.User{Email: code},
-			CreatedAt: time.Now(),
+		// Повторно отправить код можно только для незавершённой регистрации; данные регистрации сохраняются
+		if !models.TemporaryStore.Renew(request.Email, code) {
+			apperror.Write(w, r, apperror.ErrConfirmationMissing.Wrap(fmt.Errorf("email %s", request.Email)))
+			return
 		}
 
 		// Отправляем код на почту
-		err = email.SendConfirmationEmail(request.Email, code)
+		err = email.SendConfirmationEmail(r.Context(), mailer, request.Email, code, i18n.FromContext(r.Context()))
+		if email.IsSuppressed(err) {
+			apperror.Write(w, r, apperror.ErrEmailSuppressed.Wrap(err))
+			return
+		}
 		if err != nil {
-			logger.Error("Ошибка отправки email: " + err.Error())
-			http.Error(w, "Ошибка отправки email", http.StatusInternalServerError)
+			apperror.Write(w, r, apperror.ErrEmailSendFailed.Wrap(err))
 			return
 		}
 
 		// Успешная отправка
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode("Повторный код подтверждения отправлен на email " + request.Email)
+		json.NewEncoder(w).Encode(i18n.T(i18n.FromContext(r.Context()), "confirm.resent", request.Email))
 	}
 }
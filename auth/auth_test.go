@@ -0,0 +1,261 @@
+package auth
+
+import (
+	"Cloud/dataBase"
+	"Cloud/email"
+	"Cloud/i18n"
+	"Cloud/internal"
+	"Cloud/logger"
+	"Cloud/models"
+	"context"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Запускайте с -race: код подтверждения забирают из общего хранилища параллельные запросы
+func TestConfirmEmailConcurrent(t *testing.T) {
+	const addr = "race@example.com"
+	models.TemporaryStore.Put(addr, models.ConfirmationData{
+		Code:      "123456",
+		User:      models.User{Name: "race", Email: addr, Phone: "+79990000001", Password: "secret123"},
+		CreatedAt: time.Now(),
+	})
+
+	users := dataBase.NewMemoryUserRepository()
+	handler := ConfirmEmailHandler(users, nil)
+
+	const requests = 20
+	codes := make([]int, requests)
+	var wg sync.WaitGroup
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/confirm-email", strings.NewReader(`{"email":"`+addr+`","code":"123456"}`))
+			w := httptest.NewRecorder()
+			handler(w, req)
+			codes[i] = w.Code
+		}()
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			succeeded++
+		case http.StatusNotFound:
+			// код уже использован другим запросом
+		default:
+			t.Errorf("неожиданный статус %d", code)
+		}
+	}
+	if succeeded != 1 {
+		t.Fatalf("код подтверждения использован %d раз, ожидался 1", succeeded)
+	}
+
+	list, err := users.List(context.Background(), nil, 10, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("создано %d пользователей, ожидался 1", len(list))
+	}
+}
+
+func TestConfirmEmailStatuses(t *testing.T) {
+	store := models.NewConfirmationStore()
+	store.Put("a@example.com", models.ConfirmationData{Code: "111111", CreatedAt: time.Now()})
+	store.Put("old@example.com", models.ConfirmationData{Code: "222222", CreatedAt: time.Now().Add(-2 * time.Hour)})
+
+	cases := []struct {
+		email, code string
+		want        models.ClaimStatus
+	}{
+		{"missing@example.com", "111111", models.ClaimMissing},
+		{"a@example.com", "000000", models.ClaimInvalid},
+		{"old@example.com", "222222", models.ClaimExpired},
+		{"old@example.com", "222222", models.ClaimMissing}, // просроченная запись удаляется
+		{"a@example.com", "111111", models.ClaimOK},
+		{"a@example.com", "111111", models.ClaimMissing}, // код одноразовый
+	}
+	for _, tc := range cases {
+		if _, got := store.Claim(tc.email, tc.code, time.Hour); got != tc.want {
+			t.Errorf("Claim(%s, %s) = %d, ожидалось %d", tc.email, tc.code, got, tc.want)
+		}
+	}
+}
+
+// Занятый телефон не отличается от свободного ни ответом, ни письмом тому, кто регистрируется
+func TestRegisterTakenPhone(t *testing.T) {
+	t.Setenv("MAIL_FROM", "noreply@example.com")
+	users := dataBase.NewMemoryUserRepository()
+	owner := &models.User{Name: "owner", Email: "owner@example.com", Phone: "+7 (999) 000-00-02", Password: "hash", Locale: "en"}
+	if err := users.Create(context.Background(), owner); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name, email, phone string
+		wantTo             string
+		wantSubject        string
+		wantStored         bool
+	}{
+		{"свободные email и телефон", "new@example.com", "+79990000003", "new@example.com", "", true},
+		// Письмо о существующей учётной записи уходит на языке владельца, а не того, кто регистрируется
+		{"занятый email", "OWNER@example.com", "+79990000004", "OWNER@example.com", i18n.T("en", "email.account_exists.subject"), false},
+		{"занятый телефон", "other@example.com", "+79990000002", "owner@example.com", i18n.T("en", "email.account_exists.subject"), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			mailer := email.NewMemoryMailer()
+			body := `{"name":"test","email":"` + tc.email + `","phone":"` + tc.phone + `","password":"secret123"}`
+			req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+			req = req.WithContext(i18n.WithLang(req.Context(), "ru"))
+			w := httptest.NewRecorder()
+			RegisterUser(users, mailer)(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("статус %d, ожидался 200: %s", w.Code, w.Body)
+			}
+			sent := mailer.Messages()
+			if len(sent) != 1 || len(sent[0].Message.To) != 1 || sent[0].Message.To[0] != tc.wantTo {
+				t.Fatalf("письма %+v, ожидалось одно письмо на %s", sent, tc.wantTo)
+			}
+			if tc.wantSubject != "" && sent[0].Message.Subject != tc.wantSubject {
+				t.Errorf("тема письма %q, ожидалась %q", sent[0].Message.Subject, tc.wantSubject)
+			}
+			if _, status := models.TemporaryStore.Claim(tc.email, "", time.Hour); (status != models.ClaimMissing) != tc.wantStored {
+				t.Errorf("регистрация сохранена: %v, ожидалось %v", status != models.ClaimMissing, tc.wantStored)
+			}
+		})
+	}
+}
+
+// Открытие ссылки из письма (в том числе почтовым сканером) не завершает сеанс — только отправка формы
+func TestRevokeSessionRequiresPost(t *testing.T) {
+	users := dataBase.NewMemoryUserRepository()
+	user := &models.User{Name: "revoke", Email: "revoke@example.com", Password: "hash"}
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	session := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := users.UpdateTokenExpiration(context.Background(), user.ID, session); err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := SessionRevokeURL(user.ID, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := link[strings.Index(link, "token=")+len("token="):]
+
+	w := httptest.NewRecorder()
+	ConfirmRevokeSessionHandler()(w, httptest.NewRequest(http.MethodGet, "/sessions/revoke?token="+token, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `<form method="post">`) {
+		t.Fatalf("GET: статус %d, страница без формы подтверждения: %s", w.Code, w.Body)
+	}
+	if expires, _ := users.GetTokenExpiration(context.Background(), user.ID); !expires.Equal(session) {
+		t.Fatal("GET завершил сеанс")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/sessions/revoke", strings.NewReader("token="+token))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	RevokeSessionHandler(users, nil)(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST: статус %d: %s", w.Code, w.Body)
+	}
+	if expires, _ := users.GetTokenExpiration(context.Background(), user.ID); !expires.Before(session) {
+		t.Fatal("POST не завершил сеанс")
+	}
+
+	w = httptest.NewRecorder()
+	ConfirmRevokeSessionHandler()(w, httptest.NewRequest(http.MethodGet, "/sessions/revoke?token=invalid", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("недействительный токен: статус %d, ожидался 401", w.Code)
+	}
+}
+
+// requestLogSink запоминает записи лога запросов
+type requestLogSink struct {
+	mu      sync.Mutex
+	entries []models.RequestLog
+}
+
+func (s *requestLogSink) Name() string { return "memory" }
+
+func (s *requestLogSink) Write(ctx context.Context, batch []models.RequestLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, batch...)
+	return nil
+}
+
+func (s *requestLogSink) Close() error { return nil }
+
+// В лог запросов попадает только ID, проверенный JWTMiddleware; поддельный токен не подменяет пользователя
+func TestLoggingMiddlewareUserID(t *testing.T) {
+	previousKey := jwtKey
+	jwtKey = []byte("test-secret")
+	t.Cleanup(func() { jwtKey = previousKey })
+
+	users := dataBase.NewMemoryUserRepository()
+	user := &models.User{Name: "logged", Email: "logged@example.com", Password: "hash"}
+	if err := users.Create(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	token, expiresAt, err := GenerateAccessToken(*user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := users.UpdateTokenExpiration(context.Background(), user.ID, expiresAt); err != nil {
+		t.Fatal(err)
+	}
+
+	// Подпись поддельного токена сделана другим ключом, а в полезной нагрузке — ID другого пользователя
+	jwtKey = []byte("other-secret")
+	forged, _, err := GenerateAccessToken(models.User{ID: 42, Email: "forged@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtKey = []byte("test-secret")
+
+	sink := &requestLogSink{}
+	app := &internal.App{RequestLogger: logger.NewRequestLogger(sink, logger.DefaultRequestLoggerConfig())}
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	router := mux.NewRouter()
+	router.Use(LoggingMiddleware(app))
+	router.Handle("/private", JWTMiddleware(users, ok))
+	router.Handle("/public", ok)
+
+	cases := []struct{ path, token, wantUserID string }{
+		{"/private", token, strconv.Itoa(user.ID)},
+		{"/private", forged, ""},
+		{"/public", forged, ""},
+		{"/public", token, ""},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("Authorization", "Bearer "+tc.token)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if err := app.RequestLogger.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(sink.entries) != len(cases) {
+		t.Fatalf("записей в логе %d, ожидалось %d", len(sink.entries), len(cases))
+	}
+	for i, tc := range cases {
+		if got := sink.entries[i].UserID; got != tc.wantUserID {
+			t.Errorf("%s: user_id %q, ожидался %q", tc.path, got, tc.wantUserID)
+		}
+	}
+}
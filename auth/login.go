@@ -1,15 +1,19 @@
 package auth
 
 import (
+	"Cloud/apperror"
+	"Cloud/audit"
 	"Cloud/dataBase"
-	"Cloud/logger"
+	"Cloud/i18n"
 	"Cloud/models"
+	"Cloud/notify"
 	"Cloud/utils"
-	"database/sql"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -19,87 +23,75 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
-// Логика аутентификации пользователя
-func LoginUser(db *sql.DB) http.HandlerFunc {
+// Логика аутентификации пользователя.
+// notifier предупреждает пользователя о входе с нового устройства или IP-адреса, auditor записывает удачные и неудачные попытки входа.
+func LoginUser(users dataBase.UserRepository, notifier *notify.Notifier, auditor *audit.Auditor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var loginReq LoginRequest
 		var user *models.User
-		var message string
 
 		//Декодирование JSON из тела запроса
 		err := json.NewDecoder(r.Body).Decode(&loginReq)
 		if err != nil {
-			logger.Error("Ошибка декодирования JSON: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
 			return
 		}
 
 		// Проверяем, заполнены ли оба поля
 		if loginReq.Email == "" && loginReq.Phone == "" {
-			logger.Error("Не указаны email или телефон")
-			http.Error(w, "Не указаны email или телефон", http.StatusBadRequest)
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.login_missing")))
 			return
 		}
 
 		// Проверяем какой из полей заполнен, и ищем пользователя
 		if loginReq.Email != "" {
-			user, message, err = dataBase.FindUserByEmail(db, loginReq.Email)
+			user, err = users.FindByEmail(r.Context(), loginReq.Email)
 		}
 		if loginReq.Phone != "" {
-			user, message, err = dataBase.FindUserByPhone(db, loginReq.Phone)
+			user, err = users.FindByPhone(r.Context(), loginReq.Phone)
 		}
 
-		// Проверяем на ошибки и статус пользователя
+		// Пользователь не найден, заблокирован или удалён, либо произошла ошибка базы данных
 		if err != nil {
-			logger.Error("Ошибка при поиске пользователя: " + err.Error())
-			http.Error(w, "Ошибка при поиске пользователя", http.StatusInternalServerError)
-			return
-		}
-
-		// Если пользователь заблокирован или удалён, выводим сообщение
-		if message != "" {
-			logger.Error(message)
-			http.Error(w, message, http.StatusUnauthorized) // Ответ с соответствующим сообщением
-			return
-		}
-
-		// Если пользователь не найден стопаем функцию и выводим ответ
-		if user == nil {
-			http.Error(w, "Пользователь не найден", http.StatusNotFound)
+			if errors.Is(err, apperror.ErrUserNotFound) || errors.Is(err, apperror.ErrUserBanned) || errors.Is(err, apperror.ErrUserDeleted) {
+				auditor.Record(r, audit.Event{Action: models.AuditLoginFailed})
+			}
+			apperror.Write(w, r, err)
 			return
 		}
 
 		// Проверка пароля
 		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(loginReq.Password)); err != nil {
-			logger.Error("Неверный пароль")
-			http.Error(w, "Неверный пароль", http.StatusUnauthorized)
+			auditor.Record(r, audit.Event{Action: models.AuditLoginFailed, TargetID: user.ID})
+			apperror.Write(w, r, apperror.ErrInvalidCredentials.Wrap(err))
 			return
 		}
 
 		// Генерация access токена
 		accessToken, expirationTime, err := GenerateAccessToken(*user)
 		if err != nil {
-			logger.Error("Ошибка создания access токена: " + err.Error())
-			http.Error(w, "Ошибка создания access токена", http.StatusInternalServerError)
+			apperror.Write(w, r, fmt.Errorf("ошибка создания access токена: %w", err))
 			return
 		}
 
 		// Генерация refresh токена
 		refreshToken, err := GenerateRefreshToken(*user)
 		if err != nil {
-			logger.Error("Ошибка генерации refresh токена: " + err.Error())
-			http.Error(w, "Ошибка генерации refresh токена", http.StatusInternalServerError)
+			apperror.Write(w, r, fmt.Errorf("ошибка генерации refresh токена: %w", err))
 			return
 		}
 
 		// Сохраняем время истечения access токена в базе данных
-		err = dataBase.UpdateTokenExpiration(db, expirationTime, user.ID)
+		err = users.UpdateTokenExpiration(r.Context(), user.ID, expirationTime)
 		if err != nil {
-			logger.Error("Ошибка сохранения времени истечения access токена: " + err.Error())
-			http.Error(w, "Ошибка сохранения времени истечения access токена", http.StatusInternalServerError)
+			apperror.Write(w, r, fmt.Errorf("ошибка сохранения времени истечения access токена: %w", err))
 			return
 		}
 
+		// Запоминаем устройство входа и при необходимости отправляем предупреждение
+		notifier.Login(context.WithoutCancel(r.Context()), user, utils.ClientIP(r), r.UserAgent(), expirationTime)
+		auditor.Record(r, audit.Event{Action: models.AuditLogin, ActorID: user.ID, TargetID: user.ID})
+
 		// Сохранение refresh токена в куки
 		http.SetCookie(w, &http.Cookie{
 			Name:     "refresh_token",
@@ -116,8 +108,8 @@ func LoginUser(db *sql.DB) http.HandlerFunc {
 	}
 }
 
-// Логика выхода с аккаунта пользователя
-func LogoutHandler(db *sql.DB) http.HandlerFunc {
+// Логика выхода с аккаунта пользователя; маршрут закрыт JWTMiddleware
+func LogoutHandler(users dataBase.UserRepository, auditor *audit.Auditor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Удаляем refresh токен из куки
 		http.SetCookie(w, &http.Cookie{
@@ -130,39 +122,22 @@ func LogoutHandler(db *sql.DB) http.HandlerFunc {
 		})
 		// r.URL.User.Username() // Эта строка не нужна для выхода
 
-		authHeader := r.Header.Get("Authorization")
-		// Проверка наличия токена
-		if authHeader == "" {
-			logger.Error("Отсутствует токен авторизации")
-			http.Error(w, "Отсутствует токен авторизации", http.StatusUnauthorized)
-			return
-		}
-
-		// Извлекаем токен
-		tokenParts := strings.Split(authHeader, "Bearer ")
-		if len(tokenParts) != 2 {
-			logger.Error("Недействительный токен")
-			http.Error(w, "Недействительный токен", http.StatusUnauthorized)
-			return
-		}
-		tokenStr := tokenParts[1]
-
-		// Извлекаем userID из токена
-		userID, err := utils.GetUserIDFromToken(tokenStr)
-		if err != nil {
-			logger.Error("Недействительный токен: " + err.Error())
-			http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+		// Пользователя определяет JWTMiddleware, проверивший подпись токена и его действие
+		userID, ok := utils.UserIDFromContext(r.Context())
+		if !ok {
+			apperror.Write(w, r, apperror.ErrUnauthorized)
 			return
 		}
 
 		// Изменение времени истечения токена
-		err = dataBase.UpdateTokenExpiration(db, time.Now(), userID)
+		err := users.UpdateTokenExpiration(r.Context(), userID, time.Now())
 		if err != nil {
-			logger.Error("Ошибка сохранения времени истечения access токена: " + err.Error())
-			http.Error(w, "Ошибка сохранения времени истечения access токена", http.StatusInternalServerError)
+			apperror.Write(w, r, fmt.Errorf("ошибка сохранения времени истечения access токена: %w", err))
 			return
 		}
 
+		auditor.Record(r, audit.Event{Action: models.AuditLogout, ActorID: userID, TargetID: userID})
+
 		w.WriteHeader(http.StatusNoContent) // Успешный логаут без контента
 	}
 }
@@ -1,52 +1,63 @@
 package auth
 
 import (
+	"Cloud/apperror"
 	"Cloud/dataBase"
+	"Cloud/i18n"
 	"Cloud/internal"
 	"Cloud/logger"
+	"Cloud/models"
 	"Cloud/utils"
-	"database/sql"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// ResponseWriterWrapper добавляет возможность перехватывать статус ответа
+// ResponseWriterWrapper добавляет возможность перехватывать статус ответа и считать записанные байты
 type ResponseWriterWrapper struct {
 	http.ResponseWriter
-	StatusCode int
+	StatusCode   int
+	BytesWritten int64
+}
+
+// countingBody считает байты, прочитанные обработчиком из тела запроса
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
 }
 
 // Мидлвар для проверки токена
-func JWTMiddleware(db *sql.DB, next http.Handler) http.Handler {
+func JWTMiddleware(users dataBase.UserRepository, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Получение заголовка авторизации
-		authHeader := r.Header.Get("Authorization")
-
-		// Проверка наличия токена
-		if authHeader == "" {
-			logger.Error("Отсутствует токен авторизации")
-			http.Error(w, "Требуется авторизация", http.StatusUnauthorized)
+		// Извлечение токена из заголовка авторизации
+		tokenStr, err := bearerToken(r)
+		if err != nil {
+			apperror.Write(w, r, err)
 			return
 		}
 
-		// Извлечение токена
-		tokenStr := strings.Split(authHeader, "Bearer ")[1]
-
 		// Валидация токена
 		claims, err := ValidateJWT(tokenStr)
 		if err != nil {
-			logger.Error("Недействительный токен: " + err.Error())
-			http.Error(w, "Недействительный токен", http.StatusUnauthorized)
+			apperror.Write(w, r, apperror.ErrInvalidToken.Wrap(err))
 			return
 		}
 
 		// Получение времени истечения токена из базы данных
-		tokenExpiration, err := dataBase.GetTokenExpiration(db, claims.UserID)
+		tokenExpiration, err := users.GetTokenExpiration(r.Context(), claims.UserID)
 		if err != nil {
-			logger.Error("Ошибка получения времени истечения токена из базы: " + err.Error())
-			http.Error(w, "Ошибка получения времени истечения токена", http.StatusInternalServerError)
+			apperror.Write(w, r, err)
 			return
 		}
 
@@ -55,25 +66,115 @@ func JWTMiddleware(db *sql.DB, next http.Handler) http.Handler {
 
 		// Проверка на соответствие времени истечения
 		if !tokenExpirationRounded.Equal(claimsExpirationRounded) {
-			logger.Info("Токен истек, доступ запрещен.")
-			http.Error(w, "Токен истек", http.StatusUnauthorized)
+			apperror.Write(w, r, apperror.ErrTokenExpired)
 			return
 		}
 
 		// Добавление данных пользователя в контекст
 		r.Header.Set("userEmail", claims.Email)
+		r = r.WithContext(utils.WithUserID(r.Context(), claims.UserID))
+
+		// Сохранённый язык пользователя важнее заголовка Accept-Language
+		locale, err := users.GetLocale(r.Context(), claims.UserID)
+		if err != nil {
+			logger.WarningContext(r.Context(), "Не удалось получить язык пользователя", "error", err.Error())
+		} else if i18n.IsSupported(locale) {
+			w.Header().Set("Content-Language", locale)
+			r = r.WithContext(i18n.WithLang(r.Context(), locale))
+		}
 
 		// Передача управления следующему обработчику
 		next.ServeHTTP(w, r)
 	})
 }
 
+// bearerToken извлекает токен из заголовка "Authorization: Bearer <token>"
+func bearerToken(r *http.Request) (string, error) {
+	authHeader := r.Header.Get("Authorization")
+
+	// Проверка наличия токена
+	if authHeader == "" {
+		return "", apperror.ErrUnauthorized
+	}
+
+	tokenStr, ok := strings.CutPrefix(authHeader, "Bearer ")
+	if !ok || tokenStr == "" {
+		return "", apperror.ErrInvalidToken
+	}
+
+	return tokenStr, nil
+}
+
+// RequestIDMiddleware присваивает каждому запросу идентификатор и возвращает его в заголовке X-Request-ID.
+// Идентификатор, пришедший от клиента или прокси, переиспользуется, если он имеет допустимый формат.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(utils.RequestIDHeader)
+		if !utils.IsValidRequestID(requestID) {
+			requestID = utils.GenRequestID()
+		}
+
+		w.Header().Set(utils.RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(utils.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// RequireRole пропускает только пользователей с одной из указанных ролей.
+// Подключается после JWTMiddleware, который кладёт ID пользователя в контекст.
+func RequireRole(users dataBase.UserRepository, roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := utils.UserIDFromContext(r.Context())
+			if !ok {
+				apperror.Write(w, r, apperror.ErrUnauthorized)
+				return
+			}
+
+			role, err := users.GetRole(r.Context(), userID)
+			if err != nil {
+				apperror.Write(w, r, err)
+				return
+			}
+
+			if !slices.Contains(roles, role) {
+				apperror.Write(w, r, apperror.ErrForbidden.Wrap(fmt.Errorf("user %d has role %q", userID, role)))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LanguageMiddleware выбирает язык ответа по заголовку Accept-Language
+func LanguageMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := i18n.Negotiate(r.Header.Get("Accept-Language"))
+
+		w.Header().Set("Content-Language", lang)
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(i18n.WithLang(r.Context(), lang)))
+	})
+}
+
 // WriteHeader перехватывает статус ответа
 func (rw *ResponseWriterWrapper) WriteHeader(code int) {
 	rw.StatusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Write считает байты тела ответа
+func (rw *ResponseWriterWrapper) Write(b []byte) (int, error) {
+	n, err := rw.ResponseWriter.Write(b)
+	rw.BytesWritten += int64(n)
+	return n, err
+}
+
+// Unwrap даёт http.ResponseController доступ к исходному ResponseWriter (Flush, дедлайны)
+func (rw *ResponseWriterWrapper) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // LoggingMiddleware логирует все запросы, включая статус ответа и ошибки
 func LoggingMiddleware(app *internal.App) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -82,45 +183,56 @@ func LoggingMiddleware(app *internal.App) func(http.Handler) http.Handler {
 			start := time.Now()
 			userID := ""
 
-			wrapper := ResponseWriterWrapper{w, 200}
-
-			next.ServeHTTP(&wrapper, r)
+			wrapper := ResponseWriterWrapper{ResponseWriter: w, StatusCode: http.StatusOK}
 
-			if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+			// Шаблон маршрута группирует запросы вида /user/1, /user/2 в /user/{id}
+			route := ""
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
 
-				// Проверяем, что заголовок начинается с "Bearer "
-				if strings.HasPrefix(authHeader, "Bearer ") {
+			// Код ошибки заполняет apperror.Write, ID пользователя — JWTMiddleware,
+			// маршрут попадает во все записи лога этого запроса
+			ctx, errorCode := utils.WithErrorCodeSlot(r.Context())
+			ctx, verifiedUserID := utils.WithUserIDSlot(ctx)
+			r = r.WithContext(logger.WithRoute(ctx, route))
 
-					// Извлекаем токен
-					tokenStr := strings.Split(authHeader, "Bearer ")[1]
+			var body *countingBody
+			if r.Body != nil && r.Body != http.NoBody {
+				body = &countingBody{ReadCloser: r.Body}
+				r.Body = body
+			}
 
-					// Декодируем токен и получаем userID
-					userId, err := utils.GetUserIDFromToken(tokenStr)
-					if err != nil {
-						logger.Error("Недействительный токен: " + err.Error())
-						http.Error(w, "Недействительный токен", http.StatusUnauthorized)
-						return
-					}
+			next.ServeHTTP(&wrapper, r)
 
-					// Присваиваем userID
-					userID = strconv.Itoa(userId)
-				}
+			// ID пользователя записывает JWTMiddleware после проверки подписи и срока токена;
+			// для открытых маршрутов и недействительных токенов поле остаётся пустым
+			if *verifiedUserID != 0 {
+				userID = strconv.Itoa(*verifiedUserID)
 			}
 
-			err := app.RequestLogger.Log(
-				r.Method,
-				r.URL.Path,
-				userID,
-				r.RemoteAddr,
-				r.UserAgent(),
-				wrapper.StatusCode,
-				time.Since(start),
-			)
-
-			if err != nil {
-				// Логируем ошибку записи лога, если она произошла
-				logger.Error("Ошибка при записи лога запроса:" + err.Error())
+			var bytesIn int64
+			if body != nil {
+				bytesIn = body.n
 			}
+
+			// Запись в MongoDB идёт в фоне, ответ клиенту не задерживается
+			app.RequestLogger.Log(models.RequestLog{
+				RequestID:  utils.RequestIDFromContext(r.Context()),
+				Method:     r.Method,
+				Endpoint:   r.URL.Path,
+				Route:      route,
+				Query:      utils.RedactQuery(r.URL.RawQuery),
+				UserID:     userID,
+				IP:         utils.ClientIP(r),
+				UserAgent:  r.UserAgent(),
+				Time:       start,
+				StatusCode: wrapper.StatusCode,
+				ErrorCode:  *errorCode,
+				BytesIn:    bytesIn,
+				BytesOut:   wrapper.BytesWritten,
+				DurationMs: float64(time.Since(start)) / float64(time.Millisecond),
+			})
 		})
 	}
 }
@@ -1,52 +1,107 @@
 package auth
 
 import (
+	"Cloud/apperror"
+	"Cloud/dataBase"
 	"Cloud/email"
+	"Cloud/i18n"
 	"Cloud/logger"
 	"Cloud/models"
 	"Cloud/utils"
-	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 )
 
-// Регистрация пользователя (не админ)
-func RegisterUser(db *sql.DB) http.HandlerFunc {
+// Регистрация пользователя (не админ).
+// Ответ не зависит от того, заняты ли уже email или телефон: вместо кода подтверждения владельцу учётной записи
+// уходит письмо о попытке повторной регистрации, и подтверждать регистрацию нечем.
+func RegisterUser(users dataBase.UserRepository, mailer email.Mailer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var user models.User
 
 		err := json.NewDecoder(r.Body).Decode(&user)
 		if err != nil {
-			logger.Error("Ошибка декодирования JSON: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
+			return
 		}
 
 		//Валидация данных пользователя
 		if err := utils.ValidateUserForCreate(user); err != nil {
-			logger.Error("User validation failed!" + err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apperror.Write(w, r, apperror.Validation(err))
+			return
+		}
+
+		// Письмо отправляем на языке, выбранном пользователем, либо на языке запроса
+		lang := i18n.FromContext(r.Context())
+		if user.Locale != "" {
+			lang = user.Locale
+		}
+
+		// Письмо о существующей учётной записи читает её владелец, поэтому оно уходит на языке владельца
+		taken, ownerLocale, err := users.EmailTaken(r.Context(), user.Email)
+		if err != nil {
+			apperror.Write(w, r, fmt.Errorf("ошибка проверки email: %w", err))
 			return
 		}
+		if !taken && user.Phone != "" {
+			owner, ownerLocale, err := users.PhoneOwner(r.Context(), user.Phone)
+			if err != nil {
+				apperror.Write(w, r, fmt.Errorf("ошибка проверки телефона: %w", err))
+				return
+			}
+			if owner != "" {
+				// Письмо получает владелец номера, а не тот, кто регистрируется: иначе по ответу
+				// или по письму можно было бы узнать, зарегистрирован ли номер
+				if err := email.SendAccountExistsEmail(r.Context(), mailer, owner, ownerLang(ownerLocale, lang)); err != nil {
+					logger.WarningContext(r.Context(), "Не удалось отправить письмо владельцу телефона", "error", err.Error())
+				}
+				writeCodeSent(w, r, user.Email)
+				return
+			}
+		}
 
 		// Генерация кода подтверждения
-		confirmationCode := utils.GenRandCode()                         // создайте эту функцию для генерации кода
-		err = email.SendConfirmationEmail(user.Email, confirmationCode) // отправка кода на почту
+		confirmationCode := utils.GenRandCode() // создайте эту функцию для генерации кода
+		if taken {
+			err = email.SendAccountExistsEmail(r.Context(), mailer, user.Email, ownerLang(ownerLocale, lang))
+		} else {
+			err = email.SendConfirmationEmail(r.Context(), mailer, user.Email, confirmationCode, lang) // отправка кода на почту
+		}
+		if email.IsSuppressed(err) {
+			// Адрес ранее отклонялся или получатель жаловался на спам — письмо не дойдёт
+			apperror.Write(w, r, apperror.ErrEmailSuppressed.Wrap(err))
+			return
+		}
 		if err != nil {
-			logger.Error("Ошибка отправки письма: " + err.Error())
-			http.Error(w, "Ошибка отправки письма", http.StatusInternalServerError)
+			apperror.Write(w, r, apperror.ErrEmailSendFailed.Wrap(err))
 			return
 		}
 
 		// Сохраняем код, данные пользователя и время создания в TemporaryStore на 1 час
-		models.TemporaryStore[user.Email] = models.ConfirmationData{
-			Code:      confirmationCode,
-			User:      user,
-			CreatedAt: time.Now(),
+		if !taken {
+			models.TemporaryStore.Put(user.Email, models.ConfirmationData{
+				Code:      confirmationCode,
+				User:      user,
+				CreatedAt: time.Now(),
+			})
 		}
 
-		// Ответ клиенту
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{"message": "Код подтверждения отправлен на " + user.Email})
+		writeCodeSent(w, r, user.Email)
+	}
+}
+
+// ownerLang возвращает язык владельца учётной записи; если он не выбран, письмо уходит на языке запроса
+func ownerLang(locale, fallback string) string {
+	if i18n.IsSupported(locale) {
+		return locale
 	}
+	return fallback
+}
+
+// writeCodeSent отвечает клиенту, что код подтверждения отправлен на address
+func writeCodeSent(w http.ResponseWriter, r *http.Request, address string) {
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"message": i18n.T(i18n.FromContext(r.Context()), "register.code_sent", address)})
 }
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"Cloud/apperror"
+	"Cloud/audit"
+	"Cloud/dataBase"
+	"Cloud/i18n"
+	"Cloud/models"
+	"Cloud/utils"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"html/template"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// RevokeLinkTTL — сколько действует ссылка на завершение сеанса из письма
+const RevokeLinkTTL = 7 * 24 * time.Hour
+
+// revokeAudience отличает токены ссылок на завершение сеанса от access токенов
+const revokeAudience = "session-revoke"
+
+// revokeClaims — содержимое токена ссылки на завершение сеанса.
+// Сеанс определяется временем истечения access токена, которое хранится в users.token_expires_at.
+type revokeClaims struct {
+	UserID           int   `json:"uid"`
+	SessionExpiresAt int64 `json:"sexp"`
+	jwt.RegisteredClaims
+}
+
+// SessionRevokeURL строит подписанную ссылку, по которой пользователь может завершить сеанс из письма
+func SessionRevokeURL(userID int, sessionExpiresAt time.Time) (string, error) {
+	claims := &revokeClaims{
+		UserID:           userID,
+		SessionExpiresAt: sessionExpiresAt.Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{revokeAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(RevokeLinkTTL)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+	if err != nil {
+		return "", err
+	}
+
+	return utils.BaseURL() + "/sessions/revoke?token=" + url.QueryEscape(token), nil
+}
+
+// revokePage — страница, которую открывает ссылка из письма. С токеном показывает форму подтверждения,
+// без него — результат.
+var revokePage = template.Must(template.New("revoke").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Token}}<form method="post"><input type="hidden" name="token" value="{{.Token}}"><button type="submit">{{.Button}}</button></form>{{end}}
+</body>
+</html>
+`))
+
+// ConfirmRevokeSessionHandler показывает страницу подтверждения завершения сеанса по ссылке из письма.
+// Сам сеанс не завершается: почтовые сканеры и предпросмотр ссылок открывают ссылки GET-запросом.
+func ConfirmRevokeSessionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if _, err := parseRevokeToken(token); err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+		writeRevokePage(w, r, "session.revoke_confirm", token)
+	}
+}
+
+// RevokeSessionHandler завершает сеанс по токену из формы страницы подтверждения.
+// Если сеанс уже завершён или сменился новым входом, ничего не меняется.
+func RevokeSessionHandler(users dataBase.UserRepository, auditor *audit.Auditor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := parseRevokeToken(r.PostFormValue("token"))
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		tokenExpiration, err := users.GetTokenExpiration(r.Context(), claims.UserID)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		// Завершаем сеанс так же, как при выходе: access токен с этим временем истечения перестаёт приниматься
+		if tokenExpiration.Unix() == claims.SessionExpiresAt {
+			if err := users.UpdateTokenExpiration(r.Context(), claims.UserID, time.Now()); err != nil {
+				apperror.Write(w, r, fmt.Errorf("ошибка завершения сеанса: %w", err))
+				return
+			}
+			auditor.Record(r, audit.Event{Action: models.AuditSessionRevoke, ActorID: claims.UserID, TargetID: claims.UserID})
+		}
+
+		writeRevokePage(w, r, "session.revoked", "")
+	}
+}
+
+// parseRevokeToken проверяет подпись, срок действия и назначение токена ссылки на завершение сеанса
+func parseRevokeToken(raw string) (*revokeClaims, error) {
+	claims := &revokeClaims{}
+	token, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	})
+	if err != nil || !token.Valid || !claims.VerifyAudience(revokeAudience, true) {
+		return nil, apperror.ErrInvalidToken.Wrap(fmt.Errorf("ссылка на завершение сеанса недействительна: %v", err))
+	}
+	return claims, nil
+}
+
+// writeRevokePage отвечает страницей с сообщением messageKey; непустой token добавляет форму подтверждения
+func writeRevokePage(w http.ResponseWriter, r *http.Request, messageKey, token string) {
+	lang := i18n.FromContext(r.Context())
+	// Токен есть в адресе страницы и в форме: страницу не кешируем и адрес не передаём другим сайтам
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.WriteHeader(http.StatusOK)
+	revokePage.Execute(w, map[string]string{
+		"Lang":    lang,
+		"Title":   i18n.T(lang, "session.revoke_title"),
+		"Message": i18n.T(lang, messageKey),
+		"Button":  i18n.T(lang, "session.revoke_button"),
+		"Token":   token,
+	})
+}
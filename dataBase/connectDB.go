@@ -9,7 +9,10 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"log"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,43 +21,256 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 
-// ConnectPostgresDB устанавливает подключение к базе данных PostgreSQL и возвращает объект DB.
-// @Summary Подключение к базе данных PostgreSQL
-// @Description Устанавливает соединение с базой данных PostgreSQL с использованием строки подключения.
-// @Success 200 {string} string "Успешное подключение к PostgreSQL"
-// @Failure 500 {object} ErrorResponse "Ошибка подключения к PostgreSQL"
-// @Router /connect-postgres [get]
-func ConnectPostgresDB() *sql.DB {
-
-	hostPostgresDB := os.Getenv("POSTGRES_HOST")
-	portPostgresDB := os.Getenv("POSTGRES_PORT")
-	userPostgresDB := os.Getenv("POSTGRES_USER")
-	passwordPostgresDB := os.Getenv("POSTGRES_PASS")
-	dbnamePostgresDB := os.Getenv("POSTGRES_NAME")
-
-	// Строка подключения к базе данных PostgreSQL
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=require",
-		hostPostgresDB, portPostgresDB, userPostgresDB, passwordPostgresDB, dbnamePostgresDB)
-
-	// Открытие подключения к базе данных
-	db, err := sql.Open("postgres", connStr)
+// PostgresConfig — параметры подключения к PostgreSQL
+type PostgresConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	Name     string
+
+	SSLMode     string // disable, require, verify-ca или verify-full
+	SSLRootCert string // сертификат CA для verify-ca и verify-full
+	SSLCert     string // клиентский сертификат
+	SSLKey      string // ключ клиентского сертификата
+
+	MaxOpenConns    int           // 0 — без ограничения
+	MaxIdleConns    int           // сколько простаивающих соединений держать в пуле
+	ConnMaxLifetime time.Duration // соединение закрывается после стольких минут жизни, 0 — никогда
+	ConnMaxIdleTime time.Duration // простаивающее соединение закрывается после такой паузы, 0 — никогда
+
+	StatementTimeout time.Duration // PostgreSQL прерывает запросы дольше этого времени, 0 — без ограничения
+
+	ConnectAttempts int           // сколько раз пытаться подключиться при запуске
+	ConnectBackoff  time.Duration // пауза перед второй попыткой, далее удваивается до maxConnectBackoff
+
+	ReplicaDSN string // строка подключения к реплике для чтения списков; пусто — всё читается с основной базы
+}
+
+// maxConnectBackoff — верхняя граница паузы между попытками подключения
+const maxConnectBackoff = 30 * time.Second
+
+// DefaultPostgresConfig возвращает параметры подключения по умолчанию
+func DefaultPostgresConfig() PostgresConfig {
+	return PostgresConfig{
+		SSLMode:          "require",
+		MaxOpenConns:     25,
+		MaxIdleConns:     10,
+		ConnMaxLifetime:  30 * time.Minute,
+		ConnMaxIdleTime:  5 * time.Minute,
+		StatementTimeout: 30 * time.Second,
+		ConnectAttempts:  10,
+		ConnectBackoff:   time.Second,
+	}
+}
+
+// PostgresConfigFromEnv читает параметры подключения из переменных окружения POSTGRES_*
+func PostgresConfigFromEnv() (PostgresConfig, error) {
+	cfg := DefaultPostgresConfig()
+	cfg.Host = os.Getenv("POSTGRES_HOST")
+	cfg.Port = os.Getenv("POSTGRES_PORT")
+	cfg.User = os.Getenv("POSTGRES_USER")
+	cfg.Password = os.Getenv("POSTGRES_PASS")
+	cfg.Name = os.Getenv("POSTGRES_NAME")
+	cfg.SSLRootCert = os.Getenv("POSTGRES_SSLROOTCERT")
+	cfg.SSLCert = os.Getenv("POSTGRES_SSLCERT")
+	cfg.SSLKey = os.Getenv("POSTGRES_SSLKEY")
+	cfg.ReplicaDSN = os.Getenv("POSTGRES_REPLICA_DSN")
+
+	if value := os.Getenv("POSTGRES_SSLMODE"); value != "" {
+		switch value {
+		case "disable", "require", "verify-ca", "verify-full":
+			cfg.SSLMode = value
+		default:
+			return cfg, fmt.Errorf("некорректное значение POSTGRES_SSLMODE: %q", value)
+		}
+	}
+
+	ints := []struct {
+		name string
+		dst  *int
+		min  int
+	}{
+		{"POSTGRES_MAX_OPEN_CONNS", &cfg.MaxOpenConns, 0},
+		{"POSTGRES_MAX_IDLE_CONNS", &cfg.MaxIdleConns, 0},
+		{"POSTGRES_CONNECT_ATTEMPTS", &cfg.ConnectAttempts, 1},
+	}
+	for _, v := range ints {
+		if value := os.Getenv(v.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < v.min {
+				return cfg, fmt.Errorf("некорректное значение %s: %q", v.name, value)
+			}
+			*v.dst = n
+		}
+	}
+
+	durations := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"POSTGRES_CONN_MAX_LIFETIME", &cfg.ConnMaxLifetime},
+		{"POSTGRES_CONN_MAX_IDLE_TIME", &cfg.ConnMaxIdleTime},
+		{"POSTGRES_STATEMENT_TIMEOUT", &cfg.StatementTimeout},
+		{"POSTGRES_CONNECT_BACKOFF", &cfg.ConnectBackoff},
+	}
+	for _, v := range durations {
+		if value := os.Getenv(v.name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 {
+				return cfg, fmt.Errorf("некорректное значение %s: %q", v.name, value)
+			}
+			*v.dst = d
+		}
+	}
+
+	// Простаивающих соединений не может быть больше, чем открытых
+	if cfg.MaxOpenConns > 0 && cfg.MaxIdleConns > cfg.MaxOpenConns {
+		cfg.MaxIdleConns = cfg.MaxOpenConns
+	}
+
+	return cfg, nil
+}
+
+// DSN возвращает строку подключения к основной базе
+func (c PostgresConfig) DSN() string {
+	params := []struct{ key, value string }{
+		{"host", c.Host},
+		{"port", c.Port},
+		{"user", c.User},
+		{"password", c.Password},
+		{"dbname", c.Name},
+		{"sslmode", c.SSLMode},
+		{"sslrootcert", c.SSLRootCert},
+		{"sslcert", c.SSLCert},
+		{"sslkey", c.SSLKey},
+	}
+
+	var parts []string
+	for _, p := range params {
+		if p.value != "" {
+			parts = append(parts, p.key+"="+quoteDSNValue(p.value))
+		}
+	}
+	return withStatementTimeout(strings.Join(parts, " "), c.StatementTimeout)
+}
+
+// quoteDSNValue экранирует значение для строки подключения вида key=value
+func quoteDSNValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// withStatementTimeout добавляет к строке подключения параметр statement_timeout; lib/pq передаёт его серверу при подключении.
+// Поддерживаются строки вида key=value и URL postgres://.
+func withStatementTimeout(dsn string, timeout time.Duration) string {
+	if timeout <= 0 {
+		return dsn
+	}
+	ms := strconv.FormatInt(timeout.Milliseconds(), 10)
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn
+		}
+		query := u.Query()
+		if !query.Has("statement_timeout") {
+			query.Set("statement_timeout", ms)
+		}
+		u.RawQuery = query.Encode()
+		return u.String()
+	}
+
+	if strings.Contains(dsn, "statement_timeout=") {
+		return dsn
+	}
+	return strings.TrimSpace(dsn + " statement_timeout=" + ms)
+}
+
+// Postgres — пулы соединений с основной базой и репликой для чтения
+type Postgres struct {
+	Primary *sql.DB
+	Replica *sql.DB // совпадает с Primary, если реплика не настроена
+}
+
+// Close закрывает пулы соединений
+func (p *Postgres) Close() error {
+	if p.Replica != p.Primary {
+		p.Replica.Close()
+	}
+	return p.Primary.Close()
+}
+
+// Pools возвращает пулы по именам для метрик; реплика указывается, только если она настроена
+func (p *Postgres) Pools() map[string]*sql.DB {
+	pools := map[string]*sql.DB{"primary": p.Primary}
+	if p.Replica != p.Primary {
+		pools["replica"] = p.Replica
+	}
+	return pools
+}
+
+// ConnectPostgresDB подключается к основной базе PostgreSQL и, если задан ReplicaDSN, к реплике.
+// Пока база недоступна, например при одновременном запуске с контейнером базы, подключение повторяется
+// с растущей паузой до ConnectAttempts раз.
+func ConnectPostgresDB(ctx context.Context, cfg PostgresConfig) (*Postgres, error) {
+	primary, err := openPostgres(ctx, "основной базе", cfg.DSN(), cfg)
 	if err != nil {
-		// Логирование ошибки при неудачном подключении
-		logger.Error("Failed to connect to PostgresDB!" + err.Error())
-		log.Fatal(err) // Завершение программы в случае ошибки
+		return nil, err
 	}
 
-	// Проверка соединения с базой данных (ping)
-	if err := db.Ping(); err != nil {
-		// Логирование ошибки при неудачном пинге
-		logger.Error("Failed to ping PostgresDB!" + err.Error())
-		log.Fatal(err) // Завершение программы в случае ошибки
+	pg := &Postgres{Primary: primary, Replica: primary}
+	if cfg.ReplicaDSN != "" {
+		replica, err := openPostgres(ctx, "реплике", withStatementTimeout(cfg.ReplicaDSN, cfg.StatementTimeout), cfg)
+		if err != nil {
+			primary.Close()
+			return nil, err
+		}
+		pg.Replica = replica
 	}
+	return pg, nil
+}
+
+// openPostgres открывает пул соединений с настройками cfg и ждёт, пока база ответит на ping
+func openPostgres(ctx context.Context, name, dsn string, cfg PostgresConfig) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("некорректные параметры подключения к %s: %w", name, err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	backoff := cfg.ConnectBackoff
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			logger.Info(fmt.Sprintf("Успешно подключено к %s PostgreSQL", name))
+			return db, nil
+		}
+		if attempt >= cfg.ConnectAttempts {
+			break
+		}
 
-	logger.Info("Успешно подключено к PostgresDB")
+		logger.Warning(fmt.Sprintf("Не удалось подключиться к %s PostgreSQL (попытка %d из %d), повтор через %s: %s",
+			name, attempt, cfg.ConnectAttempts, backoff, err.Error()))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			db.Close()
+			return nil, ctx.Err()
+		}
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
 
-	// Возврат объекта подключения к базе данных
-	return db
+	db.Close()
+	return nil, fmt.Errorf("не удалось подключиться к %s PostgreSQL за %d попыток: %w", name, cfg.ConnectAttempts, err)
 }
 
 // ConnectMongoDB устанавливает подключение к базе данных MongoDB и возвращает объект клиента.
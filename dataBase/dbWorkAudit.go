@@ -0,0 +1,159 @@
+package dataBase
+
+import (
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// auditLockKey — ключ advisory-блокировки, под которой добавляются записи аудита, чтобы цепочка хешей не ветвилась
+const auditLockKey = 0x61756469 // "audi"
+
+// auditColumns — столбцы audit_log в порядке сканирования scanAuditEntry
+const auditColumns = `id, time, actor_id, actor_ip, action, target_id, changes, request_id, prev_hash, hash`
+
+// AuditFilter — условия отбора записей аудита; пустые поля не ограничивают выборку
+type AuditFilter struct {
+	ActorID  int
+	TargetID int
+	Action   string
+	From     time.Time // не раньше (включительно)
+	To       time.Time // раньше (не включительно)
+}
+
+// DBAppendAuditEntry добавляет запись в журнал аудита (таблица audit_log) в отдельной транзакции
+func DBAppendAuditEntry(ctx context.Context, db *sql.DB, entry *models.AuditEntry) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := appendAuditEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// appendAuditEntry читает хеш предыдущей записи и вставляет новую под advisory-блокировкой, которая держится до конца транзакции q.
+// Транзакция должна быть READ COMMITTED: при более строгой изоляции снимок может не содержать последнюю запись,
+// и вставка нарушит уникальность prev_hash.
+func appendAuditEntry(ctx context.Context, q dbtx, entry *models.AuditEntry) error {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+
+	if _, err := q.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditLockKey); err != nil {
+		return err
+	}
+
+	err = q.QueryRowContext(ctx, `SELECT hash FROM audit_log ORDER BY id DESC LIMIT 1`).Scan(&entry.PrevHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		entry.PrevHash = ""
+	} else if err != nil {
+		return err
+	}
+
+	// PostgreSQL хранит время с точностью до микросекунды; хеш считаем от того же значения
+	entry.Time = entry.Time.UTC().Truncate(time.Microsecond)
+	entry.Hash = entry.ComputeHash()
+
+	return q.QueryRowContext(ctx, `INSERT INTO audit_log (time, actor_id, actor_ip, action, target_id, changes, request_id, prev_hash, hash)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		entry.Time, entry.ActorID, entry.ActorIP, entry.Action, entry.TargetID, changes, entry.RequestID, entry.PrevHash, entry.Hash).
+		Scan(&entry.ID)
+}
+
+// DBListAuditEntries возвращает до limit записей аудита от новых к старым, начиная с записи перед beforeID (0 — с самой новой)
+func DBListAuditEntries(ctx context.Context, db *sql.DB, filter AuditFilter, beforeID int64, limit int) ([]*models.AuditEntry, error) {
+	var conditions []string
+	var args []any
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", "$"+strconv.Itoa(len(args))))
+	}
+
+	if filter.ActorID != 0 {
+		add("actor_id = ?", filter.ActorID)
+	}
+	if filter.TargetID != 0 {
+		add("target_id = ?", filter.TargetID)
+	}
+	if filter.Action != "" {
+		add("action = ?", filter.Action)
+	}
+	if !filter.From.IsZero() {
+		add("time >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("time < ?", filter.To)
+	}
+	if beforeID > 0 {
+		add("id < ?", beforeID)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_log`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	args = append(args, limit)
+	query += ` ORDER BY id DESC LIMIT $` + strconv.Itoa(len(args))
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := make([]*models.AuditEntry, 0)
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// DBEachAuditEntry проходит по журналу аудита в порядке добавления и вызывает fn для каждой записи; ошибка fn прекращает обход
+func DBEachAuditEntry(ctx context.Context, db *sql.DB, fn func(*models.AuditEntry) error) error {
+	rows, err := db.QueryContext(ctx, `SELECT `+auditColumns+` FROM audit_log ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func scanAuditEntry(row rowScanner) (*models.AuditEntry, error) {
+	var entry models.AuditEntry
+	var changes []byte
+
+	err := row.Scan(&entry.ID, &entry.Time, &entry.ActorID, &entry.ActorIP, &entry.Action, &entry.TargetID, &changes, &entry.RequestID, &entry.PrevHash, &entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) > 0 {
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, err
+		}
+	}
+	return &entry, nil
+}
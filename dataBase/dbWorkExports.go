@@ -0,0 +1,134 @@
+package dataBase
+
+import (
+	"Cloud/apperror"
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// exportColumns — столбцы export_jobs в порядке сканирования scanExportJob
+const exportColumns = `id, status, format, filter, rows_done, rows_total, COALESCE(file_path, ''), COALESCE(file_size, 0), COALESCE(error, ''),
+	created_at, started_at, finished_at, expires_at`
+
+// DBCreateExportJob добавляет задание на выгрузку в очередь
+func DBCreateExportJob(ctx context.Context, db *sql.DB, job *models.ExportJob) error {
+	query := `INSERT INTO export_jobs (id, status, format, filter, rows_done, rows_total, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, 0, 0, now(), now()) RETURNING ` + exportColumns
+
+	saved, err := scanExportJob(db.QueryRowContext(ctx, query, job.ID, models.ExportQueued, job.Format, []byte(job.Filter)))
+	if err != nil {
+		return err
+	}
+	*job = *saved
+	return nil
+}
+
+// DBGetExportJob возвращает задание на выгрузку по ID
+func DBGetExportJob(ctx context.Context, db *sql.DB, id string) (*models.ExportJob, error) {
+	job, err := scanExportJob(db.QueryRowContext(ctx, `SELECT `+exportColumns+` FROM export_jobs WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.ErrExportNotFound
+	}
+	return job, err
+}
+
+// DBClaimExportJob забирает в работу самое старое задание из очереди.
+// Задания, которые выполнялись, но давно не сообщали о прогрессе (например, после падения процесса), запускаются заново.
+// Если заданий нет, возвращает nil без ошибки.
+func DBClaimExportJob(ctx context.Context, db *sql.DB, lease time.Duration) (*models.ExportJob, error) {
+	query := `UPDATE export_jobs SET status = $1, rows_done = 0, started_at = now(), updated_at = now()
+			  WHERE id = (
+				  SELECT id FROM export_jobs
+				  WHERE status = $2 OR (status = $1 AND updated_at < now() - $3 * interval '1 second')
+				  ORDER BY created_at
+				  LIMIT 1
+				  FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING ` + exportColumns
+
+	job, err := scanExportJob(db.QueryRowContext(ctx, query, models.ExportRunning, models.ExportQueued, int(lease.Seconds())))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
+
+// DBUpdateExportProgress сохраняет прогресс выгрузки; заодно служит признаком того, что обработчик жив
+func DBUpdateExportProgress(ctx context.Context, db *sql.DB, id string, rowsDone, rowsTotal int64) error {
+	_, err := db.ExecContext(ctx, `UPDATE export_jobs SET rows_done = $1, rows_total = $2, updated_at = now() WHERE id = $3`,
+		rowsDone, rowsTotal, id)
+	return err
+}
+
+// DBFinishExportJob отмечает выгрузку завершённой; файл хранится до expiresAt
+func DBFinishExportJob(ctx context.Context, db *sql.DB, id, filePath string, fileSize, rows int64, expiresAt time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE export_jobs SET status = $1, file_path = $2, file_size = $3, rows_done = $4, rows_total = $4,
+			  finished_at = now(), expires_at = $5, updated_at = now() WHERE id = $6`,
+		models.ExportDone, filePath, fileSize, rows, expiresAt, id)
+	return err
+}
+
+// DBFailExportJob отмечает выгрузку неудачной; запись о задании хранится до expiresAt
+func DBFailExportJob(ctx context.Context, db *sql.DB, id, message string, expiresAt time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE export_jobs SET status = $1, error = $2, finished_at = now(), expires_at = $3, updated_at = now() WHERE id = $4`,
+		models.ExportFailed, message, expiresAt, id)
+	return err
+}
+
+// DBExpireExportJobs отмечает истёкшими завершённые задания с прошедшим сроком хранения
+// и возвращает пути их файлов, которые нужно удалить
+func DBExpireExportJobs(ctx context.Context, db *sql.DB) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `UPDATE export_jobs SET status = $1, updated_at = now()
+			  WHERE status IN ($2, $3) AND expires_at < now()
+			  RETURNING COALESCE(file_path, '')`, models.ExportExpired, models.ExportDone, models.ExportFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, rows.Err()
+}
+
+func scanExportJob(row rowScanner) (*models.ExportJob, error) {
+	var job models.ExportJob
+	var filter []byte
+	var startedAt, finishedAt, expiresAt sql.NullTime
+
+	err := row.Scan(&job.ID, &job.Status, &job.Format, &filter, &job.RowsDone, &job.RowsTotal, &job.FilePath, &job.FileSize, &job.Error,
+		&job.CreatedAt, &startedAt, &finishedAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	job.Filter = filter
+	if startedAt.Valid {
+		job.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		job.FinishedAt = &finishedAt.Time
+	}
+	if expiresAt.Valid {
+		job.ExpiresAt = &expiresAt.Time
+	}
+	return &job, nil
+}
+
+// DBRequeueExportJob возвращает прерванное задание в очередь, например при остановке приложения
+func DBRequeueExportJob(ctx context.Context, db *sql.DB, id string) error {
+	_, err := db.ExecContext(ctx, `UPDATE export_jobs SET status = $1, rows_done = 0, started_at = NULL, updated_at = now() WHERE id = $2 AND status = $3`,
+		models.ExportQueued, id, models.ExportRunning)
+	return err
+}
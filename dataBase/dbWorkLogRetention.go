@@ -0,0 +1,128 @@
+package dataBase
+
+import (
+	"Cloud/models"
+	"context"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
+)
+
+// SecurityEventCollection возвращает коллекцию событий безопасности.
+// В неё попадают копии логов запросов, для которых RequestLog.IsSecurityEvent возвращает true; хранятся они дольше.
+func SecurityEventCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("Cloud").Collection("security_events")
+}
+
+// ttlIndexName — имя TTL-индекса по полю time
+const ttlIndexName = "time_ttl"
+
+// DBGetLogTTL возвращает expireAfterSeconds TTL-индекса по полю time; 0 — индекса нет
+func DBGetLogTTL(ctx context.Context, collection *mongo.Collection) (int64, error) {
+	cursor, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var index struct {
+			Name               string `bson:"name"`
+			ExpireAfterSeconds *int64 `bson:"expireAfterSeconds"`
+		}
+		if err := cursor.Decode(&index); err != nil {
+			return 0, err
+		}
+		if index.Name == ttlIndexName && index.ExpireAfterSeconds != nil {
+			return *index.ExpireAfterSeconds, nil
+		}
+	}
+	return 0, cursor.Err()
+}
+
+// DBEnsureLogTTL создаёт или изменяет TTL-индекс по полю time, чтобы MongoDB сама удаляла записи старше ttl.
+// ttl = 0 удаляет индекс — записи хранятся без ограничения.
+func DBEnsureLogTTL(ctx context.Context, collection *mongo.Collection, ttl time.Duration) error {
+	current, err := DBGetLogTTL(ctx, collection)
+	if err != nil {
+		return err
+	}
+	seconds := int64(ttl.Seconds())
+
+	switch {
+	case seconds == current:
+		return nil
+	case seconds == 0:
+		_, err := collection.Indexes().DropOne(ctx, ttlIndexName)
+		return err
+	case current == 0:
+		_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "time", Value: 1}},
+			Options: options.Index().SetName(ttlIndexName).SetExpireAfterSeconds(int32(seconds)),
+		})
+		return err
+	default:
+		// Срок существующего индекса меняется без пересоздания
+		return collection.Database().RunCommand(ctx, bson.D{
+			{Key: "collMod", Value: collection.Name()},
+			{Key: "index", Value: bson.M{"name": ttlIndexName, "expireAfterSeconds": seconds}},
+		}).Err()
+	}
+}
+
+// DBLogCollectionStats заполняет количество записей, размеры и время самой старой записи коллекции
+func DBLogCollectionStats(ctx context.Context, collection *mongo.Collection, stats *models.CollectionRetention) error {
+	pipeline := mongo.Pipeline{
+		{{Key: "$collStats", Value: bson.M{"storageStats": bson.M{}}}},
+	}
+
+	var rows []struct {
+		StorageStats struct {
+			Count          int64 `bson:"count"`
+			Size           int64 `bson:"size"`
+			StorageSize    int64 `bson:"storageSize"`
+			TotalIndexSize int64 `bson:"totalIndexSize"`
+		} `bson:"storageStats"`
+	}
+	err := aggregate(ctx, collection, pipeline, &rows)
+	// Коллекции ещё нет — записей тоже нет
+	var cmdErr mongo.CommandError
+	if errors.As(err, &cmdErr) && cmdErr.HasErrorCode(26) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		stats.Documents += row.StorageStats.Count
+		stats.SizeBytes += row.StorageStats.Size
+		stats.StorageBytes += row.StorageStats.StorageSize
+		stats.IndexBytes += row.StorageStats.TotalIndexSize
+	}
+
+	var oldest struct {
+		Time time.Time `bson:"time"`
+	}
+	err = collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"time": 1}).SetProjection(bson.M{"time": 1})).Decode(&oldest)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	stats.Oldest = &oldest.Time
+	return nil
+}
+
+// DBDeleteArchivedLogs удаляет записи старше before, уже сохранённые в архив.
+// Записи с _id больше maxID вставлены после архивации (например, перенесены из файла переполнения) и не удаляются.
+func DBDeleteArchivedLogs(ctx context.Context, collection *mongo.Collection, before time.Time, maxID primitive.ObjectID) (int64, error) {
+	result, err := collection.DeleteMany(ctx, bson.M{"time": bson.M{"$lt": before}, "_id": bson.M{"$lte": maxID}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
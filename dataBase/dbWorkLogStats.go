@@ -0,0 +1,224 @@
+package dataBase
+
+import (
+	"Cloud/models"
+	"context"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"strconv"
+)
+
+// Агрегации по логам запросов.
+// Используются $dateTrunc (MongoDB 5.0+) и $percentile (MongoDB 7.0+).
+
+// Интервалы группировки по времени
+const (
+	BucketMinute = "minute"
+	BucketHour   = "hour"
+	BucketDay    = "day"
+	BucketWeek   = "week"
+	BucketMonth  = "month"
+)
+
+// IsValidBucket проверяет, поддерживается ли интервал группировки
+func IsValidBucket(bucket string) bool {
+	switch bucket {
+	case BucketMinute, BucketHour, BucketDay, BucketWeek, BucketMonth:
+		return true
+	}
+	return false
+}
+
+// Поля, по которым строится топ
+const (
+	TopByUser = "userid"
+	TopByIP   = "ip"
+)
+
+// endpointKey группирует по шаблону маршрута, а для старых записей без него — по фактическому пути
+var endpointKey = bson.M{"$cond": bson.A{bson.M{"$gt": bson.A{"$route", ""}}, "$route", "$endpoint"}}
+
+// maxStatRows ограничивает размер результата агрегации по endpoint и интервалам
+const maxStatRows = 10000
+
+// DBRequestsByEndpoint считает запросы к каждому endpoint в каждом интервале bucket
+func DBRequestsByEndpoint(ctx context.Context, collection *mongo.Collection, filter RequestLogFilter, bucket string) ([]models.EndpointBucketStat, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter.BSON()}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{
+				"endpoint": endpointKey,
+				"bucket":   bson.M{"$dateTrunc": bson.M{"date": "$time", "unit": bucket}},
+			},
+			"requests": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.D{{Key: "_id.bucket", Value: 1}, {Key: "requests", Value: -1}}}},
+		{{Key: "$limit", Value: maxStatRows}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "endpoint": "$_id.endpoint", "bucket": "$_id.bucket", "requests": 1}}},
+	}
+
+	stats := make([]models.EndpointBucketStat, 0)
+	return stats, aggregate(ctx, collection, pipeline, &stats)
+}
+
+// DBLatencyPercentiles считает перцентили длительности запросов по каждому endpoint или по всем запросам сразу
+func DBLatencyPercentiles(ctx context.Context, collection *mongo.Collection, filter RequestLogFilter, byEndpoint bool) ([]models.LatencyStat, error) {
+	var groupID any
+	if byEndpoint {
+		groupID = endpointKey
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter.BSON()}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      groupID,
+			"requests": bson.M{"$sum": 1},
+			"avg_ms":   bson.M{"$avg": "$duration"},
+			"max_ms":   bson.M{"$max": "$duration"},
+			"p": bson.M{"$percentile": bson.M{
+				"input":  "$duration",
+				"p":      bson.A{0.5, 0.95, 0.99},
+				"method": "approximate",
+			}},
+		}}},
+		{{Key: "$sort", Value: bson.M{"requests": -1}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":      0,
+			"endpoint": bson.M{"$ifNull": bson.A{"$_id", ""}},
+			"requests": 1,
+			"avg_ms":   1,
+			"max_ms":   1,
+			"p50_ms":   bson.M{"$arrayElemAt": bson.A{"$p", 0}},
+			"p95_ms":   bson.M{"$arrayElemAt": bson.A{"$p", 1}},
+			"p99_ms":   bson.M{"$arrayElemAt": bson.A{"$p", 2}},
+		}}},
+	}
+
+	var rows []struct {
+		Endpoint string  `bson:"endpoint"`
+		Requests int64   `bson:"requests"`
+		AvgMs    float64 `bson:"avg_ms"`
+		P50Ms    float64 `bson:"p50_ms"`
+		P95Ms    float64 `bson:"p95_ms"`
+		P99Ms    float64 `bson:"p99_ms"`
+		MaxMs    float64 `bson:"max_ms"`
+	}
+	if err := aggregate(ctx, collection, pipeline, &rows); err != nil {
+		return nil, err
+	}
+
+	stats := make([]models.LatencyStat, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, models.LatencyStat(row))
+	}
+	return stats, nil
+}
+
+// DBStatusClasses считает ответы каждого класса (2xx, 4xx, 5xx и т.д.) и их долю от всех запросов
+func DBStatusClasses(ctx context.Context, collection *mongo.Collection, filter RequestLogFilter) ([]models.StatusClassStat, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: filter.BSON()}},
+		{{Key: "$group", Value: bson.M{
+			"_id":      bson.M{"$floor": bson.M{"$divide": bson.A{"$statuscode", 100}}},
+			"requests": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+	}
+
+	var rows []struct {
+		Class    int   `bson:"_id"`
+		Requests int64 `bson:"requests"`
+	}
+	if err := aggregate(ctx, collection, pipeline, &rows); err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, row := range rows {
+		total += row.Requests
+	}
+
+	stats := make([]models.StatusClassStat, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, models.StatusClassStat{
+			Class:    strconv.Itoa(row.Class) + "xx",
+			Requests: row.Requests,
+			Rate:     float64(row.Requests) / float64(total),
+		})
+	}
+	return stats, nil
+}
+
+// DBTopValues возвращает limit самых активных пользователей (TopByUser) или IP-адресов (TopByIP)
+func DBTopValues(ctx context.Context, collection *mongo.Collection, filter RequestLogFilter, field string, limit int) ([]models.TopStat, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: withNonEmpty(filter, field)}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + field, "requests": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "requests", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "value": "$_id", "requests": 1}}},
+	}
+
+	stats := make([]models.TopStat, 0, limit)
+	return stats, aggregate(ctx, collection, pipeline, &stats)
+}
+
+// DBUniqueUsersPerDay считает уникальных авторизованных пользователей за каждый день (UTC)
+func DBUniqueUsersPerDay(ctx context.Context, collection *mongo.Collection, filter RequestLogFilter) ([]models.DailyUsersStat, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: withNonEmpty(filter, TopByUser)}},
+		{{Key: "$group", Value: bson.M{"_id": bson.M{
+			"day":  bson.M{"$dateTrunc": bson.M{"date": "$time", "unit": BucketDay}},
+			"user": "$userid",
+		}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$_id.day", "users": bson.M{"$sum": 1}}}},
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "day": "$_id", "users": 1}}},
+	}
+
+	stats := make([]models.DailyUsersStat, 0)
+	return stats, aggregate(ctx, collection, pipeline, &stats)
+}
+
+// DBLogSummary собирает сводку для выгрузки: запросы по endpoint за дни, перцентили, классы ответов, топы и уникальных пользователей
+func DBLogSummary(ctx context.Context, collection *mongo.Collection, filter RequestLogFilter) (*models.LogSummary, error) {
+	var summary models.LogSummary
+	var err error
+
+	if summary.ByEndpoint, err = DBRequestsByEndpoint(ctx, collection, filter, BucketDay); err != nil {
+		return nil, err
+	}
+	if summary.Latency, err = DBLatencyPercentiles(ctx, collection, filter, true); err != nil {
+		return nil, err
+	}
+	if summary.StatusClasses, err = DBStatusClasses(ctx, collection, filter); err != nil {
+		return nil, err
+	}
+	if summary.TopUsers, err = DBTopValues(ctx, collection, filter, TopByUser, 20); err != nil {
+		return nil, err
+	}
+	if summary.TopIPs, err = DBTopValues(ctx, collection, filter, TopByIP, 20); err != nil {
+		return nil, err
+	}
+	if summary.UniqueUsers, err = DBUniqueUsersPerDay(ctx, collection, filter); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// withNonEmpty добавляет к фильтру условие, что поле field заполнено
+func withNonEmpty(filter RequestLogFilter, field string) bson.M {
+	return bson.M{"$and": bson.A{
+		filter.BSON(),
+		bson.M{field: bson.M{"$nin": bson.A{"", nil}}},
+	}}
+}
+
+// aggregate выполняет конвейер агрегации и декодирует все результаты в result
+func aggregate(ctx context.Context, collection *mongo.Collection, pipeline mongo.Pipeline, result any) error {
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.All(ctx, result)
+}
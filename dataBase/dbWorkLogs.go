@@ -0,0 +1,222 @@
+package dataBase
+
+import (
+	"Cloud/models"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// RequestLogCollection возвращает коллекцию логов запросов
+func RequestLogCollection(client *mongo.Client) *mongo.Collection {
+	return client.Database("Cloud").Collection("logs")
+}
+
+// RequestLogFilter — условия отбора логов запросов; пустые поля не ограничивают выборку
+type RequestLogFilter struct {
+	From            time.Time     `json:"from,omitempty"`         // не раньше (включительно)
+	To              time.Time     `json:"to,omitempty"`           // раньше (не включительно)
+	Method          string        `json:"method,omitempty"`       // HTTP-метод
+	EndpointPattern string        `json:"endpoint,omitempty"`     // путь; * соответствует любой последовательности символов, например /user/*
+	Route           string        `json:"route,omitempty"`        // шаблон маршрута, например /user/{id}
+	RequestID       string        `json:"request_id,omitempty"`   // идентификатор запроса
+	UserID          string        `json:"user_id,omitempty"`      // ID пользователя
+	StatusMin       int           `json:"status_min,omitempty"`   // минимальный код ответа (включительно)
+	StatusMax       int           `json:"status_max,omitempty"`   // максимальный код ответа (включительно)
+	IP              string        `json:"ip,omitempty"`           // IP-адрес клиента
+	MinDuration     time.Duration `json:"min_duration,omitempty"` // минимальная длительность запроса
+}
+
+// BSON строит условие запроса к MongoDB
+func (f RequestLogFilter) BSON() bson.M {
+	query := bson.M{}
+
+	if !f.From.IsZero() || !f.To.IsZero() {
+		timeRange := bson.M{}
+		if !f.From.IsZero() {
+			timeRange["$gte"] = f.From
+		}
+		if !f.To.IsZero() {
+			timeRange["$lt"] = f.To
+		}
+		query["time"] = timeRange
+	}
+	if f.Method != "" {
+		query["method"] = strings.ToUpper(f.Method)
+	}
+	if f.EndpointPattern != "" {
+		if strings.Contains(f.EndpointPattern, "*") {
+			pattern := strings.ReplaceAll(regexp.QuoteMeta(f.EndpointPattern), `\*`, `.*`)
+			query["endpoint"] = primitive.Regex{Pattern: "^" + pattern + "$"}
+		} else {
+			query["endpoint"] = f.EndpointPattern
+		}
+	}
+	if f.Route != "" {
+		query["route"] = f.Route
+	}
+	if f.RequestID != "" {
+		query["requestid"] = f.RequestID
+	}
+	if f.UserID != "" {
+		query["userid"] = f.UserID
+	}
+	if f.StatusMin != 0 || f.StatusMax != 0 {
+		statusRange := bson.M{}
+		if f.StatusMin != 0 {
+			statusRange["$gte"] = f.StatusMin
+		}
+		if f.StatusMax != 0 {
+			statusRange["$lte"] = f.StatusMax
+		}
+		query["statuscode"] = statusRange
+	}
+	if f.IP != "" {
+		// В старых записях адрес сохранён вместе с портом клиента
+		query["ip"] = primitive.Regex{Pattern: "^" + regexp.QuoteMeta(f.IP) + `(:\d+)?$`}
+	}
+	if f.MinDuration > 0 {
+		// Длительность хранится в миллисекундах
+		query["duration"] = bson.M{"$gte": float64(f.MinDuration) / float64(time.Millisecond)}
+	}
+
+	return query
+}
+
+// LogCursor — позиция в выдаче логов, отсортированной от новых к старым.
+// Курсор указывает на последнюю отданную запись; следующая страница начинается сразу после неё.
+type LogCursor struct {
+	Time time.Time          `json:"t"`
+	ID   primitive.ObjectID `json:"id"`
+}
+
+// ErrInvalidCursor — курсор повреждён или создан не этим API
+var ErrInvalidCursor = errors.New("некорректный курсор")
+
+// Encode кодирует курсор в непрозрачную строку для клиента
+func (c LogCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeLogCursor разбирает курсор, полученный от клиента
+func DecodeLogCursor(s string) (*LogCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c LogCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// DBFindRequestLogs возвращает до limit логов, подходящих под фильтр, начиная после cursor (nil — с самых новых).
+// Второе значение — курсор следующей страницы или nil, если записей больше нет.
+func DBFindRequestLogs(ctx context.Context, collection *mongo.Collection, filter RequestLogFilter, cursor *LogCursor, limit int) ([]models.RequestLog, *LogCursor, error) {
+	query := filter.BSON()
+	if cursor != nil {
+		// Записи старше курсора; при равном времени порядок определяет _id
+		query["$or"] = bson.A{
+			bson.M{"time": bson.M{"$lt": cursor.Time}},
+			bson.M{"time": cursor.Time, "_id": bson.M{"$lt": cursor.ID}},
+		}
+	}
+
+	// Запрашиваем на одну запись больше, чтобы понять, есть ли следующая страница
+	opts := options.Find().
+		SetSort(bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(limit + 1))
+
+	result, err := collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer result.Close(ctx)
+
+	logs := make([]models.RequestLog, 0, limit)
+	if err := result.All(ctx, &logs); err != nil {
+		return nil, nil, err
+	}
+
+	if len(logs) <= limit {
+		return logs, nil, nil
+	}
+	logs = logs[:limit]
+	last := logs[len(logs)-1]
+	return logs, &LogCursor{Time: last.Time, ID: last.ID}, nil
+}
+
+// EnsureRequestLogIndexes создаёт индексы для фильтров API логов.
+// Каждый индекс заканчивается полями сортировки, чтобы выборка с фильтром не требовала сортировки в памяти.
+func EnsureRequestLogIndexes(ctx context.Context, collection *mongo.Collection) error {
+	sortKeys := bson.E{Key: "time", Value: -1}
+	idKey := bson.E{Key: "_id", Value: -1}
+
+	indexes := []mongo.IndexModel{
+		{Keys: bson.D{sortKeys, idKey}},
+		{Keys: bson.D{{Key: "userid", Value: 1}, sortKeys, idKey}},
+		{Keys: bson.D{{Key: "endpoint", Value: 1}, sortKeys, idKey}},
+		{Keys: bson.D{{Key: "route", Value: 1}, sortKeys, idKey}},
+		{Keys: bson.D{{Key: "requestid", Value: 1}}},
+		{Keys: bson.D{{Key: "method", Value: 1}, sortKeys, idKey}},
+		{Keys: bson.D{{Key: "statuscode", Value: 1}, sortKeys, idKey}},
+		{Keys: bson.D{{Key: "ip", Value: 1}, sortKeys, idKey}},
+		{Keys: bson.D{{Key: "duration", Value: -1}, sortKeys}},
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// DBCountRequestLogs возвращает количество логов, подходящих под фильтр
+func DBCountRequestLogs(ctx context.Context, collection *mongo.Collection, filter RequestLogFilter) (int64, error) {
+	return collection.CountDocuments(ctx, filter.BSON())
+}
+
+// DBEachRequestLog проходит по всем логам, подходящим под фильтр, от новых к старым и вызывает fn для каждого.
+// Записи читаются из курсора пачками и не накапливаются в памяти; ошибка fn прекращает обход.
+func DBEachRequestLog(ctx context.Context, collection *mongo.Collection, filter RequestLogFilter, fn func(*models.RequestLog) error) error {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "time", Value: -1}, {Key: "_id", Value: -1}}).
+		SetBatchSize(1000)
+
+	cursor, err := collection.Find(ctx, filter.BSON(), opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var log models.RequestLog
+	for cursor.Next(ctx) {
+		log = models.RequestLog{}
+		if err := cursor.Decode(&log); err != nil {
+			return err
+		}
+		if err := fn(&log); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
+
+// DBInsertRequestLogs вставляет пачку логов одной командой InsertMany.
+// Вставка неупорядоченная, поэтому не останавливается на первой ошибке.
+func DBInsertRequestLogs(ctx context.Context, collection *mongo.Collection, batch []models.RequestLog) error {
+	docs := make([]any, len(batch))
+	for i := range batch {
+		docs[i] = batch[i]
+	}
+
+	_, err := collection.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	return err
+}
@@ -0,0 +1,67 @@
+package dataBase
+
+import (
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// LoginOrigin — чем текущий вход отличается от предыдущих входов пользователя
+type LoginOrigin struct {
+	FirstLogin bool // у пользователя ещё не было сохранённых входов
+	NewIP      bool // с этого IP-адреса пользователь ещё не входил
+	NewDevice  bool // с этого устройства (User-Agent) пользователь ещё не входил
+}
+
+// DBRecordLogin сохраняет устройство и IP-адрес, с которых выполнен вход (таблица user_devices),
+// и сообщает, встречались ли они раньше
+func DBRecordLogin(ctx context.Context, db *sql.DB, userID int, ip, userAgent string) (LoginOrigin, error) {
+	var origin LoginOrigin
+	var hasHistory, knownIP, knownDevice bool
+
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) > 0, COALESCE(bool_or(ip = $2), false), COALESCE(bool_or(user_agent = $3), false)
+			  FROM user_devices WHERE user_id = $1`, userID, ip, userAgent).Scan(&hasHistory, &knownIP, &knownDevice)
+	if err != nil {
+		return origin, err
+	}
+
+	_, err = db.ExecContext(ctx, `INSERT INTO user_devices (user_id, ip, user_agent, first_seen_at, last_seen_at)
+			  VALUES ($1, $2, $3, now(), now())
+			  ON CONFLICT (user_id, ip, user_agent) DO UPDATE SET last_seen_at = now()`, userID, ip, userAgent)
+	if err != nil {
+		return origin, err
+	}
+
+	origin.FirstLogin = !hasHistory
+	origin.NewIP = !knownIP
+	origin.NewDevice = !knownDevice
+	return origin, nil
+}
+
+// DBGetNotificationPreferences возвращает настройки уведомлений пользователя (таблица notification_preferences).
+// Если пользователь их не менял, возвращаются настройки по умолчанию.
+func DBGetNotificationPreferences(ctx context.Context, db *sql.DB, userID int) (*models.NotificationPreferences, error) {
+	prefs := models.DefaultNotificationPreferences(userID)
+
+	err := db.QueryRowContext(ctx, `SELECT new_login, password_change, email_change, account_ban, account_deletion
+			  FROM notification_preferences WHERE user_id = $1`, userID).
+		Scan(&prefs.NewLogin, &prefs.PasswordChange, &prefs.EmailChange, &prefs.AccountBan, &prefs.AccountDeletion)
+	if errors.Is(err, sql.ErrNoRows) {
+		return prefs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// DBSaveNotificationPreferences сохраняет настройки уведомлений пользователя
+func DBSaveNotificationPreferences(ctx context.Context, db *sql.DB, prefs *models.NotificationPreferences) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO notification_preferences (user_id, new_login, password_change, email_change, account_ban, account_deletion, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, now())
+			  ON CONFLICT (user_id) DO UPDATE SET new_login = $2, password_change = $3, email_change = $4,
+			      account_ban = $5, account_deletion = $6, updated_at = now()`,
+		prefs.UserID, prefs.NewLogin, prefs.PasswordChange, prefs.EmailChange, prefs.AccountBan, prefs.AccountDeletion)
+	return err
+}
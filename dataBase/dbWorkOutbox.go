@@ -0,0 +1,145 @@
+package dataBase
+
+import (
+	"Cloud/apperror"
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// outboxColumns — столбцы email_outbox в порядке сканирования scanOutboxMessage
+const outboxColumns = `id, sender, recipient, subject, body_text, body_html, status, attempts, max_attempts, next_attempt_at, COALESCE(last_error, ''), created_at, updated_at, sent_at`
+
+// DBEnqueueEmail добавляет письмо в очередь исходящей почты
+func DBEnqueueEmail(ctx context.Context, db *sql.DB, msg *models.OutboxMessage) error {
+	query := `INSERT INTO email_outbox (sender, recipient, subject, body_text, body_html, status, attempts, max_attempts, next_attempt_at, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6, 0, $7, $8, now(), now()) RETURNING id, created_at, updated_at`
+
+	return db.QueryRowContext(ctx, query, msg.From, msg.Recipient, msg.Subject, msg.Text, msg.HTML, models.OutboxPending, msg.MaxAttempts, msg.NextAttemptAt).
+		Scan(&msg.ID, &msg.CreatedAt, &msg.UpdatedAt)
+}
+
+// DBClaimOutboxBatch забирает в работу до limit писем, готовых к отправке.
+// Письма, зависшие в статусе sending дольше lease (например, после падения процесса), забираются повторно.
+// FOR UPDATE SKIP LOCKED позволяет нескольким экземплярам приложения разбирать очередь без дублей.
+func DBClaimOutboxBatch(ctx context.Context, db *sql.DB, limit int, lease time.Duration) ([]*models.OutboxMessage, error) {
+	query := `UPDATE email_outbox SET status = $1, locked_until = now() + $2 * interval '1 second', updated_at = now()
+			  WHERE id IN (
+				  SELECT id FROM email_outbox
+				  WHERE (status = $3 AND next_attempt_at <= now())
+				     OR (status = $1 AND locked_until < now())
+				  ORDER BY next_attempt_at
+				  LIMIT $4
+				  FOR UPDATE SKIP LOCKED
+			  )
+			  RETURNING ` + outboxColumns
+
+	rows, err := db.QueryContext(ctx, query, models.OutboxSending, int(lease.Seconds()), models.OutboxPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanOutboxMessages(rows)
+}
+
+// DBMarkOutboxSent отмечает письмо как отправленное
+func DBMarkOutboxSent(ctx context.Context, db *sql.DB, id int64) error {
+	_, err := db.ExecContext(ctx, `UPDATE email_outbox SET status = $1, attempts = attempts + 1, sent_at = now(), last_error = NULL, locked_until = NULL, updated_at = now() WHERE id = $2`,
+		models.OutboxSent, id)
+	return err
+}
+
+// DBMarkOutboxFailed записывает неудачную попытку: письмо либо ждёт следующей попытки, либо уходит в dead
+func DBMarkOutboxFailed(ctx context.Context, db *sql.DB, id int64, status string, nextAttemptAt time.Time, lastError string) error {
+	_, err := db.ExecContext(ctx, `UPDATE email_outbox SET status = $1, attempts = attempts + 1, next_attempt_at = $2, last_error = $3, locked_until = NULL, updated_at = now() WHERE id = $4`,
+		status, nextAttemptAt, lastError, id)
+	return err
+}
+
+// DBDeferOutbox откладывает письмо без увеличения счётчика попыток (например, из-за ограничения частоты)
+func DBDeferOutbox(ctx context.Context, db *sql.DB, id int64, nextAttemptAt time.Time) error {
+	_, err := db.ExecContext(ctx, `UPDATE email_outbox SET status = $1, next_attempt_at = $2, locked_until = NULL, updated_at = now() WHERE id = $3`,
+		models.OutboxPending, nextAttemptAt, id)
+	return err
+}
+
+// DBListOutbox возвращает письма из очереди с фильтром по статусу (пустой статус — все письма)
+func DBListOutbox(ctx context.Context, db *sql.DB, status string, limit, offset int) ([]*models.OutboxMessage, error) {
+	query := `SELECT ` + outboxColumns + ` FROM email_outbox WHERE ($1 = '' OR status = $1) ORDER BY id DESC LIMIT $2 OFFSET $3`
+
+	rows, err := db.QueryContext(ctx, query, status, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanOutboxMessages(rows)
+}
+
+// DBGetOutboxMessage возвращает письмо из очереди по ID
+func DBGetOutboxMessage(ctx context.Context, db *sql.DB, id int64) (*models.OutboxMessage, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+outboxColumns+` FROM email_outbox WHERE id = $1`, id)
+
+	msg, err := scanOutboxMessage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.ErrOutboxMessageNotFound
+	}
+	return msg, err
+}
+
+// DBRetryOutboxMessage возвращает неотправленное письмо в очередь с обнулённым счётчиком попыток
+func DBRetryOutboxMessage(ctx context.Context, db *sql.DB, id int64) error {
+	result, err := db.ExecContext(ctx, `UPDATE email_outbox SET status = $1, attempts = 0, next_attempt_at = now(), last_error = NULL, locked_until = NULL, updated_at = now()
+			  WHERE id = $2 AND status <> $3`, models.OutboxPending, id, models.OutboxSent)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		// Письма нет либо оно уже отправлено
+		if _, err := DBGetOutboxMessage(ctx, db, id); err != nil {
+			return err
+		}
+		return apperror.ErrOutboxAlreadySent
+	}
+	return nil
+}
+
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanOutboxMessage(row rowScanner) (*models.OutboxMessage, error) {
+	var msg models.OutboxMessage
+	var sentAt sql.NullTime
+
+	err := row.Scan(&msg.ID, &msg.From, &msg.Recipient, &msg.Subject, &msg.Text, &msg.HTML, &msg.Status, &msg.Attempts, &msg.MaxAttempts,
+		&msg.NextAttemptAt, &msg.LastError, &msg.CreatedAt, &msg.UpdatedAt, &sentAt)
+	if err != nil {
+		return nil, err
+	}
+	if sentAt.Valid {
+		msg.SentAt = &sentAt.Time
+	}
+	return &msg, nil
+}
+
+func scanOutboxMessages(rows *sql.Rows) ([]*models.OutboxMessage, error) {
+	messages := make([]*models.OutboxMessage, 0)
+	for rows.Next() {
+		msg, err := scanOutboxMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
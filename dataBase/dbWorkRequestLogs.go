@@ -0,0 +1,56 @@
+package dataBase
+
+import (
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// requestLogColumns — столбцы таблицы request_logs в порядке requestLogValues
+const requestLogColumns = `request_id, method, endpoint, route, query, user_id, ip, user_agent, time, status_code, error_code, bytes_in, bytes_out, duration_ms, security_event`
+
+// requestLogValues возвращает значения столбцов request_logs для записи
+func requestLogValues(log *models.RequestLog) []any {
+	return []any{log.RequestID, log.Method, log.Endpoint, log.Route, log.Query, log.UserID, log.IP, log.UserAgent, log.Time,
+		log.StatusCode, log.ErrorCode, log.BytesIn, log.BytesOut, log.DurationMs, log.IsSecurityEvent()}
+}
+
+// DBInsertRequestLogsSQL вставляет пачку логов запросов в таблицу request_logs одной командой INSERT
+func DBInsertRequestLogsSQL(ctx context.Context, db *sql.DB, batch []models.RequestLog) error {
+	if len(batch) == 0 {
+		return nil
+	}
+
+	// PostgreSQL принимает не больше 65535 параметров в одной команде
+	columns := strings.Count(requestLogColumns, ",") + 1
+	if maxRows := 65535 / columns; len(batch) > maxRows {
+		if err := DBInsertRequestLogsSQL(ctx, db, batch[:maxRows]); err != nil {
+			return err
+		}
+		return DBInsertRequestLogsSQL(ctx, db, batch[maxRows:])
+	}
+
+	args := make([]any, 0, len(batch)*columns)
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO request_logs (` + requestLogColumns + `) VALUES `)
+	for i := range batch {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		query.WriteString("(")
+		for j := 0; j < columns; j++ {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteString("$" + strconv.Itoa(i*columns+j+1))
+		}
+		query.WriteString(")")
+		args = append(args, requestLogValues(&batch[i])...)
+	}
+
+	_, err := db.ExecContext(ctx, query.String(), args...)
+	return err
+}
@@ -0,0 +1,93 @@
+package dataBase
+
+import (
+	"Cloud/apperror"
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// suppressionColumns — столбцы email_suppressions в порядке сканирования scanSuppression
+const suppressionColumns = `email, reason, COALESCE(detail, ''), COALESCE(source, ''), event_count, created_at, updated_at`
+
+// normalizeEmail приводит адрес к виду, в котором он хранится в списке подавления
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// DBAddSuppression добавляет адрес в список подавления.
+// Повторное событие по тому же адресу обновляет причину и увеличивает счётчик событий.
+func DBAddSuppression(ctx context.Context, db *sql.DB, s *models.Suppression) error {
+	query := `INSERT INTO email_suppressions (email, reason, detail, source, event_count, created_at, updated_at)
+			  VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), 1, now(), now())
+			  ON CONFLICT (email) DO UPDATE SET reason = $2, detail = NULLIF($3, ''), source = NULLIF($4, ''),
+			      event_count = email_suppressions.event_count + 1, updated_at = now()
+			  RETURNING ` + suppressionColumns
+
+	saved, err := scanSuppression(db.QueryRowContext(ctx, query, normalizeEmail(s.Email), s.Reason, s.Detail, s.Source))
+	if err != nil {
+		return err
+	}
+	*s = *saved
+	return nil
+}
+
+// DBGetSuppression возвращает запись списка подавления; ErrSuppressionNotFound, если адреса в списке нет
+func DBGetSuppression(ctx context.Context, db *sql.DB, email string) (*models.Suppression, error) {
+	row := db.QueryRowContext(ctx, `SELECT `+suppressionColumns+` FROM email_suppressions WHERE email = $1`, normalizeEmail(email))
+
+	s, err := scanSuppression(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.ErrSuppressionNotFound
+	}
+	return s, err
+}
+
+// DBListSuppressions возвращает записи списка подавления с фильтром по причине (пустая причина — все записи)
+func DBListSuppressions(ctx context.Context, db *sql.DB, reason string, limit, offset int) ([]*models.Suppression, error) {
+	query := `SELECT ` + suppressionColumns + ` FROM email_suppressions WHERE ($1 = '' OR reason = $1) ORDER BY updated_at DESC LIMIT $2 OFFSET $3`
+
+	rows, err := db.QueryContext(ctx, query, reason, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suppressions := make([]*models.Suppression, 0)
+	for rows.Next() {
+		s, err := scanSuppression(rows)
+		if err != nil {
+			return nil, err
+		}
+		suppressions = append(suppressions, s)
+	}
+	return suppressions, rows.Err()
+}
+
+// DBDeleteSuppression убирает адрес из списка подавления
+func DBDeleteSuppression(ctx context.Context, db *sql.DB, email string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM email_suppressions WHERE email = $1`, normalizeEmail(email))
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return apperror.ErrSuppressionNotFound
+	}
+	return nil
+}
+
+func scanSuppression(row rowScanner) (*models.Suppression, error) {
+	var s models.Suppression
+	err := row.Scan(&s.Email, &s.Reason, &s.Detail, &s.Source, &s.EventCount, &s.CreatedAt, &s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
@@ -1,16 +1,35 @@
 package dataBase
 
 import (
+	"Cloud/apperror"
 	"Cloud/logger"
 	"Cloud/models"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/lib/pq"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// DBCreateUser создает нового пользователя в базе данных.
+// PostgresUserRepository — UserRepository поверх PostgreSQL (таблица users)
+type PostgresUserRepository struct {
+	db     dbtx
+	reader dbtx // для списков и поиска; может отставать от db
+}
+
+// NewPostgresUserRepository создаёт PostgresUserRepository. Списки пользователей читаются с replica;
+// nil — с основной базы db.
+func NewPostgresUserRepository(db, replica *sql.DB) *PostgresUserRepository {
+	if replica == nil {
+		replica = db
+	}
+	return &PostgresUserRepository{db: db, reader: replica}
+}
+
+// Create создает нового пользователя в базе данных.
 // @Summary Create a new user
 // @Description Adds a new user to the database with the provided details.
 // @Accept json
@@ -19,16 +38,16 @@ import (
 // @Success 201 {object} models.User
 // @Failure 400 {object} ErrorResponse
 // @Router /user [post]
-func DBCreateUser(db *sql.DB, user *models.User) error {
-	query := `INSERT INTO users (name, phone, email, password, from_date_create, from_date_update) 
-			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+func (r *PostgresUserRepository) Create(ctx context.Context, user *models.User) error {
+	query := `INSERT INTO users (name, phone, email, password, from_date_create, from_date_update, locale) 
+			  VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')) RETURNING id`
 
-	err := db.QueryRow(query, user.Name, user.Phone, user.Email, user.Password, user.FromDateCreate, user.FromDateUpdate).Scan(&user.ID)
+	err := r.db.QueryRowContext(ctx, query, user.Name, user.Phone, user.Email, user.Password, user.FromDateCreate, user.FromDateUpdate, user.Locale).Scan(&user.ID)
 
-	return err
+	return userConflict(err)
 }
 
-// DBGetUser получает пользователя по его ID из базы данных.
+// Get получает пользователя по его ID из базы данных.
 // @Summary Get user by ID
 // @Description Retrieves a user from the database by their ID.
 // @Accept json
@@ -37,19 +56,22 @@ func DBCreateUser(db *sql.DB, user *models.User) error {
 // @Success 200 {object} models.User
 // @Failure 404 {object} ErrorResponse
 // @Router /user/{id} [get]
-func DBGetUser(db *sql.DB, userID int) (*models.User, error) {
+func (r *PostgresUserRepository) Get(ctx context.Context, userID int) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, name, phone, email, password, from_date_create, from_date_update, is_deleted, is_banned FROM users WHERE id = $1`
+	query := `SELECT id, name, phone, email, password, from_date_create, from_date_update, is_deleted, is_banned, COALESCE(locale, ''), COALESCE(role, 'user'), version FROM users WHERE id = $1`
 
-	err := db.QueryRow(query, userID).Scan(&user.ID, &user.Name, &user.Phone, &user.Email, &user.Password, &user.FromDateCreate, &user.FromDateUpdate, &user.IsDeleted, &user.IsBanned)
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(&user.ID, &user.Name, &user.Phone, &user.Email, &user.Password, &user.FromDateCreate, &user.FromDateUpdate, &user.IsDeleted, &user.IsBanned, &user.Locale, &user.Role, &user.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperror.ErrUserNotFound
+	}
 	if err != nil {
-		logger.Error("Failed to retrieve data from the database!" + err.Error())
+		logger.ErrorContext(ctx, "Не удалось получить пользователя из базы данных", "target_id", userID, "error", err.Error())
 		return nil, err
 	}
 	return &user, nil
 }
 
-// DBGetAllUsers получает всех пользователей с учетом фильтров, лимита и смещения.
+// List получает всех пользователей с учетом фильтров, лимита и смещения.
 // @Summary Get all users
 // @Description Retrieves all users from the database with optional filters, limit, and offset.
 // @Accept json
@@ -60,9 +82,9 @@ func DBGetUser(db *sql.DB, userID int) (*models.User, error) {
 // @Success 200 {array} models.User
 // @Failure 500 {object} ErrorResponse
 // @Router /users [get]
-func DBGetAllUsers(db *sql.DB, filters map[string]string, limit, offset int) ([]*models.User, error) {
+func (r *PostgresUserRepository) List(ctx context.Context, filters map[string]string, limit, offset int) ([]*models.User, error) {
 	// Базовый SQL-запрос
-	query := `SELECT id, name, phone, email, password, from_date_create, from_date_update, is_deleted, is_banned FROM users WHERE TRUE`
+	query := `SELECT id, name, phone, email, password, from_date_create, from_date_update, is_deleted, is_banned, COALESCE(locale, ''), COALESCE(role, 'user'), version FROM users WHERE TRUE`
 	args := []interface{}{}
 	counter := 1
 
@@ -80,9 +102,9 @@ func DBGetAllUsers(db *sql.DB, filters map[string]string, limit, offset int) ([]
 	args = append(args, limit, offset)
 
 	// Выполняем запрос
-	rows, err := db.Query(query, args...)
+	rows, err := r.reader.QueryContext(ctx, query, args...)
 	if err != nil {
-		logger.Error("Failed to retrieve data from the database!" + err.Error())
+		logger.ErrorContext(ctx, "Не удалось получить список пользователей из базы данных", "error", err.Error())
 		return nil, err
 	}
 	defer rows.Close()
@@ -90,8 +112,8 @@ func DBGetAllUsers(db *sql.DB, filters map[string]string, limit, offset int) ([]
 	users := make([]*models.User, 0)
 	for rows.Next() {
 		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Phone, &user.Email, &user.Password, &user.FromDateCreate, &user.FromDateUpdate, &user.IsDeleted, &user.IsBanned); err != nil {
-			logger.Error("Failed to retrieve data from the database!" + err.Error())
+		if err := rows.Scan(&user.ID, &user.Name, &user.Phone, &user.Email, &user.Password, &user.FromDateCreate, &user.FromDateUpdate, &user.IsDeleted, &user.IsBanned, &user.Locale, &user.Role, &user.Version); err != nil {
+			logger.ErrorContext(ctx, "Не удалось прочитать пользователя из списка", "error", err.Error())
 			return nil, err
 		}
 		users = append(users, &user)
@@ -99,14 +121,14 @@ func DBGetAllUsers(db *sql.DB, filters map[string]string, limit, offset int) ([]
 
 	// Проверка на ошибки после завершения чтения
 	if err := rows.Err(); err != nil {
-		logger.Error("Failed to retrieve data from the database!" + err.Error())
+		logger.ErrorContext(ctx, "Не удалось получить список пользователей из базы данных", "error", err.Error())
 		return nil, err
 	}
 
 	return users, nil
 }
 
-// DBUpdateUser обновляет данные о пользователе в базе данных.
+// Update обновляет данные о пользователе в базе данных.
 // @Summary Update user
 // @Description Updates the user details in the database.
 // @Accept json
@@ -115,7 +137,11 @@ func DBGetAllUsers(db *sql.DB, filters map[string]string, limit, offset int) ([]
 // @Success 204
 // @Failure 400 {object} ErrorResponse
 // @Router /user [put]
-func DBUpdateUser(db *sql.DB, user *models.User) error {
+func (r *PostgresUserRepository) Update(ctx context.Context, user *models.User) error {
+	if err := requireVersion(user.ID, user.Version); err != nil {
+		return err
+	}
+
 	query := `UPDATE users SET `
 	args := []interface{}{}
 	setClauses := []string{}
@@ -137,6 +163,10 @@ func DBUpdateUser(db *sql.DB, user *models.User) error {
 		setClauses = append(setClauses, "password=$"+strconv.Itoa(len(args)+1))
 		args = append(args, user.Password)
 	}
+	if user.Locale != "" {
+		setClauses = append(setClauses, "locale=$"+strconv.Itoa(len(args)+1))
+		args = append(args, user.Locale)
+	}
 
 	setClauses = append(setClauses, "from_date_update=$"+strconv.Itoa(len(args)+1))
 	args = append(args, user.FromDateUpdate)
@@ -151,19 +181,91 @@ func DBUpdateUser(db *sql.DB, user *models.User) error {
 		args = append(args, user.IsBanned)
 	}
 
-	if len(setClauses) == 0 {
-		return nil // Ничего не обновлено
-	}
+	// Любое изменение увеличивает версию и применяется только к версии, которую видел вызывающий
+	setClauses = append(setClauses, "version = version + 1")
 
 	// Создаём запрос UPDATE
-	query += strings.Join(setClauses, ", ") + " WHERE id = $" + strconv.Itoa(len(args)+1)
-	args = append(args, user.ID)
+	query += strings.Join(setClauses, ", ") + " WHERE id = $" + strconv.Itoa(len(args)+1) + " AND version = $" + strconv.Itoa(len(args)+2)
+	args = append(args, user.ID, user.Version)
+	query += " RETURNING version"
 
-	_, err := db.Exec(query, args...)
-	return err
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.updateMissed(ctx, user.ID, user.Version)
+	}
+	return userConflict(err)
+}
+
+// Patch изменяет поля пользователя, заданные в patch, включая пустые строки и false
+func (r *PostgresUserRepository) Patch(ctx context.Context, patch *models.UserPatch) error {
+	if err := requireVersion(patch.ID, patch.Version); err != nil {
+		return err
+	}
+
+	args := []interface{}{}
+	setClauses := []string{}
+	set := func(column string, value any) {
+		args = append(args, value)
+		setClauses = append(setClauses, column+" = $"+strconv.Itoa(len(args)))
+	}
+
+	if patch.Name != nil {
+		set("name", *patch.Name)
+	}
+	if patch.Phone != nil {
+		set("phone", *patch.Phone)
+	}
+	if patch.Email != nil {
+		set("email", *patch.Email)
+	}
+	if patch.Password != nil {
+		set("password", *patch.Password)
+	}
+	if patch.Locale != nil {
+		args = append(args, *patch.Locale)
+		setClauses = append(setClauses, "locale = NULLIF($"+strconv.Itoa(len(args))+", '')")
+	}
+	if patch.IsDeleted != nil {
+		set("is_deleted", *patch.IsDeleted)
+	}
+	if patch.IsBanned != nil {
+		set("is_banned", *patch.IsBanned)
+	}
+	set("from_date_update", patch.FromDateUpdate)
+	setClauses = append(setClauses, "version = version + 1")
+
+	query := `UPDATE users SET ` + strings.Join(setClauses, ", ") + " WHERE id = $" + strconv.Itoa(len(args)+1) +
+		" AND version = $" + strconv.Itoa(len(args)+2) + " RETURNING version"
+	args = append(args, patch.ID, patch.Version)
+
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(&patch.Version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return r.updateMissed(ctx, patch.ID, patch.Version)
+	}
+	return userConflict(err)
 }
 
-// DBDeleteUser удаляет пользователя из базы данных по его ID.
+// requireVersion запрещает изменение без версии: без неё запись перезаписала бы чужие правки вслепую
+func requireVersion(userID int, version int64) error {
+	if version == 0 {
+		return apperror.ErrPreconditionRequired.Wrap(fmt.Errorf("не указана версия пользователя %d", userID))
+	}
+	return nil
+}
+
+// updateMissed объясняет, почему изменение не затронуло ни одной строки: пользователя нет или его версия уже другая
+func (r *PostgresUserRepository) updateMissed(ctx context.Context, userID int, version int64) error {
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return apperror.ErrUserNotFound
+	}
+	return apperror.ErrPreconditionFailed.Wrap(fmt.Errorf("версия пользователя %d изменилась, ожидалась %d", userID, version))
+}
+
+// Delete удаляет пользователя из базы данных по его ID.
 // @Summary Delete user
 // @Description Deletes a user from the database by their ID.
 // @Accept json
@@ -172,97 +274,171 @@ func DBUpdateUser(db *sql.DB, user *models.User) error {
 // @Success 204
 // @Failure 404 {object} ErrorResponse
 // @Router /user/{id} [delete]
-func DBDeleteUser(db *sql.DB, userID int) error {
-	query := `UPDATE users SET is_deleted = true WHERE id = $1`
+func (r *PostgresUserRepository) Delete(ctx context.Context, userID int) error {
+	query := `UPDATE users SET is_deleted = true, version = version + 1 WHERE id = $1`
 
-	_, err := db.Exec(query, userID)
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
 
-	return err
+	return checkUserAffected(result)
 }
 
-func FindUserByEmail(db *sql.DB, email string) (*models.User, string, error) {
-	var user models.User
-	query := `SELECT id, name, phone, email, password, from_date_create, from_date_update, is_deleted, is_banned FROM users WHERE email = $1`
-
-	err := db.QueryRow(query, email).Scan(&user.ID, &user.Name, &user.Phone, &user.Email, &user.Password, &user.FromDateCreate, &user.FromDateUpdate, &user.IsDeleted, &user.IsBanned)
-
-	// Проверка на ошибку запроса
+// checkUserAffected возвращает ErrUserNotFound, если запрос не затронул ни одной строки
+func checkUserAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// Пользователь с таким email не найден
-			logger.Error("Пользователь с данным email не найден: " + email)
-			return nil, "Пользователь с данным email не найден", nil
-		}
-		// Другая ошибка базы данных
-		logger.Error("Ошибка базы данных: " + err.Error())
-		return nil, "", err
+		return err
+	}
+	if affected == 0 {
+		return apperror.ErrUserNotFound
 	}
+	return nil
+}
 
-	// Проверка на заблокированного или удалённого пользователя
-	if user.IsBanned {
-		logger.Info("Пользователь заблокирован: " + email)
-		return nil, "Пользователь заблокирован", nil
+// userConflict переводит нарушение уникальных индексов email и телефона в ErrEmailTaken и ErrPhoneTaken;
+// остальные ошибки возвращает без изменений
+func userConflict(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" { // unique_violation
+		return err
 	}
-	if user.IsDeleted {
-		logger.Info("Пользователь удалён: " + email)
-		return nil, "Пользователь удалён", nil
+	switch pqErr.Constraint {
+	case "users_email_unique_idx":
+		return apperror.ErrEmailTaken.Wrap(err)
+	case "users_phone_unique_idx":
+		return apperror.ErrPhoneTaken.Wrap(err)
 	}
+	return err
+}
 
-	// Если пользователь не заблокирован и не удалён, вернуть его данные
-	return &user, "", nil
+// EmailTaken проверяет, занят ли email. Читает основную базу: реплика может ещё не знать о только что созданном пользователе.
+func (r *PostgresUserRepository) EmailTaken(ctx context.Context, email string) (bool, string, error) {
+	var locale string
+	err := r.db.QueryRowContext(ctx, `SELECT COALESCE(locale, '') FROM users WHERE lower(email) = lower($1) AND email <> ''`, email).Scan(&locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, "", nil
+	}
+	return err == nil, locale, err
 }
 
-func FindUserByPhone(db *sql.DB, phone string) (*models.User, string, error) {
+// PhoneOwner возвращает email и язык владельца номера телефона. Как и EmailTaken, читает основную базу.
+func (r *PostgresUserRepository) PhoneOwner(ctx context.Context, phone string) (string, string, error) {
+	var email, locale string
+	err := r.db.QueryRowContext(ctx, `SELECT email, COALESCE(locale, '') FROM users
+			  WHERE regexp_replace(phone, '[^0-9]', '', 'g') = regexp_replace($1, '[^0-9]', '', 'g') AND phone <> ''`, phone).Scan(&email, &locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", nil
+	}
+	return email, locale, err
+}
 
+// FindByEmail ищет активного пользователя по email без учёта регистра.
+// Возвращает ErrUserNotFound, ErrUserBanned или ErrUserDeleted, если войти под этим пользователем нельзя.
+func (r *PostgresUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	query := `SELECT id, name, phone, email, password, from_date_create, from_date_update, is_deleted, is_banned, COALESCE(locale, ''), COALESCE(role, 'user'), version FROM users WHERE lower(email) = lower($1) AND email <> ''`
+	return r.findActive(ctx, query, email)
+}
+
+// FindByPhone ищет активного пользователя по цифрам номера телефона.
+// Возвращает ErrUserNotFound, ErrUserBanned или ErrUserDeleted, если войти под этим пользователем нельзя.
+func (r *PostgresUserRepository) FindByPhone(ctx context.Context, phone string) (*models.User, error) {
+	query := `SELECT id, name, phone, email, password, from_date_create, from_date_update, is_deleted, is_banned, COALESCE(locale, ''), COALESCE(role, 'user'), version FROM users
+			  WHERE regexp_replace(phone, '[^0-9]', '', 'g') = regexp_replace($1, '[^0-9]', '', 'g') AND phone <> ''`
+	return r.findActive(ctx, query, phone)
+}
+
+// findActive выполняет поиск пользователя и проверяет, не заблокирован ли он и не удалён ли
+func (r *PostgresUserRepository) findActive(ctx context.Context, query, value string) (*models.User, error) {
 	var user models.User
-	query := `SELECT id, name, phone, email, password, from_date_create, from_date_update, is_deleted, is_banned FROM users WHERE phone = $1`
 
-	err := db.QueryRow(query, phone).Scan(&user.ID, &user.Name, &user.Phone, &user.Email, &user.Password, &user.FromDateCreate, &user.FromDateUpdate, &user.IsDeleted, &user.IsBanned)
+	err := r.db.QueryRowContext(ctx, query, value).Scan(&user.ID, &user.Name, &user.Phone, &user.Email, &user.Password, &user.FromDateCreate, &user.FromDateUpdate, &user.IsDeleted, &user.IsBanned, &user.Locale, &user.Role, &user.Version)
 
 	// Проверка на ошибку запроса
 	if err != nil {
-		if err == sql.ErrNoRows {
-			// Пользователь с таким phone не найден
-			logger.Error("Пользователь с данным phone не найден: " + phone)
-			return nil, "Пользователь с данным phone не найден", nil
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.InfoContext(ctx, "Пользователь не найден", "login", value)
+			return nil, apperror.ErrUserNotFound
 		}
 		// Другая ошибка базы данных
-		logger.Error("Ошибка базы данных: " + err.Error())
-		return nil, "", err
+		logger.ErrorContext(ctx, "Ошибка поиска пользователя в базе данных", "error", err.Error())
+		return nil, err
 	}
 
 	// Проверка на заблокированного или удалённого пользователя
 	if user.IsBanned {
-		logger.Info("Пользователь заблокирован: " + phone)
-		return nil, "Пользователь заблокирован", nil
+		logger.InfoContext(ctx, "Пользователь заблокирован", "login", value)
+		return nil, apperror.ErrUserBanned
 	}
 	if user.IsDeleted {
-		logger.Info("Пользователь удалён: " + phone)
-		return nil, "Пользователь удалён", nil
+		logger.InfoContext(ctx, "Пользователь удалён", "login", value)
+		return nil, apperror.ErrUserDeleted
 	}
 
 	// Если пользователь не заблокирован и не удалён, вернуть его данные
-	return &user, "", nil
+	return &user, nil
 }
 
-// Изменение времени истечения токена
-func UpdateTokenExpiration(db *sql.DB, expirationTime time.Time, userID int) error {
+// UpdateTokenExpiration изменяет время истечения токена
+func (r *PostgresUserRepository) UpdateTokenExpiration(ctx context.Context, userID int, expirationTime time.Time) error {
 
-	_, err := db.Exec("UPDATE users SET token_expires_at = $1 WHERE id = $2", expirationTime, userID)
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET token_expires_at = $1 WHERE id = $2", expirationTime, userID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// Получение времени истечения токена из базы данных
-func GetTokenExpiration(db *sql.DB, userID int) (time.Time, error) {
+// GetTokenExpiration получает время истечения токена из базы данных
+func (r *PostgresUserRepository) GetTokenExpiration(ctx context.Context, userID int) (time.Time, error) {
 
 	var tokenExpiration time.Time
-	err := db.QueryRow("SELECT token_expires_at FROM users WHERE id = $1", userID).Scan(&tokenExpiration)
+	err := r.db.QueryRowContext(ctx, "SELECT token_expires_at FROM users WHERE id = $1", userID).Scan(&tokenExpiration)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, apperror.ErrUserNotFound
+	}
 	if err != nil {
-		logger.Error("Ошибка получения времени истечения токена из базы:" + err.Error())
+		logger.ErrorContext(ctx, "Ошибка получения времени истечения токена из базы", "target_id", userID, "error", err.Error())
 		return time.Time{}, err
 	}
 	return tokenExpiration, nil
 }
+
+// GetLocale получает сохранённый язык пользователя; пустая строка, если язык не выбран
+func (r *PostgresUserRepository) GetLocale(ctx context.Context, userID int) (string, error) {
+
+	var locale string
+	err := r.db.QueryRowContext(ctx, "SELECT COALESCE(locale, '') FROM users WHERE id = $1", userID).Scan(&locale)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", apperror.ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return locale, nil
+}
+
+// GetRole получает роль пользователя; пользователи без явно заданной роли считаются обычными
+func (r *PostgresUserRepository) GetRole(ctx context.Context, userID int) (string, error) {
+
+	var role string
+	err := r.db.QueryRowContext(ctx, "SELECT COALESCE(role, $2) FROM users WHERE id = $1", userID, models.RoleUser).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", apperror.ErrUserNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return role, nil
+}
+
+// SetRole изменяет роль пользователя
+func (r *PostgresUserRepository) SetRole(ctx context.Context, userID int, role string) error {
+
+	result, err := r.db.ExecContext(ctx, "UPDATE users SET role = $1, version = version + 1 WHERE id = $2", role, userID)
+	if err != nil {
+		return err
+	}
+	return checkUserAffected(result)
+}
@@ -0,0 +1,277 @@
+package dataBase
+
+import (
+	"Cloud/logger"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockKey — ключ advisory-блокировки, под которой выполняются миграции, чтобы экземпляры приложения не применяли их одновременно
+const migrationLockKey = 0x6d696772 // "migr"
+
+// migrationFileName — имя файла миграции: <версия>_<название>.<up|down>.sql
+var migrationFileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration — версия схемы базы данных с командами применения и отката
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus — состояние миграции в базе данных
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	AppliedAt *time.Time // nil — миграция не применена
+	Unknown   bool       // миграция применена, но её нет в этой сборке приложения
+}
+
+// Migrator применяет встроенные в приложение миграции и записывает применённые версии в таблицу schema_migrations
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration // по возрастанию версии
+}
+
+// NewMigrator создаёт Migrator для миграций из каталога migrations
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// loadMigrations читает пары up/down-файлов и проверяет, что у каждой версии есть оба файла
+func loadMigrations(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("некорректное имя файла миграции %q", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("некорректная версия миграции %q: %w", entry.Name(), err)
+		}
+		body, err := fs.ReadFile(files, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("у миграции %d два названия: %s и %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("у миграции %d_%s должны быть файлы up и down", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// withLock выполняет fn на отдельном соединении, удерживая advisory-блокировку миграций.
+// Блокировка сеансовая, поэтому все команды fn должны идти через conn.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// Ожидание блокировки и долгие миграции не должны прерываться по statement_timeout из строки подключения
+	if _, err := conn.ExecContext(ctx, `SET statement_timeout = 0`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), `RESET statement_timeout`)
+
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockKey); err != nil {
+		return fmt.Errorf("не удалось получить блокировку миграций: %w", err)
+	}
+	// Снимаем блокировку и при отменённом ctx, иначе она останется на соединении в пуле
+	defer conn.ExecContext(context.WithoutCancel(ctx), `SELECT pg_advisory_unlock($1)`, migrationLockKey)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+			  version    BIGINT      PRIMARY KEY,
+			  name       TEXT        NOT NULL,
+			  applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+		  )`); err != nil {
+		return fmt.Errorf("не удалось создать таблицу schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// applied возвращает применённые версии и время их применения
+func applied(ctx context.Context, conn *sql.Conn) (map[int64]MigrationStatus, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, name, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int64]MigrationStatus{}
+	for rows.Next() {
+		var status MigrationStatus
+		var appliedAt time.Time
+		if err := rows.Scan(&status.Version, &status.Name, &appliedAt); err != nil {
+			return nil, err
+		}
+		status.AppliedAt = &appliedAt
+		versions[status.Version] = status
+	}
+	return versions, rows.Err()
+}
+
+// Up применяет все неприменённые миграции по возрастанию версии; каждая миграция выполняется в своей транзакции.
+// Возвращает применённые миграции.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := m.run(ctx, conn, migration, migration.Up, true); err != nil {
+				return err
+			}
+			logger.Info(fmt.Sprintf("Применена миграция %d_%s", migration.Version, migration.Name))
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down откатывает steps последних применённых миграций. Возвращает откаченные миграции.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		// Откатить версию, которой нет в этой сборке, нечем, а откат более старых версий под ней сломает схему
+		for version := range versions {
+			if version > m.latest() {
+				return fmt.Errorf("миграция %d применена более новой версией приложения и не может быть откачена этой", version)
+			}
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := m.migrations[i]
+			if _, ok := versions[migration.Version]; !ok {
+				continue
+			}
+			if err := m.run(ctx, conn, migration, migration.Down, false); err != nil {
+				return err
+			}
+			logger.Info(fmt.Sprintf("Откачена миграция %d_%s", migration.Version, migration.Name))
+			done = append(done, migration)
+		}
+
+		return nil
+	})
+	return done, err
+}
+
+// run выполняет команды миграции и обновляет schema_migrations в одной транзакции
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, migration Migration, query string, up bool) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("миграция %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, migration.Version, migration.Name)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// latest возвращает последнюю версию схемы в этой сборке
+func (m *Migrator) latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status возвращает все миграции сборки и применённые версии, которых в сборке нет, по возрастанию версии
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var statuses []MigrationStatus
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		versions, err := applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+			if s, ok := versions[migration.Version]; ok {
+				status.AppliedAt = s.AppliedAt
+				delete(versions, migration.Version)
+			}
+			statuses = append(statuses, status)
+		}
+		for _, s := range versions {
+			s.Unknown = true
+			statuses = append(statuses, s)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
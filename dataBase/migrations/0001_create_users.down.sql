@@ -0,0 +1,7 @@
+-- Таблица users может быть старше миграций: 0001 лишь фиксирует её как базовую схему.
+-- Откат удалил бы данные, которые создавала не эта миграция, поэтому он запрещён.
+DO $$
+BEGIN
+    RAISE EXCEPTION 'миграцию 0001_create_users нельзя откатить: таблица users — базовая схема';
+END
+$$;
@@ -0,0 +1,13 @@
+-- Пользователи в том виде, в каком таблица существовала до появления миграций.
+-- IF NOT EXISTS позволяет применить миграцию к такой базе; столбцы, появившиеся позже, добавляют следующие миграции.
+CREATE TABLE IF NOT EXISTS users (
+    id               SERIAL PRIMARY KEY,
+    name             TEXT        NOT NULL,
+    phone            TEXT        NOT NULL DEFAULT '',
+    email            TEXT        NOT NULL DEFAULT '',
+    password         TEXT        NOT NULL,
+    from_date_create TEXT        NOT NULL,
+    from_date_update TEXT        NOT NULL,
+    is_deleted       BOOLEAN     NOT NULL DEFAULT FALSE,
+    is_banned        BOOLEAN     NOT NULL DEFAULT FALSE
+);
@@ -0,0 +1,20 @@
+-- Очередь исходящей почты
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    sender          TEXT        NOT NULL,
+    recipient       TEXT        NOT NULL,
+    subject         TEXT        NOT NULL,
+    body_text       TEXT        NOT NULL,
+    body_html       TEXT        NOT NULL DEFAULT '',
+    status          TEXT        NOT NULL,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    max_attempts    INTEGER     NOT NULL,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_error      TEXT,
+    locked_until    TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    sent_at         TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS email_outbox_status_next_attempt_idx ON email_outbox (status, next_attempt_at);
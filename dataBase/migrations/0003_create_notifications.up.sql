@@ -0,0 +1,20 @@
+-- Устройства, с которых входил пользователь: по ним определяется вход с нового устройства или IP-адреса
+CREATE TABLE IF NOT EXISTS user_devices (
+    user_id       INTEGER     NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    ip            TEXT        NOT NULL,
+    user_agent    TEXT        NOT NULL,
+    first_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_seen_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (user_id, ip, user_agent)
+);
+
+-- Настройки писем о событиях безопасности; без строки действуют значения по умолчанию
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id          INTEGER     PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    new_login        BOOLEAN     NOT NULL,
+    password_change  BOOLEAN     NOT NULL,
+    email_change     BOOLEAN     NOT NULL,
+    account_ban      BOOLEAN     NOT NULL,
+    account_deletion BOOLEAN     NOT NULL,
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,10 @@
+-- Адреса, на которые письма не отправляются: отказы доставки и жалобы
+CREATE TABLE IF NOT EXISTS email_suppressions (
+    email       TEXT        PRIMARY KEY,
+    reason      TEXT        NOT NULL,
+    detail      TEXT,
+    source      TEXT,
+    event_count INTEGER     NOT NULL DEFAULT 1,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,19 @@
+-- Задания на фоновую выгрузку логов запросов
+CREATE TABLE IF NOT EXISTS export_jobs (
+    id          TEXT        PRIMARY KEY,
+    status      TEXT        NOT NULL,
+    format      TEXT        NOT NULL,
+    filter      JSONB       NOT NULL,
+    rows_done   BIGINT      NOT NULL DEFAULT 0,
+    rows_total  BIGINT      NOT NULL DEFAULT 0,
+    file_path   TEXT,
+    file_size   BIGINT,
+    error       TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at  TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    expires_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS export_jobs_status_created_idx ON export_jobs (status, created_at);
@@ -0,0 +1,23 @@
+-- Логи запросов для хранилища postgres (REQUEST_LOG_SINKS)
+CREATE TABLE IF NOT EXISTS request_logs (
+    id             BIGSERIAL PRIMARY KEY,
+    request_id     TEXT             NOT NULL,
+    method         TEXT             NOT NULL,
+    endpoint       TEXT             NOT NULL,
+    route          TEXT             NOT NULL DEFAULT '',
+    query          TEXT             NOT NULL DEFAULT '',
+    user_id        TEXT             NOT NULL DEFAULT '',
+    ip             TEXT             NOT NULL,
+    user_agent     TEXT             NOT NULL DEFAULT '',
+    time           TIMESTAMPTZ      NOT NULL,
+    status_code    INTEGER          NOT NULL,
+    error_code     TEXT             NOT NULL DEFAULT '',
+    bytes_in       BIGINT           NOT NULL DEFAULT 0,
+    bytes_out      BIGINT           NOT NULL DEFAULT 0,
+    duration_ms    DOUBLE PRECISION NOT NULL,
+    security_event BOOLEAN          NOT NULL DEFAULT FALSE
+);
+
+CREATE INDEX IF NOT EXISTS request_logs_time_idx ON request_logs (time);
+CREATE INDEX IF NOT EXISTS request_logs_request_id_idx ON request_logs (request_id);
+CREATE INDEX IF NOT EXISTS request_logs_security_time_idx ON request_logs (time) WHERE security_event;
@@ -0,0 +1,33 @@
+-- Журнал аудита. Каждая запись хранит хеш предыдущей, поэтому изменение или удаление записи обнаруживается проверкой цепочки.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id         BIGSERIAL PRIMARY KEY,
+    time       TIMESTAMPTZ NOT NULL,
+    actor_id   INTEGER     NOT NULL DEFAULT 0,
+    actor_ip   TEXT        NOT NULL DEFAULT '',
+    action     TEXT        NOT NULL,
+    target_id  INTEGER     NOT NULL DEFAULT 0,
+    changes    JSONB       NOT NULL DEFAULT 'null',
+    request_id TEXT        NOT NULL DEFAULT '',
+    prev_hash  TEXT        NOT NULL,
+    hash       TEXT        NOT NULL UNIQUE
+);
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target_id, id);
+CREATE INDEX IF NOT EXISTS audit_log_action_idx ON audit_log (action, id);
+CREATE INDEX IF NOT EXISTS audit_log_time_idx ON audit_log (time);
+
+-- Журнал только дополняется: изменение, удаление и очистка таблицы запрещены
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only: % is not allowed', TG_OP;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
@@ -0,0 +1,2 @@
+-- У записи может быть только один преемник: ветвление цепочки хешей завершится ошибкой, а не испорченным журналом
+CREATE UNIQUE INDEX IF NOT EXISTS audit_log_prev_hash_idx ON audit_log (prev_hash);
@@ -0,0 +1,5 @@
+-- Один адрес и один номер — одна учётная запись. Email сравнивается без учёта регистра,
+-- телефон — только по цифрам, чтобы "+7 (999) 123-45-67" и "+79991234567" считались одним номером.
+-- Если в таблице уже есть дубликаты, миграция завершится ошибкой: их нужно разобрать вручную.
+CREATE UNIQUE INDEX IF NOT EXISTS users_email_unique_idx ON users (lower(email)) WHERE email <> '';
+CREATE UNIQUE INDEX IF NOT EXISTS users_phone_unique_idx ON users (regexp_replace(phone, '[^0-9]', '', 'g')) WHERE phone <> '';
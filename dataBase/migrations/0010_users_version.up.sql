@@ -0,0 +1,2 @@
+-- Версия строки для оптимистичной блокировки: растёт при каждом изменении пользователя и отдаётся клиентам как ETag
+ALTER TABLE users ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
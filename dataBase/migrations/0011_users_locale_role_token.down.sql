@@ -0,0 +1,3 @@
+-- Откат ничего не удаляет: столбцы locale, role и token_expires_at могли существовать до этой миграции,
+-- и их удаление уничтожило бы данные, которые она не создавала.
+SELECT 1;
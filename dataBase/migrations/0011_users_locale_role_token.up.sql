@@ -0,0 +1,5 @@
+-- Язык писем, роль и срок действия access токена. IF NOT EXISTS: в базе, созданной до миграций,
+-- часть столбцов уже может быть, и 0001 их не добавляет.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+ALTER TABLE users ADD COLUMN IF NOT EXISTS token_expires_at TIMESTAMPTZ;
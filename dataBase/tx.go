@@ -0,0 +1,108 @@
+package dataBase
+
+import (
+	"Cloud/logger"
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/lib/pq"
+	"time"
+)
+
+// maxTxAttempts — сколько раз выполняется транзакция, прерванная из-за конфликта с параллельной
+const maxTxAttempts = 3
+
+// txRetryDelay — пауза перед повтором; растёт с каждой попыткой
+const txRetryDelay = 20 * time.Millisecond
+
+// Tx даёт доступ к репозиториям, работающим внутри одной транзакции
+type Tx struct {
+	Users UserRepository
+
+	exec dbtx // nil — транзакция MemoryUserRepository
+}
+
+// AppendAuditEntry добавляет запись в журнал аудита в этой же транзакции: при откате пропадёт и запись
+func (tx *Tx) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	if tx.exec == nil {
+		return errors.New("журнал аудита хранится только в PostgreSQL")
+	}
+	return appendAuditEntry(ctx, tx.exec, entry)
+}
+
+// Transactor выполняет шаги операции в одной транзакции
+type Transactor interface {
+	// WithTx вызывает fn с репозиториями, привязанными к транзакции.
+	// Если fn вернула ошибку, транзакция откатывается и ошибка возвращается без изменений.
+	WithTx(ctx context.Context, fn func(tx *Tx) error) error
+}
+
+// PostgresTransactor — Transactor поверх PostgreSQL с изоляцией SERIALIZABLE: транзакции, которые
+// пересеклись с параллельными, PostgreSQL прерывает ошибкой сериализации, и WithTx их повторяет
+type PostgresTransactor struct {
+	db *sql.DB
+}
+
+// NewPostgresTransactor создаёт PostgresTransactor
+func NewPostgresTransactor(db *sql.DB) *PostgresTransactor {
+	return &PostgresTransactor{db: db}
+}
+
+func (t *PostgresTransactor) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	return WithTx(ctx, t.db, &sql.TxOptions{Isolation: sql.LevelSerializable}, fn)
+}
+
+// WithTx выполняет fn в транзакции с параметрами opts (nil — по умолчанию) и фиксирует её, если fn не вернула ошибку.
+// При ошибке или панике в fn транзакция откатывается. Транзакция, прерванная из-за ошибки сериализации
+// или взаимоблокировки, выполняется заново до maxTxAttempts раз, поэтому fn не должна иметь побочных эффектов вне базы данных.
+func WithTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		err = runTx(ctx, db, opts, fn)
+		if err == nil || !isRetryable(err) || attempt == maxTxAttempts {
+			break
+		}
+
+		logger.WarningContext(ctx, "Транзакция прервана из-за конфликта, повтор", "attempt", attempt, "error", err.Error())
+		select {
+		case <-time.After(time.Duration(attempt) * txRetryDelay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// runTx выполняет одну попытку транзакции
+func runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *Tx) error) error {
+	sqlTx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	// Откат после Commit ничего не делает, а при панике в fn освобождает соединение
+	defer sqlTx.Rollback()
+
+	tx := &Tx{
+		Users: &PostgresUserRepository{db: sqlTx, reader: sqlTx},
+		exec:  sqlTx,
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return sqlTx.Commit()
+}
+
+// isRetryable сообщает, что транзакцию можно повторить: её прервал PostgreSQL из-за конфликта с другой транзакцией
+func isRetryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	switch pqErr.Code {
+	case "40001", // serialization_failure
+		"40P01": // deadlock_detected
+		return true
+	}
+	return false
+}
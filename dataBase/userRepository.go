@@ -0,0 +1,57 @@
+package dataBase
+
+import (
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// UserRepository — хранилище пользователей. Все методы прерывают запрос, когда ctx отменён,
+// например когда клиент закрыл соединение.
+type UserRepository interface {
+	// Create сохраняет нового пользователя и записывает присвоенный ID в user.ID;
+	// ErrEmailTaken или ErrPhoneTaken, если email или телефон уже заняты
+	Create(ctx context.Context, user *models.User) error
+	// Get возвращает пользователя по ID, в том числе удалённого и заблокированного; ErrUserNotFound, если его нет
+	Get(ctx context.Context, userID int) (*models.User, error)
+	// List возвращает пользователей по возрастанию ID; filters — подстроки полей name, email и phone без учёта регистра
+	List(ctx context.Context, filters map[string]string, limit, offset int) ([]*models.User, error)
+	// Update изменяет непустые поля user и записывает новую версию в user.Version; ErrUserNotFound, если пользователя нет,
+	// ErrEmailTaken или ErrPhoneTaken, если новый email или телефон заняты другим пользователем.
+	// Изменение применяется только к версии user.Version: ErrPreconditionFailed, если версия другая,
+	// ErrPreconditionRequired, если она не указана (0).
+	Update(ctx context.Context, user *models.User) error
+	// Patch изменяет поля, заданные в patch, в том числе на пустые значения, и записывает новую версию в patch.Version.
+	// Ошибки те же, что у Update.
+	Patch(ctx context.Context, patch *models.UserPatch) error
+	// Delete помечает пользователя удалённым. Delete и SetRole тоже увеличивают версию пользователя.
+	Delete(ctx context.Context, userID int) error
+	// EmailTaken сообщает, есть ли учётная запись с таким email (без учёта регистра), в том числе удалённая или заблокированная,
+	// и возвращает её язык, чтобы письмо владельцу ушло на его языке
+	EmailTaken(ctx context.Context, email string) (taken bool, locale string, err error)
+	// PhoneOwner возвращает email и язык учётной записи с таким номером телефона (по цифрам номера), в том числе удалённой
+	// или заблокированной, или пустые строки, если номер свободен
+	PhoneOwner(ctx context.Context, phone string) (email, locale string, err error)
+	// FindByEmail ищет пользователя, под которым можно войти; ErrUserNotFound, ErrUserBanned или ErrUserDeleted
+	FindByEmail(ctx context.Context, email string) (*models.User, error)
+	// FindByPhone ищет пользователя, под которым можно войти; ErrUserNotFound, ErrUserBanned или ErrUserDeleted
+	FindByPhone(ctx context.Context, phone string) (*models.User, error)
+	// UpdateTokenExpiration сохраняет время истечения действующего access токена
+	UpdateTokenExpiration(ctx context.Context, userID int, expirationTime time.Time) error
+	// GetTokenExpiration возвращает время истечения действующего access токена
+	GetTokenExpiration(ctx context.Context, userID int) (time.Time, error)
+	// GetLocale возвращает выбранный пользователем язык или пустую строку
+	GetLocale(ctx context.Context, userID int) (string, error)
+	// GetRole возвращает роль пользователя
+	GetRole(ctx context.Context, userID int) (string, error)
+	// SetRole изменяет роль пользователя
+	SetRole(ctx context.Context, userID int, role string) error
+}
+
+// dbtx — общие методы *sql.DB и *sql.Tx, через которые работают репозитории
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
@@ -0,0 +1,333 @@
+package dataBase
+
+import (
+	"Cloud/apperror"
+	"Cloud/models"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryUserRepository хранит пользователей в памяти. Предназначен для тестов обработчиков.
+type MemoryUserRepository struct {
+	mu              sync.Mutex
+	nextID          int
+	users           map[int]*models.User
+	tokenExpiration map[int]time.Time
+}
+
+// NewMemoryUserRepository создаёт пустой MemoryUserRepository
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{users: map[int]*models.User{}, tokenExpiration: map[int]time.Time{}}
+}
+
+// get возвращает копию пользователя, чтобы вызывающий код не менял хранимые данные
+func (r *MemoryUserRepository) get(userID int) (*models.User, error) {
+	user, ok := r.users[userID]
+	if !ok {
+		return nil, apperror.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// conflict возвращает ErrEmailTaken или ErrPhoneTaken, если email или phone занят пользователем, отличным от userID.
+// Сравнение такое же, как в уникальных индексах PostgreSQL: email без учёта регистра, телефон по цифрам.
+func (r *MemoryUserRepository) conflict(userID int, email, phone string) error {
+	for id, u := range r.users {
+		if id == userID {
+			continue
+		}
+		if email != "" && strings.EqualFold(u.Email, email) {
+			return apperror.ErrEmailTaken
+		}
+		if phone != "" && u.Phone != "" && phoneDigits(u.Phone) == phoneDigits(phone) {
+			return apperror.ErrPhoneTaken
+		}
+	}
+	return nil
+}
+
+// phoneDigits оставляет в номере телефона только цифры
+func phoneDigits(phone string) string {
+	return strings.Map(func(c rune) rune {
+		if c >= '0' && c <= '9' {
+			return c
+		}
+		return -1
+	}, phone)
+}
+
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.conflict(0, user.Email, user.Phone); err != nil {
+		return err
+	}
+
+	r.nextID++
+	user.ID = r.nextID
+	user.Version = 1
+	stored := *user
+	if stored.Role == "" {
+		stored.Role = models.RoleUser
+	}
+	r.users[stored.ID] = &stored
+	return nil
+}
+
+func (r *MemoryUserRepository) Get(ctx context.Context, userID int) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.get(userID)
+}
+
+func (r *MemoryUserRepository) List(ctx context.Context, filters map[string]string, limit, offset int) ([]*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	matches := func(u *models.User) bool {
+		fields := map[string]string{"name": u.Name, "email": u.Email, "phone": u.Phone}
+		for field, value := range filters {
+			if value != "" && !strings.Contains(strings.ToLower(fields[field]), strings.ToLower(value)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	ids := make([]int, 0, len(r.users))
+	for id, u := range r.users {
+		if matches(u) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	users := make([]*models.User, 0)
+	for _, id := range ids[min(offset, len(ids)):min(offset+limit, len(ids))] {
+		user, _ := r.get(id)
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func (r *MemoryUserRepository) Update(ctx context.Context, user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := requireVersion(user.ID, user.Version); err != nil {
+		return err
+	}
+	stored, ok := r.users[user.ID]
+	if !ok {
+		return apperror.ErrUserNotFound
+	}
+	if user.Version != stored.Version {
+		return apperror.ErrPreconditionFailed
+	}
+	if err := r.conflict(user.ID, user.Email, user.Phone); err != nil {
+		return err
+	}
+
+	// Как и в PostgresUserRepository, пустые поля и false не меняют сохранённые значения
+	for _, field := range []struct{ dst, src *string }{
+		{&stored.Name, &user.Name}, {&stored.Phone, &user.Phone}, {&stored.Email, &user.Email},
+		{&stored.Password, &user.Password}, {&stored.Locale, &user.Locale},
+	} {
+		if *field.src != "" {
+			*field.dst = *field.src
+		}
+	}
+	stored.FromDateUpdate = user.FromDateUpdate
+	stored.IsDeleted = stored.IsDeleted || user.IsDeleted
+	stored.IsBanned = stored.IsBanned || user.IsBanned
+	stored.Version++
+	user.Version = stored.Version
+	return nil
+}
+
+func (r *MemoryUserRepository) Patch(ctx context.Context, patch *models.UserPatch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := requireVersion(patch.ID, patch.Version); err != nil {
+		return err
+	}
+	stored, ok := r.users[patch.ID]
+	if !ok {
+		return apperror.ErrUserNotFound
+	}
+	if patch.Version != stored.Version {
+		return apperror.ErrPreconditionFailed
+	}
+
+	email, phone := "", ""
+	if patch.Email != nil {
+		email = *patch.Email
+	}
+	if patch.Phone != nil {
+		phone = *patch.Phone
+	}
+	if err := r.conflict(patch.ID, email, phone); err != nil {
+		return err
+	}
+
+	for _, field := range []struct{ dst, src *string }{
+		{&stored.Name, patch.Name}, {&stored.Phone, patch.Phone}, {&stored.Email, patch.Email},
+		{&stored.Password, patch.Password}, {&stored.Locale, patch.Locale},
+	} {
+		if field.src != nil {
+			*field.dst = *field.src
+		}
+	}
+	if patch.IsDeleted != nil {
+		stored.IsDeleted = *patch.IsDeleted
+	}
+	if patch.IsBanned != nil {
+		stored.IsBanned = *patch.IsBanned
+	}
+	stored.FromDateUpdate = patch.FromDateUpdate
+	stored.Version++
+	patch.Version = stored.Version
+	return nil
+}
+
+func (r *MemoryUserRepository) Delete(ctx context.Context, userID int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[userID]
+	if !ok {
+		return apperror.ErrUserNotFound
+	}
+	stored.IsDeleted = true
+	stored.Version++
+	return nil
+}
+
+func (r *MemoryUserRepository) EmailTaken(ctx context.Context, email string) (bool, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if email != "" && u.Email != "" && strings.EqualFold(u.Email, email) {
+			return true, u.Locale, nil
+		}
+	}
+	return false, "", nil
+}
+
+func (r *MemoryUserRepository) PhoneOwner(ctx context.Context, phone string) (string, string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if phone != "" && u.Phone != "" && phoneDigits(u.Phone) == phoneDigits(phone) {
+			return u.Email, u.Locale, nil
+		}
+	}
+	return "", "", nil
+}
+
+func (r *MemoryUserRepository) FindByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.findActive(func(u *models.User) bool { return u.Email != "" && strings.EqualFold(u.Email, email) })
+}
+
+func (r *MemoryUserRepository) FindByPhone(ctx context.Context, phone string) (*models.User, error) {
+	return r.findActive(func(u *models.User) bool { return u.Phone != "" && phoneDigits(u.Phone) == phoneDigits(phone) })
+}
+
+// findActive ищет первого по ID пользователя, для которого match возвращает true, и проверяет, можно ли под ним войти
+func (r *MemoryUserRepository) findActive(match func(u *models.User) bool) (*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var found *models.User
+	for _, u := range r.users {
+		if match(u) && (found == nil || u.ID < found.ID) {
+			found = u
+		}
+	}
+
+	switch {
+	case found == nil:
+		return nil, apperror.ErrUserNotFound
+	case found.IsBanned:
+		return nil, apperror.ErrUserBanned
+	case found.IsDeleted:
+		return nil, apperror.ErrUserDeleted
+	}
+	return r.get(found.ID)
+}
+
+func (r *MemoryUserRepository) UpdateTokenExpiration(ctx context.Context, userID int, expirationTime time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// PostgresUserRepository не проверяет существование пользователя при обновлении токена
+	r.tokenExpiration[userID] = expirationTime
+	return nil
+}
+
+func (r *MemoryUserRepository) GetTokenExpiration(ctx context.Context, userID int) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[userID]; !ok {
+		return time.Time{}, apperror.ErrUserNotFound
+	}
+	return r.tokenExpiration[userID], nil
+}
+
+func (r *MemoryUserRepository) GetLocale(ctx context.Context, userID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, err := r.get(userID)
+	if err != nil {
+		return "", err
+	}
+	return user.Locale, nil
+}
+
+func (r *MemoryUserRepository) GetRole(ctx context.Context, userID int) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, err := r.get(userID)
+	if err != nil {
+		return "", err
+	}
+	if user.Role == "" {
+		return models.RoleUser, nil
+	}
+	return user.Role, nil
+}
+
+func (r *MemoryUserRepository) SetRole(ctx context.Context, userID int, role string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.users[userID]
+	if !ok {
+		return apperror.ErrUserNotFound
+	}
+	stored.Role = role
+	stored.Version++
+	return nil
+}
+
+// WithTx вызывает fn с этим же репозиторием. Изменения применяются сразу и при ошибке fn не откатываются.
+func (r *MemoryUserRepository) WithTx(ctx context.Context, fn func(tx *Tx) error) error {
+	return fn(&Tx{Users: r})
+}
+
+// Проверка на этапе компиляции, что реализации удовлетворяют интерфейсам
+var (
+	_ UserRepository = (*PostgresUserRepository)(nil)
+	_ UserRepository = (*MemoryUserRepository)(nil)
+	_ Transactor     = (*PostgresTransactor)(nil)
+	_ Transactor     = (*MemoryUserRepository)(nil)
+)
@@ -1,32 +1,76 @@
 package email
 
 import (
-	"fmt"
-	"net/smtp"
+	"context"
 	"os"
+	"time"
 )
 
+// ConfirmationCodeTTL — время действия кода подтверждения email
+const ConfirmationCodeTTL = time.Hour
+
+// renderer — рендерер шаблонов писем; по умолчанию использует только встроенные шаблоны
+var renderer = mustEmbeddedRenderer()
+
+func mustEmbeddedRenderer() *Renderer {
+	r, err := NewRenderer("")
+	if err != nil {
+		panic("email: не удалось загрузить встроенные шаблоны: " + err.Error())
+	}
+	return r
+}
+
+// LoadTemplates подключает каталог с переопределёнными шаблонами писем.
+// Вызывается при старте приложения; пустой overrideDir оставляет только встроенные шаблоны.
+func LoadTemplates(overrideDir string) error {
+	r, err := NewRenderer(overrideDir)
+	if err != nil {
+		return err
+	}
+	renderer = r
+	return nil
+}
+
+// Compose собирает письмо из шаблона name на языке lang
+func Compose(name, lang, to string, data any) (*Message, error) {
+	subject, text, html, err := renderer.Render(name, lang, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		From:    os.Getenv("MAIL_FROM"),
+		To:      []string{to},
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	}, nil
+}
+
 // SendConfirmationEmail отправляет электронное письмо с кодом подтверждения на указанный адрес.
+// mailer - транспорт, через который отправляется письмо.
 // to - адрес электронной почты получателя.
 // code - код подтверждения, который будет отправлен в письме.
-func SendConfirmationEmail(to, code string) error {
-	// Получаем переменные окружения для настройки почты
-	from := os.Getenv("MAIL_FROM")         // Адрес электронной почты отправителя
-	password := os.Getenv("MAIL_PASSWORD") // Пароль для SMTP-сервера
-	smtpHost := os.Getenv("MAIL_HOST")     // Хост SMTP-сервера
-	smtpPort := os.Getenv("MAIL_PORT")     // Порт SMTP-сервера
-
-	// Формируем сообщение, включая заголовок и тело письма
-	msg := []byte(fmt.Sprintf("Subject: Подтверждение регистрации\n\nВаш код подтверждения: %s", code))
+// lang - язык письма; тексты берутся из каталога сообщений i18n.
+func SendConfirmationEmail(ctx context.Context, mailer Mailer, to, code, lang string) error {
+	msg, err := Compose(TemplateConfirmation, lang, to, ConfirmationData{
+		Code:             code,
+		ExpiresInMinutes: int(ConfirmationCodeTTL.Minutes()),
+	})
+	if err != nil {
+		return err
+	}
 
-	// Настраиваем аутентификацию для отправки почты
-	auth := smtp.PlainAuth("", from, password, smtpHost)
+	return mailer.Send(ctx, msg)
+}
 
-	// Отправляем почту с использованием smtp.SendMail
-	err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{to}, msg)
+// SendAccountExistsEmail сообщает владельцу адреса to, что с этим адресом пытались зарегистрироваться повторно.
+// Отправляется вместо кода подтверждения, чтобы ответ на регистрацию не выдавал, есть ли у адреса учётная запись.
+func SendAccountExistsEmail(ctx context.Context, mailer Mailer, to, lang string) error {
+	msg, err := Compose(TemplateAccountExists, lang, to, nil)
 	if err != nil {
-		return err // Возвращаем ошибку, если отправка не удалась
+		return err
 	}
 
-	return nil // Возвращаем nil, если отправка прошла успешно
+	return mailer.Send(ctx, msg)
 }
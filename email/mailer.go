@@ -0,0 +1,257 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Mailer доставляет готовые письма получателям
+type Mailer interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Режимы шифрования соединения с SMTP-сервером
+const (
+	TLSModeNone     = "none"     // без шифрования (только для локальной разработки)
+	TLSModeStartTLS = "starttls" // STARTTLS поверх обычного соединения (обычно порт 587)
+	TLSModeImplicit = "tls"      // неявный TLS с первого байта (обычно порт 465)
+)
+
+// SMTPMailer отправляет письма через SMTP-сервер
+type SMTPMailer struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	TLSMode  string
+	Timeout  time.Duration
+}
+
+// Send отправляет письмо через SMTP с учётом режима TLS и дедлайна контекста
+func (m *SMTPMailer) Send(ctx context.Context, msg *Message) error {
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	timeout := m.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(m.Host, m.Port)
+	tlsConfig := &tls.Config{ServerName: m.Host, MinVersion: tls.VersionTLS12}
+
+	// Устанавливаем соединение
+	var conn net.Conn
+	dialer := &net.Dialer{}
+	if m.TLSMode == TLSModeImplicit {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось подключиться к SMTP-серверу %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	// STARTTLS обязателен в этом режиме: без него пароль ушёл бы открытым текстом
+	if m.TLSMode == TLSModeStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("SMTP-сервер %s не поддерживает STARTTLS", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("ошибка STARTTLS: %w", err)
+		}
+	}
+
+	if m.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", m.Username, m.Password, m.Host)); err != nil {
+			return fmt.Errorf("ошибка аутентификации SMTP: %w", err)
+		}
+	}
+
+	if err := client.Mail(envelopeAddress(msg.From)); err != nil {
+		return err
+	}
+	for _, to := range msg.To {
+		if err := client.Rcpt(envelopeAddress(to)); err != nil {
+			return err
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(raw); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// FileMailer складывает письма в каталог в виде .eml-файлов вместо отправки
+type FileMailer struct {
+	Dir string
+}
+
+// Send записывает письмо в отдельный .eml-файл
+func (m *FileMailer) Send(_ context.Context, msg *Message) error {
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := time.Now().UTC().Format("20060102T150405.000000000") + "-" + hex.EncodeToString(suffix) + ".eml"
+
+	// Пишем во временный файл и переименовываем, чтобы читатели каталога не видели недописанных писем
+	tmp := filepath.Join(m.Dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, raw, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(m.Dir, name))
+}
+
+// SentMessage — письмо, сохранённое MemoryMailer
+type SentMessage struct {
+	Message *Message
+	Raw     []byte
+}
+
+// MemoryMailer хранит отправленные письма в памяти; используется в тестах
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []SentMessage
+	// Err, если задана, возвращается из Send вместо сохранения письма
+	Err error
+}
+
+// NewMemoryMailer создаёт пустой MemoryMailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send сохраняет письмо в памяти
+func (m *MemoryMailer) Send(_ context.Context, msg *Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.Err != nil {
+		return m.Err
+	}
+
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	m.messages = append(m.messages, SentMessage{Message: msg, Raw: raw})
+	return nil
+}
+
+// Messages возвращает копию списка сохранённых писем
+func (m *MemoryMailer) Messages() []SentMessage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]SentMessage(nil), m.messages...)
+}
+
+// MessagesTo возвращает письма, отправленные на указанный адрес
+func (m *MemoryMailer) MessagesTo(addr string) []SentMessage {
+	var result []SentMessage
+	for _, sent := range m.Messages() {
+		for _, to := range sent.Message.To {
+			if envelopeAddress(to) == envelopeAddress(addr) {
+				result = append(result, sent)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// Reset удаляет все сохранённые письма
+func (m *MemoryMailer) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = nil
+}
+
+// NewMailerFromEnv создаёт Mailer по переменной MAIL_TRANSPORT: smtp (по умолчанию), file или memory
+func NewMailerFromEnv() (Mailer, error) {
+	switch transport := os.Getenv("MAIL_TRANSPORT"); transport {
+	case "", "smtp":
+		tlsMode := os.Getenv("MAIL_TLS")
+		if tlsMode == "" {
+			tlsMode = TLSModeStartTLS
+		}
+		if tlsMode != TLSModeNone && tlsMode != TLSModeStartTLS && tlsMode != TLSModeImplicit {
+			return nil, fmt.Errorf("неизвестный режим MAIL_TLS: %q", tlsMode)
+		}
+
+		timeout := 30 * time.Second
+		if value := os.Getenv("MAIL_TIMEOUT_SECONDS"); value != "" {
+			seconds, err := strconv.Atoi(value)
+			if err != nil || seconds <= 0 {
+				return nil, fmt.Errorf("некорректное значение MAIL_TIMEOUT_SECONDS: %q", value)
+			}
+			timeout = time.Duration(seconds) * time.Second
+		}
+
+		username := os.Getenv("MAIL_USERNAME")
+		if username == "" {
+			username = envelopeAddress(os.Getenv("MAIL_FROM"))
+		}
+
+		return &SMTPMailer{
+			Host:     os.Getenv("MAIL_HOST"),
+			Port:     os.Getenv("MAIL_PORT"),
+			Username: username,
+			Password: os.Getenv("MAIL_PASSWORD"),
+			TLSMode:  tlsMode,
+			Timeout:  timeout,
+		}, nil
+	case "file":
+		dir := os.Getenv("MAIL_DROP_DIR")
+		if dir == "" {
+			return nil, fmt.Errorf("для MAIL_TRANSPORT=file нужно указать MAIL_DROP_DIR")
+		}
+		return &FileMailer{Dir: dir}, nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("неизвестный MAIL_TRANSPORT: %q", transport)
+	}
+}
@@ -0,0 +1,139 @@
+package email
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message — письмо, готовое к отправке: multipart/alternative с текстовой и HTML-версиями
+type Message struct {
+	From      string
+	To        []string
+	Subject   string
+	Text      string
+	HTML      string
+	Date      time.Time
+	MessageID string
+	// Boundary задаёт разделитель частей; пустое значение — случайный разделитель
+	Boundary string
+}
+
+// Bytes сериализует письмо в формат RFC 5322 с кодировкой UTF-8
+func (m *Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный адрес отправителя %q: %w", m.From, err)
+	}
+
+	to := make([]string, 0, len(m.To))
+	for _, addr := range m.To {
+		parsed, err := mail.ParseAddress(addr)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный адрес получателя %q: %w", addr, err)
+		}
+		to = append(to, parsed.String())
+	}
+	if len(to) == 0 {
+		return nil, fmt.Errorf("не указан получатель письма")
+	}
+
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	messageID := m.MessageID
+	if messageID == "" {
+		messageID = newMessageID(from.Address)
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	if m.Boundary != "" {
+		if err := writer.SetBoundary(m.Boundary); err != nil {
+			return nil, err
+		}
+	}
+
+	// Заголовки письма
+	headers := []struct{ name, value string }{
+		{"From", from.String()},
+		{"To", strings.Join(to, ", ")},
+		{"Subject", mime.BEncoding.Encode("utf-8", m.Subject)},
+		{"Date", date.Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `multipart/alternative; boundary="` + writer.Boundary() + `"`},
+	}
+	var out bytes.Buffer
+	for _, h := range headers {
+		out.WriteString(h.name + ": " + h.value + "\r\n")
+	}
+	out.WriteString("\r\n")
+
+	// Сначала текстовая версия, затем HTML: клиенты выбирают последнюю поддерживаемую
+	if err := writePart(writer, "text/plain; charset=utf-8", m.Text); err != nil {
+		return nil, err
+	}
+	if err := writePart(writer, "text/html; charset=utf-8", m.HTML); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	out.Write(buf.Bytes())
+	return out.Bytes(), nil
+}
+
+// writePart добавляет часть письма в кодировке quoted-printable
+func writePart(writer *multipart.Writer, contentType, body string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	qp := quotedprintable.NewWriter(part)
+	if _, err := qp.Write([]byte(normalizeNewlines(body))); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// normalizeNewlines приводит переводы строк к CRLF, как требует RFC 5322
+func normalizeNewlines(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// newMessageID генерирует уникальный Message-ID в домене отправителя
+func newMessageID(fromAddress string) string {
+	domain := "localhost"
+	if at := strings.LastIndex(fromAddress, "@"); at >= 0 {
+		domain = fromAddress[at+1:]
+	}
+
+	b := make([]byte, 12)
+	rand.Read(b)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(b), domain)
+}
+
+// envelopeAddress возвращает голый адрес для SMTP-конверта: "Cloud <a@b.c>" -> "a@b.c"
+func envelopeAddress(addr string) string {
+	if parsed, err := mail.ParseAddress(addr); err == nil {
+		return parsed.Address
+	}
+	return addr
+}
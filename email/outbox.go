@@ -0,0 +1,346 @@
+package email
+
+import (
+	"Cloud/dataBase"
+	"Cloud/logger"
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OutboxConfig — параметры очереди исходящей почты
+type OutboxConfig struct {
+	Workers         int           // количество параллельных обработчиков
+	BatchSize       int           // сколько писем обработчик забирает за раз
+	PollInterval    time.Duration // пауза, если очередь пуста
+	MaxAttempts     int           // после стольких неудач письмо переходит в dead
+	BaseBackoff     time.Duration // задержка перед второй попыткой, далее удваивается
+	MaxBackoff      time.Duration // верхняя граница задержки
+	Lease           time.Duration // через сколько зависшее в sending письмо снова доступно
+	SendTimeout     time.Duration // тайм-аут одной попытки отправки
+	RecipientLimit  int           // не больше RecipientLimit писем одному получателю...
+	RecipientWindow time.Duration // ...за RecipientWindow
+}
+
+// DefaultOutboxConfig возвращает параметры очереди по умолчанию
+func DefaultOutboxConfig() OutboxConfig {
+	return OutboxConfig{
+		Workers:         4,
+		BatchSize:       10,
+		PollInterval:    2 * time.Second,
+		MaxAttempts:     8,
+		BaseBackoff:     30 * time.Second,
+		MaxBackoff:      6 * time.Hour,
+		Lease:           5 * time.Minute,
+		SendTimeout:     time.Minute,
+		RecipientLimit:  5,
+		RecipientWindow: 10 * time.Minute,
+	}
+}
+
+// OutboxConfigFromEnv читает параметры очереди из переменных окружения MAIL_OUTBOX_*
+func OutboxConfigFromEnv() (OutboxConfig, error) {
+	cfg := DefaultOutboxConfig()
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"MAIL_OUTBOX_WORKERS", &cfg.Workers},
+		{"MAIL_OUTBOX_BATCH_SIZE", &cfg.BatchSize},
+		{"MAIL_OUTBOX_MAX_ATTEMPTS", &cfg.MaxAttempts},
+		{"MAIL_OUTBOX_RECIPIENT_LIMIT", &cfg.RecipientLimit},
+	}
+	for _, v := range ints {
+		if value := os.Getenv(v.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return cfg, fmt.Errorf("некорректное значение %s: %q", v.name, value)
+			}
+			*v.dst = n
+		}
+	}
+
+	durations := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"MAIL_OUTBOX_POLL_INTERVAL", &cfg.PollInterval},
+		{"MAIL_OUTBOX_BASE_BACKOFF", &cfg.BaseBackoff},
+		{"MAIL_OUTBOX_MAX_BACKOFF", &cfg.MaxBackoff},
+		{"MAIL_OUTBOX_RECIPIENT_WINDOW", &cfg.RecipientWindow},
+	}
+	for _, v := range durations {
+		if value := os.Getenv(v.name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return cfg, fmt.Errorf("некорректное значение %s: %q", v.name, value)
+			}
+			*v.dst = d
+		}
+	}
+
+	return cfg, nil
+}
+
+// Outbox — очередь исходящей почты в PostgreSQL (таблица email_outbox).
+// Реализует Mailer: Send только ставит письмо в очередь, а доставкой через transport занимаются фоновые обработчики
+// с экспоненциальной задержкой между попытками и ограничением частоты писем одному получателю.
+type Outbox struct {
+	db        *sql.DB
+	transport Mailer
+	cfg       OutboxConfig
+	limiter   *recipientLimiter
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// NewOutbox создаёт очередь; transport — реальный способ доставки писем
+func NewOutbox(db *sql.DB, transport Mailer, cfg OutboxConfig) *Outbox {
+	return &Outbox{
+		db:        db,
+		transport: transport,
+		cfg:       cfg,
+		limiter:   newRecipientLimiter(cfg.RecipientLimit, cfg.RecipientWindow),
+	}
+}
+
+// Send ставит письмо в очередь: по одной записи на каждого получателя.
+// Если хотя бы один получатель в списке подавления, письмо не ставится в очередь и возвращается SuppressedError.
+func (o *Outbox) Send(ctx context.Context, msg *Message) error {
+	for _, to := range msg.To {
+		if err := checkSuppressed(ctx, o, to); err != nil {
+			return err
+		}
+	}
+
+	for _, to := range msg.To {
+		entry := &models.OutboxMessage{
+			From:          msg.From,
+			Recipient:     to,
+			Subject:       msg.Subject,
+			Text:          msg.Text,
+			HTML:          msg.HTML,
+			MaxAttempts:   o.cfg.MaxAttempts,
+			NextAttemptAt: time.Now(),
+		}
+		if err := dataBase.DBEnqueueEmail(ctx, o.db, entry); err != nil {
+			return fmt.Errorf("не удалось поставить письмо в очередь: %w", err)
+		}
+	}
+	return nil
+}
+
+// Start запускает фоновые обработчики очереди
+func (o *Outbox) Start(ctx context.Context) {
+	ctx, o.cancel = context.WithCancel(ctx)
+
+	for i := 0; i < o.cfg.Workers; i++ {
+		o.wg.Add(1)
+		go func() {
+			defer o.wg.Done()
+			o.work(ctx)
+		}()
+	}
+	logger.InfoContext(ctx, "Очередь писем запущена", "workers", o.cfg.Workers)
+}
+
+// Stop останавливает обработчики и ждёт завершения текущих отправок
+func (o *Outbox) Stop() {
+	if o.cancel != nil {
+		o.cancel()
+	}
+	o.wg.Wait()
+}
+
+// work — цикл одного обработчика
+func (o *Outbox) work(ctx context.Context) {
+	for {
+		batch, err := dataBase.DBClaimOutboxBatch(ctx, o.db, o.cfg.BatchSize, o.cfg.Lease)
+		if err != nil && ctx.Err() == nil {
+			logger.ErrorContext(ctx, "Ошибка чтения очереди писем", "error", err.Error())
+		}
+
+		for i, msg := range batch {
+			// При остановке возвращаем необработанные письма в очередь, не дожидаясь истечения lease
+			if ctx.Err() != nil {
+				o.release(ctx, batch[i:])
+				return
+			}
+			o.deliver(ctx, msg)
+		}
+
+		// Если очередь пуста, ждём перед следующим опросом
+		if len(batch) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(o.cfg.PollInterval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// deliver отправляет одно письмо и записывает результат попытки
+func (o *Outbox) deliver(ctx context.Context, entry *models.OutboxMessage) {
+	// Результат записываем даже при остановке, поэтому не используем отменяемый контекст
+	dbCtx := context.WithoutCancel(ctx)
+
+	// Адрес мог попасть в список подавления, пока письмо ждало в очереди
+	if err := checkSuppressed(dbCtx, o, entry.Recipient); IsSuppressed(err) {
+		logger.WarningContext(ctx, "Письмо не отправлено", "message_id", entry.ID, "error", err.Error())
+		if err := dataBase.DBMarkOutboxFailed(dbCtx, o.db, entry.ID, models.OutboxDead, time.Now(), truncate(err.Error(), 1000)); err != nil {
+			logger.ErrorContext(ctx, "Не удалось сохранить результат отправки письма", "message_id", entry.ID, "error", err.Error())
+		}
+		return
+	} else if err != nil {
+		// Список подавления недоступен — откладываем письмо, не расходуя попытку
+		logger.ErrorContext(ctx, "Письмо отложено", "message_id", entry.ID, "error", err.Error())
+		if err := dataBase.DBDeferOutbox(dbCtx, o.db, entry.ID, time.Now().Add(o.cfg.PollInterval)); err != nil {
+			logger.ErrorContext(ctx, "Не удалось отложить письмо", "message_id", entry.ID, "error", err.Error())
+		}
+		return
+	}
+
+	if ok, retryAt := o.limiter.Reserve(entry.Recipient, time.Now()); !ok {
+		if err := dataBase.DBDeferOutbox(dbCtx, o.db, entry.ID, retryAt); err != nil {
+			logger.ErrorContext(ctx, "Не удалось отложить письмо", "message_id", entry.ID, "error", err.Error())
+		}
+		return
+	}
+
+	msg := &Message{
+		From:      entry.From,
+		To:        []string{entry.Recipient},
+		Subject:   entry.Subject,
+		Text:      entry.Text,
+		HTML:      entry.HTML,
+		MessageID: outboxMessageID(entry),
+	}
+
+	sendCtx, cancel := context.WithTimeout(dbCtx, o.cfg.SendTimeout)
+	err := o.transport.Send(sendCtx, msg)
+	cancel()
+
+	if err == nil {
+		if err := dataBase.DBMarkOutboxSent(dbCtx, o.db, entry.ID); err != nil {
+			logger.ErrorContext(ctx, "Письмо отправлено, но статус не сохранён", "message_id", entry.ID, "error", err.Error())
+		}
+		return
+	}
+
+	attempts := entry.Attempts + 1
+	status := models.OutboxPending
+	nextAttemptAt := time.Now().Add(o.backoff(attempts))
+	if attempts >= entry.MaxAttempts {
+		status = models.OutboxDead
+		logger.ErrorContext(ctx, "Письмо не доставлено", "message_id", entry.ID, "recipient", entry.Recipient, "attempts", attempts, "error", err.Error())
+	} else {
+		logger.WarningContext(ctx, "Попытка отправки письма не удалась", "message_id", entry.ID, "attempt", attempts, "next_attempt_at", nextAttemptAt.Format(time.RFC3339), "error", err.Error())
+	}
+
+	if err := dataBase.DBMarkOutboxFailed(dbCtx, o.db, entry.ID, status, nextAttemptAt, truncate(err.Error(), 1000)); err != nil {
+		logger.ErrorContext(ctx, "Не удалось сохранить результат отправки письма", "message_id", entry.ID, "error", err.Error())
+	}
+}
+
+// release возвращает забранные письма в очередь без увеличения счётчика попыток
+func (o *Outbox) release(ctx context.Context, batch []*models.OutboxMessage) {
+	ctx = context.WithoutCancel(ctx)
+	for _, entry := range batch {
+		if err := dataBase.DBDeferOutbox(ctx, o.db, entry.ID, time.Now()); err != nil {
+			logger.ErrorContext(ctx, "Не удалось вернуть письмо в очередь", "message_id", entry.ID, "error", err.Error())
+		}
+	}
+}
+
+// backoff возвращает задержку перед попыткой номер attempt+1: base * 2^(attempt-1) ± 20%, не больше MaxBackoff
+func (o *Outbox) backoff(attempt int) time.Duration {
+	delay := o.cfg.BaseBackoff
+	for i := 1; i < attempt && delay < o.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > o.cfg.MaxBackoff {
+		delay = o.cfg.MaxBackoff
+	}
+
+	jitter := time.Duration(rand.Int63n(int64(delay)/5*2+1)) - delay/5
+	return delay + jitter
+}
+
+// outboxMessageID строит стабильный Message-ID, чтобы повторные попытки не размножали письмо у получателя
+func outboxMessageID(entry *models.OutboxMessage) string {
+	domain := "localhost"
+	if addr := envelopeAddress(entry.From); strings.Contains(addr, "@") {
+		domain = addr[strings.LastIndex(addr, "@")+1:]
+	}
+	return fmt.Sprintf("<outbox.%d.%d@%s>", entry.ID, entry.CreatedAt.Unix(), domain)
+}
+
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
+
+// recipientLimiter ограничивает частоту писем одному получателю скользящим окном.
+// Состояние хранится в памяти процесса, поэтому лимит действует в пределах одного экземпляра приложения.
+type recipientLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	sent   map[string][]time.Time
+}
+
+func newRecipientLimiter(limit int, window time.Duration) *recipientLimiter {
+	return &recipientLimiter{limit: limit, window: window, sent: make(map[string][]time.Time)}
+}
+
+// Reserve резервирует отправку письма получателю.
+// Если лимит исчерпан, возвращает false и время, когда можно попробовать снова.
+func (l *recipientLimiter) Reserve(recipient string, now time.Time) (bool, time.Time) {
+	if l.limit <= 0 {
+		return true, now
+	}
+
+	key := strings.ToLower(envelopeAddress(recipient))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Время от времени убираем получателей без отправок в текущем окне, чтобы карта не росла бесконечно
+	if len(l.sent) > 1024 {
+		for k, times := range l.sent {
+			if len(times) == 0 || now.Sub(times[len(times)-1]) >= l.window {
+				delete(l.sent, k)
+			}
+		}
+	}
+
+	// Отбрасываем отправки за пределами окна
+	recent := l.sent[key][:0]
+	for _, t := range l.sent[key] {
+		if now.Sub(t) < l.window {
+			recent = append(recent, t)
+		}
+	}
+
+	if len(recent) >= l.limit {
+		l.sent[key] = recent
+		return false, recent[0].Add(l.window)
+	}
+
+	l.sent[key] = append(recent, now)
+	return true, now
+}
@@ -0,0 +1,38 @@
+package email
+
+import (
+	"Cloud/apperror"
+	"Cloud/dataBase"
+	"context"
+	"errors"
+	"fmt"
+)
+
+// SuppressedError означает, что письмо не отправлено: адрес находится в списке подавления
+// после постоянного отказа доставки или жалобы получателя
+type SuppressedError struct {
+	Address string
+	Reason  string
+}
+
+func (e *SuppressedError) Error() string {
+	return fmt.Sprintf("адрес %s находится в списке подавления (%s)", e.Address, e.Reason)
+}
+
+// IsSuppressed сообщает, что ошибка вызвана попыткой отправить письмо на подавленный адрес
+func IsSuppressed(err error) bool {
+	var suppressed *SuppressedError
+	return errors.As(err, &suppressed)
+}
+
+// checkSuppressed возвращает SuppressedError, если адрес находится в списке подавления
+func checkSuppressed(ctx context.Context, o *Outbox, recipient string) error {
+	s, err := dataBase.DBGetSuppression(ctx, o.db, envelopeAddress(recipient))
+	if errors.Is(err, apperror.ErrSuppressionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось проверить список подавления: %w", err)
+	}
+	return &SuppressedError{Address: s.Email, Reason: s.Reason}
+}
@@ -0,0 +1,166 @@
+package email
+
+import (
+	"Cloud/i18n"
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	texttemplate "text/template"
+	"time"
+)
+
+// Виды транзакционных писем; совпадают с именами файлов шаблонов
+const (
+	TemplateConfirmation    = "confirmation"
+	TemplatePasswordReset   = "password_reset"
+	TemplateLoginAlert      = "login_alert"
+	TemplateAccountDeletion = "account_deletion"
+	TemplatePasswordChanged = "password_changed"
+	TemplateEmailChanged    = "email_changed"
+	TemplateAccountBanned   = "account_banned"
+	TemplateAccountExists   = "account_exists"
+)
+
+//go:embed templates/*.tmpl
+var embeddedTemplates embed.FS
+
+// ConfirmationData — данные для письма с кодом подтверждения
+type ConfirmationData struct {
+	Code             string
+	ExpiresInMinutes int
+}
+
+// PasswordResetData — данные для письма со ссылкой на сброс пароля
+type PasswordResetData struct {
+	ResetURL         string
+	ExpiresInMinutes int
+}
+
+// LoginAlertData — данные для письма о входе с нового устройства
+type LoginAlertData struct {
+	Time      time.Time
+	IP        string
+	UserAgent string
+	RevokeURL string
+}
+
+// AccountDeletionData — данные для письма об удалении учётной записи
+type AccountDeletionData struct {
+	Name      string
+	Time      time.Time
+	RevokeURL string
+}
+
+// PasswordChangedData — данные для письма о смене пароля
+type PasswordChangedData struct {
+	Name      string
+	Time      time.Time
+	RevokeURL string
+}
+
+// EmailChangedData — данные для письма о смене адреса электронной почты; отправляется на прежний адрес
+type EmailChangedData struct {
+	Name      string
+	Time      time.Time
+	NewEmail  string
+	RevokeURL string
+}
+
+// AccountBannedData — данные для письма о блокировке учётной записи
+type AccountBannedData struct {
+	Name      string
+	Time      time.Time
+	RevokeURL string
+}
+
+// templateContext — то, что видят шаблоны при выполнении
+type templateContext struct {
+	Lang    string
+	Subject string
+	Data    any
+}
+
+// Renderer собирает письма из встроенных шаблонов.
+// Файлы из каталога переопределений (MAIL_TEMPLATES_DIR) заменяют встроенные шаблоны с тем же именем.
+type Renderer struct {
+	templates fs.FS
+}
+
+// NewRenderer создаёт рендерер; пустой overrideDir означает использование только встроенных шаблонов
+func NewRenderer(overrideDir string) (*Renderer, error) {
+	embedded, err := fs.Sub(embeddedTemplates, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	if overrideDir == "" {
+		return &Renderer{templates: embedded}, nil
+	}
+
+	info, err := os.Stat(overrideDir)
+	if err != nil {
+		return nil, fmt.Errorf("каталог шаблонов писем недоступен: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s не является каталогом", overrideDir)
+	}
+
+	return &Renderer{templates: overlayFS{upper: os.DirFS(overrideDir), lower: embedded}}, nil
+}
+
+// Render строит тему, текстовую и HTML-версии письма на указанном языке
+func (r *Renderer) Render(name, lang string, data any) (subject, text, html string, err error) {
+	if !i18n.IsSupported(lang) {
+		lang = i18n.Default
+	}
+
+	translate := func(key string, args ...any) string {
+		return i18n.T(lang, key, args...)
+	}
+
+	subject = translate("email." + name + ".subject")
+	ctx := templateContext{Lang: lang, Subject: subject, Data: data}
+
+	// Текстовая версия
+	textTmpl, err := texttemplate.New(name+".txt.tmpl").
+		Funcs(texttemplate.FuncMap{"t": translate}).
+		ParseFS(r.templates, name+".txt.tmpl")
+	if err != nil {
+		return "", "", "", fmt.Errorf("ошибка разбора текстового шаблона %s: %w", name, err)
+	}
+	var textBuf bytes.Buffer
+	if err := textTmpl.Execute(&textBuf, ctx); err != nil {
+		return "", "", "", fmt.Errorf("ошибка выполнения текстового шаблона %s: %w", name, err)
+	}
+
+	// HTML-версия: общий макет и содержимое конкретного письма
+	htmlTmpl, err := htmltemplate.New("layout").
+		Funcs(htmltemplate.FuncMap{"t": translate}).
+		ParseFS(r.templates, "layout.html.tmpl", name+".html.tmpl")
+	if err != nil {
+		return "", "", "", fmt.Errorf("ошибка разбора HTML-шаблона %s: %w", name, err)
+	}
+	var htmlBuf bytes.Buffer
+	if err := htmlTmpl.ExecuteTemplate(&htmlBuf, "layout", ctx); err != nil {
+		return "", "", "", fmt.Errorf("ошибка выполнения HTML-шаблона %s: %w", name, err)
+	}
+
+	return subject, textBuf.String(), htmlBuf.String(), nil
+}
+
+// overlayFS ищет файл сначала в upper, затем в lower
+type overlayFS struct {
+	upper fs.FS
+	lower fs.FS
+}
+
+// Open реализует fs.FS
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if f, err := o.upper.Open(name); err == nil {
+		return f, nil
+	}
+	return o.lower.Open(name)
+}
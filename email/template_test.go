@@ -0,0 +1,108 @@
+package email
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Обновить эталонные файлы: go test ./email -run TestRenderGolden -update
+var update = flag.Bool("update", false, "перезаписать эталонные файлы в testdata")
+
+func TestRenderGolden(t *testing.T) {
+	sentAt := time.Date(2024, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		template string
+		data     any
+	}{
+		{"confirmation", TemplateConfirmation, ConfirmationData{Code: "123456", ExpiresInMinutes: 60}},
+		{"password_reset", TemplatePasswordReset, PasswordResetData{ResetURL: "https://cloud.example.com/reset?token=abc&user=1", ExpiresInMinutes: 30}},
+		{"login_alert", TemplateLoginAlert, LoginAlertData{Time: sentAt, IP: "203.0.113.7", UserAgent: "Mozilla/5.0 <Test>", RevokeURL: "https://cloud.example.com/revoke?token=xyz"}},
+		{"account_deletion", TemplateAccountDeletion, AccountDeletionData{Name: "Иван", Time: sentAt}},
+		{"password_changed", TemplatePasswordChanged, PasswordChangedData{Name: "Иван", Time: sentAt, RevokeURL: "https://cloud.example.com/revoke?token=xyz"}},
+		{"email_changed", TemplateEmailChanged, EmailChangedData{Name: "Иван", Time: sentAt, NewEmail: "new@example.com", RevokeURL: "https://cloud.example.com/revoke?token=xyz"}},
+		{"account_banned", TemplateAccountBanned, AccountBannedData{Name: "Иван", Time: sentAt}},
+		{"account_exists", TemplateAccountExists, nil},
+	}
+
+	r, err := NewRenderer("")
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+
+	for _, tc := range cases {
+		for _, lang := range []string{"ru", "en"} {
+			t.Run(tc.name+"_"+lang, func(t *testing.T) {
+				subject, text, html, err := r.Render(tc.template, lang, tc.data)
+				if err != nil {
+					t.Fatalf("Render: %v", err)
+				}
+
+				msg := &Message{
+					From:      "Cloud <noreply@cloud.example.com>",
+					To:        []string{"user@example.com"},
+					Subject:   subject,
+					Text:      text,
+					HTML:      html,
+					Date:      sentAt,
+					MessageID: "<golden@cloud.example.com>",
+					Boundary:  "golden-boundary",
+				}
+				got, err := msg.Bytes()
+				if err != nil {
+					t.Fatalf("Bytes: %v", err)
+				}
+
+				assertGolden(t, filepath.Join("testdata", tc.name+"_"+lang+".eml.golden"), got)
+			})
+		}
+	}
+}
+
+func TestRenderOverride(t *testing.T) {
+	dir := t.TempDir()
+	override := "OVERRIDE {{.Data.Code}}\n"
+	if err := os.WriteFile(filepath.Join(dir, "confirmation.txt.tmpl"), []byte(override), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewRenderer(dir)
+	if err != nil {
+		t.Fatalf("NewRenderer: %v", err)
+	}
+
+	_, text, html, err := r.Render(TemplateConfirmation, "ru", ConfirmationData{Code: "654321"})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if text != "OVERRIDE 654321\n" {
+		t.Errorf("текстовая версия не переопределена: %q", text)
+	}
+	if !bytes.Contains([]byte(html), []byte("654321")) {
+		t.Errorf("HTML-версия должна браться из встроенного шаблона: %q", html)
+	}
+}
+
+func assertGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("не удалось прочитать эталон %s (запустите с -update): %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("результат отличается от эталона %s\n--- получено ---\n%s\n--- ожидалось ---\n%s", path, got, want)
+	}
+}
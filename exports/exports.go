@@ -0,0 +1,370 @@
+package exports
+
+import (
+	"Cloud/apperror"
+	"Cloud/dataBase"
+	"Cloud/logger"
+	"Cloud/models"
+	"Cloud/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Config — параметры фоновых выгрузок логов
+type Config struct {
+	Dir             string        // каталог для готовых файлов
+	Workers         int           // количество параллельных выгрузок
+	PollInterval    time.Duration // пауза, если очередь пуста
+	Lease           time.Duration // выгрузка без прогресса дольше Lease считается прерванной и запускается заново
+	Retention       time.Duration // сколько хранится готовый файл
+	CleanupInterval time.Duration // как часто удаляются файлы с истёкшим сроком хранения
+	LinkTTL         time.Duration // сколько действует ссылка на скачивание
+	SigningKey      []byte        // ключ подписи ссылок
+}
+
+// DefaultConfig возвращает параметры выгрузок по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		Dir:             "exports",
+		Workers:         2,
+		PollInterval:    2 * time.Second,
+		Lease:           5 * time.Minute,
+		Retention:       24 * time.Hour,
+		CleanupInterval: 10 * time.Minute,
+		LinkTTL:         15 * time.Minute,
+	}
+}
+
+// ConfigFromEnv читает параметры выгрузок из переменных окружения EXPORT_*.
+// Ключ подписи ссылок EXPORT_SIGNING_KEY обязателен и не должен совпадать с JWT_SECRET_KEY:
+// иначе утечка одного ключа позволила бы подделывать и ссылки, и токены доступа.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+
+	if dir := os.Getenv("EXPORT_DIR"); dir != "" {
+		cfg.Dir = dir
+	}
+	if value := os.Getenv("EXPORT_WORKERS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return cfg, fmt.Errorf("некорректное значение EXPORT_WORKERS: %q", value)
+		}
+		cfg.Workers = n
+	}
+
+	durations := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"EXPORT_RETENTION", &cfg.Retention},
+		{"EXPORT_CLEANUP_INTERVAL", &cfg.CleanupInterval},
+		{"EXPORT_LINK_TTL", &cfg.LinkTTL},
+	}
+	for _, v := range durations {
+		if value := os.Getenv(v.name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return cfg, fmt.Errorf("некорректное значение %s: %q", v.name, value)
+			}
+			*v.dst = d
+		}
+	}
+
+	key := os.Getenv("EXPORT_SIGNING_KEY")
+	if key == "" {
+		return cfg, fmt.Errorf("не задан ключ подписи ссылок EXPORT_SIGNING_KEY")
+	}
+	if key == os.Getenv("JWT_SECRET_KEY") {
+		return cfg, fmt.Errorf("EXPORT_SIGNING_KEY не должен совпадать с JWT_SECRET_KEY")
+	}
+	cfg.SigningKey = []byte(key)
+
+	return cfg, nil
+}
+
+// Manager принимает задания на выгрузку логов, выполняет их в фоне и выдаёт подписанные ссылки на готовые файлы.
+// Задания хранятся в PostgreSQL (таблица export_jobs), поэтому переживают перезапуск приложения.
+type Manager struct {
+	db   *sql.DB
+	logs *mongo.Collection
+	cfg  Config
+
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// NewManager создаёт Manager и каталог для файлов выгрузок
+func NewManager(db *sql.DB, logs *mongo.Collection, cfg Config) (*Manager, error) {
+	if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог выгрузок: %w", err)
+	}
+	return &Manager{db: db, logs: logs, cfg: cfg}, nil
+}
+
+// Create ставит в очередь выгрузку логов, подходящих под filter, в формате format
+func (m *Manager) Create(ctx context.Context, format string, filter dataBase.RequestLogFilter) (*models.ExportJob, error) {
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	job := &models.ExportJob{ID: hex.EncodeToString(id), Format: format, Filter: filterJSON}
+	if err := dataBase.DBCreateExportJob(ctx, m.db, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Get возвращает задание на выгрузку
+func (m *Manager) Get(ctx context.Context, id string) (*models.ExportJob, error) {
+	return dataBase.DBGetExportJob(ctx, m.db, id)
+}
+
+// DownloadURL возвращает подписанную ссылку на файл готовой выгрузки.
+// Ссылка действует LinkTTL, но не дольше срока хранения файла.
+func (m *Manager) DownloadURL(job *models.ExportJob) string {
+	expires := time.Now().Add(m.cfg.LinkTTL)
+	if job.ExpiresAt != nil && job.ExpiresAt.Before(expires) {
+		expires = *job.ExpiresAt
+	}
+
+	values := url.Values{}
+	values.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	values.Set("signature", m.sign(job.ID, expires.Unix()))
+	return utils.BaseURL() + "/exports/" + url.PathEscape(job.ID) + "/download?" + values.Encode()
+}
+
+// Open проверяет подпись ссылки и открывает файл готовой выгрузки
+func (m *Manager) Open(ctx context.Context, id, expires, signature string) (*os.File, *models.ExportJob, error) {
+	expiresUnix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > expiresUnix ||
+		!hmac.Equal([]byte(signature), []byte(m.sign(id, expiresUnix))) {
+		return nil, nil, apperror.ErrExportLinkInvalid
+	}
+
+	job, err := m.Get(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch job.Status {
+	case models.ExportDone:
+	case models.ExportExpired:
+		return nil, nil, apperror.ErrExportExpired
+	default:
+		return nil, nil, apperror.ErrExportNotReady
+	}
+
+	file, err := os.Open(job.FilePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, apperror.ErrExportExpired.Wrap(err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return file, job, nil
+}
+
+// sign подписывает ID задания и время истечения ссылки
+func (m *Manager) sign(id string, expires int64) string {
+	mac := hmac.New(sha256.New, m.cfg.SigningKey)
+	mac.Write([]byte(id + "\n" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Start запускает обработчики выгрузок и периодическое удаление устаревших файлов
+func (m *Manager) Start(ctx context.Context) {
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	for i := 0; i < m.cfg.Workers; i++ {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.work(ctx)
+		}()
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.cleanup(ctx)
+	}()
+
+	logger.Info(fmt.Sprintf("Выгрузки логов запущены: %d обработчиков, файлы в %s", m.cfg.Workers, m.cfg.Dir))
+}
+
+// Stop останавливает обработчики; прерванные выгрузки возвращаются в очередь
+func (m *Manager) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+}
+
+// work — цикл одного обработчика
+func (m *Manager) work(ctx context.Context) {
+	for {
+		job, err := dataBase.DBClaimExportJob(ctx, m.db, m.cfg.Lease)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("Ошибка чтения очереди выгрузок: " + err.Error())
+		}
+
+		if job != nil {
+			m.run(ctx, job)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.cfg.PollInterval):
+		}
+	}
+}
+
+// run выполняет одну выгрузку: пишет файл во временный и переименовывает его после успешного завершения
+func (m *Manager) run(ctx context.Context, job *models.ExportJob) {
+	// Результат записываем даже при остановке, поэтому не используем отменяемый контекст
+	dbCtx := context.WithoutCancel(ctx)
+
+	rows, err := m.write(ctx, job)
+	if err != nil {
+		if ctx.Err() != nil {
+			if err := dataBase.DBRequeueExportJob(dbCtx, m.db, job.ID); err != nil {
+				logger.Error(fmt.Sprintf("Не удалось вернуть выгрузку %s в очередь: %s", job.ID, err.Error()))
+			}
+			return
+		}
+
+		logger.Error(fmt.Sprintf("Выгрузка %s завершилась ошибкой: %s", job.ID, err.Error()))
+		if err := dataBase.DBFailExportJob(dbCtx, m.db, job.ID, err.Error(), time.Now().Add(m.cfg.Retention)); err != nil {
+			logger.Error(fmt.Sprintf("Не удалось сохранить ошибку выгрузки %s: %s", job.ID, err.Error()))
+		}
+		return
+	}
+
+	path := m.filePath(job)
+	info, err := os.Stat(path)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Файл выгрузки %s недоступен: %s", job.ID, err.Error()))
+		return
+	}
+
+	if err := dataBase.DBFinishExportJob(dbCtx, m.db, job.ID, path, info.Size(), rows, time.Now().Add(m.cfg.Retention)); err != nil {
+		logger.Error(fmt.Sprintf("Выгрузка %s готова, но статус не сохранён: %s", job.ID, err.Error()))
+		return
+	}
+	logger.Info(fmt.Sprintf("Выгрузка %s готова: %d записей, %d байт", job.ID, rows, info.Size()))
+}
+
+// write выгружает логи в файл и возвращает количество записей
+func (m *Manager) write(ctx context.Context, job *models.ExportJob) (int64, error) {
+	var filter dataBase.RequestLogFilter
+	if err := json.Unmarshal(job.Filter, &filter); err != nil {
+		return 0, fmt.Errorf("некорректный фильтр: %w", err)
+	}
+
+	total, err := dataBase.DBCountRequestLogs(ctx, m.logs, filter)
+	if err != nil {
+		return 0, err
+	}
+	if err := dataBase.DBUpdateExportProgress(ctx, m.db, job.ID, 0, total); err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(m.cfg.Dir, "."+job.ID+"-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	exporter, err := utils.NewLogExporter(job.Format, tmp)
+	if err != nil {
+		return 0, err
+	}
+
+	// Прогресс сохраняем не чаще раза в секунду, чтобы не нагружать базу
+	var done int64
+	lastReport := time.Now()
+	err = dataBase.DBEachRequestLog(ctx, m.logs, filter, func(log *models.RequestLog) error {
+		if err := exporter.Write(log); err != nil {
+			return err
+		}
+		done++
+		if time.Since(lastReport) >= time.Second {
+			lastReport = time.Now()
+			return dataBase.DBUpdateExportProgress(ctx, m.db, job.ID, done, max(total, done))
+		}
+		return nil
+	})
+	if summaryExporter, ok := exporter.(utils.SummaryExporter); ok && err == nil {
+		var summary *models.LogSummary
+		if summary, err = dataBase.DBLogSummary(ctx, m.logs, filter); err == nil {
+			err = summaryExporter.AddSummary(summary)
+		}
+	}
+	if err != nil {
+		exporter.Abort()
+		return 0, err
+	}
+	if err := exporter.Close(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	return done, os.Rename(tmp.Name(), m.filePath(job))
+}
+
+// filePath возвращает путь к файлу готовой выгрузки
+func (m *Manager) filePath(job *models.ExportJob) string {
+	return filepath.Join(m.cfg.Dir, job.ID+"."+job.Format)
+}
+
+// cleanup периодически удаляет файлы выгрузок с истёкшим сроком хранения
+func (m *Manager) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(m.cfg.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		paths, err := dataBase.DBExpireExportJobs(ctx, m.db)
+		if err != nil && ctx.Err() == nil {
+			logger.Error("Ошибка очистки выгрузок: " + err.Error())
+		}
+		for _, path := range paths {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				logger.Error(fmt.Sprintf("Не удалось удалить файл выгрузки %s: %s", path, err.Error()))
+			}
+		}
+		if len(paths) > 0 {
+			logger.Info(fmt.Sprintf("Удалено файлов выгрузок с истёкшим сроком хранения: %d", len(paths)))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
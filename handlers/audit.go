@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/audit"
+	"Cloud/dataBase"
+	"Cloud/i18n"
+	"Cloud/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AuditPage — страница журнала аудита
+type AuditPage struct {
+	Items      []*models.AuditEntry `json:"items"`
+	NextBefore int64                `json:"next_before,omitempty"` // значение before для следующей страницы; пусто на последней
+}
+
+// ListAuditEntries возвращает записи журнала аудита от новых к старым.
+// @Summary Журнал аудита
+// @Description Действия с пользователями и события входа. Только для аудиторов.
+// @Tags audit
+// @Produce json
+// @Param actor_id query int false "Кто выполнил действие"
+// @Param target_id query int false "Над каким пользователем"
+// @Param action query string false "Действие, например user.update"
+// @Param from query string false "Начало интервала (RFC 3339, включительно)"
+// @Param to query string false "Конец интервала (RFC 3339, не включительно)"
+// @Param before query int false "Вернуть записи с ID меньше указанного"
+// @Param limit query int false "Количество записей (по умолчанию 50, не больше 1000)"
+// @Success 200 {object} AuditPage "Страница журнала"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+// @Router /audit [get]
+func ListAuditEntries(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		invalid := func(name string) {
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.query_param_invalid", name, query.Get(name))))
+		}
+
+		filter := dataBase.AuditFilter{Action: query.Get("action")}
+		limit := 50
+		var before int64
+
+		for name, dst := range map[string]*int{"actor_id": &filter.ActorID, "target_id": &filter.TargetID, "limit": &limit} {
+			if value := query.Get(name); value != "" {
+				n, err := strconv.Atoi(value)
+				if err != nil || n <= 0 {
+					invalid(name)
+					return
+				}
+				*dst = n
+			}
+		}
+		limit = min(limit, 1000)
+
+		for name, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+			if value := query.Get(name); value != "" {
+				t, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					invalid(name)
+					return
+				}
+				*dst = t
+			}
+		}
+
+		if value := query.Get("before"); value != "" {
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || n <= 0 {
+				invalid("before")
+				return
+			}
+			before = n
+		}
+
+		entries, err := dataBase.DBListAuditEntries(r.Context(), db, filter, before, limit)
+		if err != nil {
+			apperror.Write(w, r, fmt.Errorf("failed to list audit entries: %w", err))
+			return
+		}
+
+		page := AuditPage{Items: entries}
+		if len(entries) == limit {
+			page.NextBefore = entries[len(entries)-1].ID
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// VerifyAuditLog проверяет цепочку хешей журнала аудита.
+// @Summary Проверка целостности журнала аудита
+// @Description Пересчитывает хеши всех записей и сообщает о первой изменённой или удалённой записи. Только для аудиторов.
+// @Tags audit
+// @Produce json
+// @Success 200 {object} models.AuditVerification "Результат проверки"
+// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+// @Router /audit/verify [get]
+func VerifyAuditLog(auditor *audit.Auditor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := auditor.Verify(r.Context())
+		if err != nil {
+			apperror.Write(w, r, fmt.Errorf("failed to verify audit log: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(result)
+	}
+}
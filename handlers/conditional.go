@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// userETag возвращает сильный ETag версии пользователя
+func userETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// parseETags разбирает значение If-Match или If-None-Match: список ETag через запятую или "*".
+// Слабые ETag возвращаются с префиксом W/.
+func parseETags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// ifNoneMatch сообщает, что у клиента уже есть текущая версия ресурса с ETag etag и можно ответить 304.
+// По RFC 9110 If-None-Match сравнивает ETag слабо: W/"1" совпадает с "1".
+func ifNoneMatch(r *http.Request, etag string) bool {
+	for _, tag := range parseETags(r.Header.Get("If-None-Match")) {
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch проверяет заголовок If-Match запроса на изменение ресурса с ETag etag.
+// Без заголовка изменение запрещено (ErrPreconditionRequired), чтобы клиент не перезаписал чужие правки вслепую;
+// если ни один ETag не совпал строго, возвращает ErrPreconditionFailed.
+func checkIfMatch(r *http.Request, etag string) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return apperror.ErrPreconditionRequired
+	}
+	for _, tag := range parseETags(header) {
+		// Слабые ETag If-Match не принимает: они не гарантируют совпадение версии
+		if tag == "*" || tag == etag {
+			return nil
+		}
+	}
+	return apperror.ErrPreconditionFailed.Wrap(fmt.Errorf("If-Match %s, текущая версия %s", header, etag))
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/exports"
+	"Cloud/i18n"
+	"Cloud/models"
+	"Cloud/utils"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+)
+
+// ExportStatus — состояние задания на выгрузку
+type ExportStatus struct {
+	*models.ExportJob
+	Progress    float64 `json:"progress"`               // доля выполненной работы от 0 до 1
+	DownloadURL string  `json:"download_url,omitempty"` // подписанная ссылка на файл, когда выгрузка готова
+}
+
+// CreateExport ставит в очередь фоновую выгрузку логов запросов.
+// @Summary Создание выгрузки логов
+// @Description Принимает те же фильтры, что и /logs, и формат (xlsx, csv, ndjson; по умолчанию xlsx). Возвращает задание, состояние которого можно опрашивать по адресу из заголовка Location.
+// @Tags logs
+// @Produce json
+// @Param format query string false "Формат: xlsx, csv или ndjson"
+// @Success 202 {object} ExportStatus "Задание поставлено в очередь"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр или формат"
+// @Router /exports [post]
+func CreateExport(manager *exports.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		formatParam := r.URL.Query().Get("format")
+		format, err := utils.NegotiateExportFormat(formatParam, "")
+		if err != nil {
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.query_param_invalid", "format", formatParam)))
+			return
+		}
+
+		job, err := manager.Create(r.Context(), format, filter)
+		if err != nil {
+			apperror.Write(w, r, fmt.Errorf("failed to create export: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/exports/"+job.ID)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(ExportStatus{ExportJob: job, Progress: job.Progress()})
+	}
+}
+
+// GetExport возвращает состояние выгрузки и, когда она готова, ссылку на скачивание.
+// @Summary Состояние выгрузки логов
+// @Description Ссылка на скачивание подписана и действует ограниченное время; за новой ссылкой достаточно запросить состояние ещё раз.
+// @Tags logs
+// @Produce json
+// @Param id path string true "ID выгрузки"
+// @Success 200 {object} ExportStatus "Состояние выгрузки"
+// @Failure 404 {object} apperror.ErrorResponse "Выгрузка не найдена"
+// @Router /exports/{id} [get]
+func GetExport(manager *exports.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := manager.Get(r.Context(), mux.Vars(r)["id"])
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		status := ExportStatus{ExportJob: job, Progress: job.Progress()}
+		if job.Status == models.ExportDone {
+			status.DownloadURL = manager.DownloadURL(job)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(status)
+	}
+}
+
+// DownloadExport отдаёт файл готовой выгрузки по подписанной ссылке.
+// @Summary Скачивание выгрузки логов
+// @Tags logs
+// @Produce application/octet-stream
+// @Param id path string true "ID выгрузки"
+// @Param expires query int true "Время истечения ссылки (Unix)"
+// @Param signature query string true "Подпись ссылки"
+// @Success 200 {file} file "Файл выгрузки"
+// @Failure 403 {object} apperror.ErrorResponse "Ссылка недействительна или устарела"
+// @Failure 410 {object} apperror.ErrorResponse "Срок хранения файла истёк"
+// @Router /exports/{id}/download [get]
+func DownloadExport(manager *exports.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		file, job, err := manager.Open(r.Context(), mux.Vars(r)["id"], query.Get("expires"), query.Get("signature"))
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+		defer file.Close()
+
+		name := "request_logs_" + job.ID + "." + job.Format
+		w.Header().Set("Content-Type", utils.ExportContentType(job.Format))
+		w.Header().Set("Content-Disposition", "attachment; filename="+name)
+
+		// ServeContent поддерживает докачку через Range
+		http.ServeContent(w, r, name, *job.FinishedAt, file)
+	}
+}
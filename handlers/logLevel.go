@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/i18n"
+	"Cloud/logger"
+	"encoding/json"
+	"net/http"
+)
+
+// LogLevel — уровень логирования приложения
+type LogLevel struct {
+	Level string `json:"level"` // debug, info, warn или error
+}
+
+// GetLogLevel возвращает текущий уровень логирования.
+// @Summary Уровень логирования
+// @Tags logger
+// @Produce json
+// @Success 200 {object} LogLevel "Текущий уровень"
+// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+// @Router /admin/log-level [get]
+func GetLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LogLevel{Level: logger.Level()})
+	}
+}
+
+// SetLogLevel меняет уровень логирования без перезапуска приложения.
+// Изменение действует до перезапуска, затем уровень снова берётся из LOG_LEVEL.
+// @Summary Изменение уровня логирования
+// @Tags logger
+// @Accept json
+// @Produce json
+// @Param level body LogLevel true "Новый уровень"
+// @Success 200 {object} LogLevel "Установленный уровень"
+// @Failure 400 {object} apperror.ErrorResponse "Неизвестный уровень"
+// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+// @Router /admin/log-level [put]
+func SetLogLevel() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req LogLevel
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
+			return
+		}
+
+		previous := logger.Level()
+		if err := logger.SetLevel(req.Level); err != nil {
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.log_level_invalid", req.Level)))
+			return
+		}
+		logger.WarningContext(r.Context(), "Уровень логирования изменён", "from", previous, "to", logger.Level())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(LogLevel{Level: logger.Level()})
+	}
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/dataBase"
+	"Cloud/i18n"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"strconv"
+)
+
+// RequestsByEndpointStats возвращает количество запросов по endpoint и интервалам времени.
+// @Summary Запросы по endpoint
+// @Description Принимает фильтры /logs. Интервал: minute, hour (по умолчанию), day, week или month.
+// @Tags logs
+// @Produce json
+// @Param bucket query string false "Интервал группировки"
+// @Success 200 {array} models.EndpointBucketStat "Количество запросов"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+// @Router /logs/stats/endpoints [get]
+func RequestsByEndpointStats(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		bucket := r.URL.Query().Get("bucket")
+		if bucket == "" {
+			bucket = dataBase.BucketHour
+		}
+		if !dataBase.IsValidBucket(bucket) {
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.query_param_invalid", "bucket", bucket)))
+			return
+		}
+
+		stats, err := dataBase.DBRequestsByEndpoint(r.Context(), dataBase.RequestLogCollection(client), filter, bucket)
+		writeStats(w, r, stats, err)
+	}
+}
+
+// LatencyStats возвращает перцентили длительности запросов.
+// @Summary Перцентили длительности запросов
+// @Description Принимает фильтры /logs. По умолчанию статистика по каждому endpoint; group=all — по всем запросам сразу.
+// @Tags logs
+// @Produce json
+// @Param group query string false "endpoint или all"
+// @Success 200 {array} models.LatencyStat "p50/p95/p99 в миллисекундах"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+// @Router /logs/stats/latency [get]
+func LatencyStats(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		var byEndpoint bool
+		switch group := r.URL.Query().Get("group"); group {
+		case "", "endpoint":
+			byEndpoint = true
+		case "all":
+		default:
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.query_param_invalid", "group", group)))
+			return
+		}
+
+		stats, err := dataBase.DBLatencyPercentiles(r.Context(), dataBase.RequestLogCollection(client), filter, byEndpoint)
+		writeStats(w, r, stats, err)
+	}
+}
+
+// StatusClassStats возвращает количество и долю ответов каждого класса.
+// @Summary Ответы по классам статусов
+// @Description Принимает фильтры /logs. Доля 4xx и 5xx — это частота ошибок.
+// @Tags logs
+// @Produce json
+// @Success 200 {array} models.StatusClassStat "Классы ответов"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+// @Router /logs/stats/status [get]
+func StatusClassStats(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		stats, err := dataBase.DBStatusClasses(r.Context(), dataBase.RequestLogCollection(client), filter)
+		writeStats(w, r, stats, err)
+	}
+}
+
+// TopStats возвращает самых активных пользователей или IP-адреса.
+// @Summary Топ пользователей и IP-адресов
+// @Description Принимает фильтры /logs. by=user (по умолчанию) или by=ip; limit — от 1 до 100, по умолчанию 10.
+// @Tags logs
+// @Produce json
+// @Param by query string false "user или ip"
+// @Param limit query int false "Количество записей"
+// @Success 200 {array} models.TopStat "Топ"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+// @Router /logs/stats/top [get]
+func TopStats(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		query := r.URL.Query()
+		var field string
+		switch by := query.Get("by"); by {
+		case "", "user":
+			field = dataBase.TopByUser
+		case "ip":
+			field = dataBase.TopByIP
+		default:
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.query_param_invalid", "by", by)))
+			return
+		}
+
+		limit := 10
+		if value := query.Get("limit"); value != "" {
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit <= 0 || limit > 100 {
+				apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.query_param_invalid", "limit", value)))
+				return
+			}
+		}
+
+		stats, err := dataBase.DBTopValues(r.Context(), dataBase.RequestLogCollection(client), filter, field, limit)
+		writeStats(w, r, stats, err)
+	}
+}
+
+// UniqueUsersStats возвращает количество уникальных пользователей по дням.
+// @Summary Уникальные пользователи по дням
+// @Description Принимает фильтры /logs. Учитываются только запросы авторизованных пользователей.
+// @Tags logs
+// @Produce json
+// @Success 200 {array} models.DailyUsersStat "Пользователи по дням"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+// @Router /logs/stats/unique-users [get]
+func UniqueUsersStats(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		stats, err := dataBase.DBUniqueUsersPerDay(r.Context(), dataBase.RequestLogCollection(client), filter)
+		writeStats(w, r, stats, err)
+	}
+}
+
+// writeStats отправляет результат агрегации или ошибку
+func writeStats(w http.ResponseWriter, r *http.Request, stats any, err error) {
+	if err != nil {
+		apperror.Write(w, r, fmt.Errorf("failed to aggregate logs: %w", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
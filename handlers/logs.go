@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/dataBase"
+	"Cloud/i18n"
+	"Cloud/logger"
+	"Cloud/models"
+	"Cloud/utils"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RequestLogPage — страница логов запросов
+type RequestLogPage struct {
+	Items      []models.RequestLog `json:"items"`
+	NextCursor string              `json:"next_cursor,omitempty"` // пусто на последней странице
+}
+
+// ListRequestLogs возвращает логи запросов по фильтрам с постраничной выдачей по курсору.
+// @Summary Логи запросов
+// @Description Возвращает логи запросов от новых к старым. Для следующей страницы передайте next_cursor из ответа в параметре cursor.
+// @Tags logs
+// @Produce json
+// @Param from query string false "Начало интервала (RFC 3339, включительно)"
+// @Param to query string false "Конец интервала (RFC 3339, не включительно)"
+// @Param method query string false "HTTP-метод"
+// @Param endpoint query string false "Путь; * соответствует любой последовательности символов, например /user/*"
+// @Param route query string false "Шаблон маршрута, например /user/{id}"
+// @Param request_id query string false "Идентификатор запроса (X-Request-ID)"
+// @Param user_id query string false "ID пользователя"
+// @Param status_min query int false "Минимальный код ответа"
+// @Param status_max query int false "Максимальный код ответа"
+// @Param ip query string false "IP-адрес клиента"
+// @Param min_duration query string false "Минимальная длительность: 250ms, 1.5s или число миллисекунд"
+// @Param limit query int false "Количество записей (по умолчанию 50, не больше 1000)"
+// @Param cursor query string false "Курсор следующей страницы"
+// @Success 200 {object} RequestLogPage "Страница логов"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+// @Router /logs [get]
+func ListRequestLogs(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		query := r.URL.Query()
+		limit := 50
+		if value := query.Get("limit"); value != "" {
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit <= 0 {
+				apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.query_param_invalid", "limit", value)))
+				return
+			}
+			limit = min(limit, 1000)
+		}
+
+		var cursor *dataBase.LogCursor
+		if value := query.Get("cursor"); value != "" {
+			cursor, err = dataBase.DecodeLogCursor(value)
+			if err != nil {
+				apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.cursor_invalid")))
+				return
+			}
+		}
+
+		logs, next, err := dataBase.DBFindRequestLogs(r.Context(), dataBase.RequestLogCollection(client), filter, cursor, limit)
+		if err != nil {
+			apperror.Write(w, r, fmt.Errorf("failed to get logs: %w", err))
+			return
+		}
+
+		page := RequestLogPage{Items: logs}
+		if next != nil {
+			page.NextCursor = next.Encode()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(page)
+	}
+}
+
+// ExportRequestLogs выгружает логи запросов, подходящие под фильтры, в xlsx, CSV или NDJSON.
+// Записи читаются из MongoDB и пишутся в ответ потоком, поэтому выгрузка не ограничена объёмом памяти.
+// @Summary Выгрузка логов запросов
+// @Description Принимает те же фильтры, что и /logs. Формат выбирается параметром format (xlsx, csv, ndjson) или заголовком Accept; по умолчанию xlsx.
+// @Tags logs
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param format query string false "Формат: xlsx, csv или ndjson"
+// @Param summary query bool false "Добавить в xlsx сводные листы с диаграммами (по умолчанию true)"
+// @Success 200 {file} file "Файл с логами запросов"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+// @Failure 406 {object} apperror.ErrorResponse "Неподдерживаемый формат"
+// @Router /logs/export [get]
+func ExportRequestLogs(client *mongo.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseLogFilter(r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		formatParam := r.URL.Query().Get("format")
+		format, err := utils.NegotiateExportFormat(formatParam, r.Header.Get("Accept"))
+		if err != nil {
+			if formatParam != "" {
+				apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.query_param_invalid", "format", formatParam)))
+			} else {
+				apperror.Write(w, r, apperror.ErrNotAcceptable.Wrap(err))
+			}
+			return
+		}
+
+		out := &trackingWriter{ResponseWriter: w}
+		exporter, err := utils.NewLogExporter(format, out)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", utils.ExportContentType(format))
+		w.Header().Set("Content-Disposition", "attachment; filename=request_logs."+format)
+		w.Header().Add("Vary", "Accept")
+
+		collection := dataBase.RequestLogCollection(client)
+		err = dataBase.DBEachRequestLog(r.Context(), collection, filter, exporter.Write)
+		if err == nil {
+			err = addSummary(r, collection, filter, exporter)
+		}
+		if err == nil {
+			err = exporter.Close()
+		} else {
+			exporter.Abort()
+		}
+		if err == nil {
+			return
+		}
+
+		// Пока в ответ ничего не записано, можно вернуть обычную ошибку; иначе файл уже частично у клиента
+		if !out.written {
+			w.Header().Del("Content-Disposition")
+			apperror.Write(w, r, fmt.Errorf("failed to export request logs: %w", err))
+			return
+		}
+		logger.ErrorContext(r.Context(), "Не удалось выгрузить журнал запросов", "error", err.Error())
+	}
+}
+
+// addSummary добавляет в выгрузку сводные листы, если формат их поддерживает и клиент не отказался от них (summary=false)
+func addSummary(r *http.Request, collection *mongo.Collection, filter dataBase.RequestLogFilter, exporter utils.LogExporter) error {
+	summaryExporter, ok := exporter.(utils.SummaryExporter)
+	if !ok || r.URL.Query().Get("summary") == "false" {
+		return nil
+	}
+
+	summary, err := dataBase.DBLogSummary(r.Context(), collection, filter)
+	if err != nil {
+		return err
+	}
+	return summaryExporter.AddSummary(summary)
+}
+
+// trackingWriter запоминает, начата ли запись тела ответа
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (t *trackingWriter) Write(p []byte) (int, error) {
+	t.written = true
+	return t.ResponseWriter.Write(p)
+}
+
+// parseLogFilter читает фильтры логов из параметров запроса
+func parseLogFilter(r *http.Request) (dataBase.RequestLogFilter, error) {
+	query := r.URL.Query()
+	filter := dataBase.RequestLogFilter{
+		Method:          query.Get("method"),
+		EndpointPattern: query.Get("endpoint"),
+		Route:           query.Get("route"),
+		RequestID:       query.Get("request_id"),
+		UserID:          query.Get("user_id"),
+		IP:              query.Get("ip"),
+	}
+
+	invalid := func(name string) error {
+		return apperror.Validation(i18n.NewError("validation.query_param_invalid", name, query.Get(name)))
+	}
+
+	for name, dst := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return filter, invalid(name)
+			}
+			*dst = t
+		}
+	}
+
+	for name, dst := range map[string]*int{"status_min": &filter.StatusMin, "status_max": &filter.StatusMax} {
+		if value := query.Get(name); value != "" {
+			code, err := strconv.Atoi(value)
+			if err != nil || code < 100 || code > 599 {
+				return filter, invalid(name)
+			}
+			*dst = code
+		}
+	}
+
+	if value := query.Get("min_duration"); value != "" {
+		// Число без единиц измерения считаем миллисекундами
+		if ms, err := strconv.ParseFloat(value, 64); err == nil && ms >= 0 {
+			filter.MinDuration = time.Duration(ms * float64(time.Millisecond))
+		} else if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+			filter.MinDuration = d
+		} else {
+			return filter, invalid("min_duration")
+		}
+	}
+
+	return filter, nil
+}
@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"crypto/subtle"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// dbPoolMetrics — метрики пула соединений в порядке вывода
+var dbPoolMetrics = []struct {
+	name, kind, help string
+	value            func(s sql.DBStats) float64
+}{
+	{"cloud_db_max_open_connections", "gauge", "Ограничение на количество открытых соединений.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) }},
+	{"cloud_db_open_connections", "gauge", "Открытые соединения: занятые и простаивающие.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) }},
+	{"cloud_db_in_use_connections", "gauge", "Соединения, занятые запросами.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) }},
+	{"cloud_db_idle_connections", "gauge", "Простаивающие соединения.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) }},
+	{"cloud_db_wait_count_total", "counter", "Сколько раз запрос ждал свободного соединения.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) }},
+	{"cloud_db_wait_duration_seconds_total", "counter", "Суммарное время ожидания свободного соединения.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() }},
+	{"cloud_db_max_idle_closed_total", "counter", "Соединения, закрытые из-за ограничения MaxIdleConns.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) }},
+	{"cloud_db_max_idle_time_closed_total", "counter", "Соединения, закрытые из-за ConnMaxIdleTime.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleTimeClosed) }},
+	{"cloud_db_max_lifetime_closed_total", "counter", "Соединения, закрытые из-за ConnMaxLifetime.",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) }},
+}
+
+// Metrics отдаёт статистику пулов соединений PostgreSQL в текстовом формате Prometheus.
+// Запрос должен содержать заголовок "Authorization: Bearer <token>"; пустой token отклоняет все запросы.
+// @Summary Метрики приложения
+// @Description Статистика пулов соединений с базой данных в формате Prometheus.
+// @Tags metrics
+// @Produce plain
+// @Success 200 {string} string "Метрики"
+// @Failure 401 {object} apperror.ErrorResponse "Неверный токен"
+// @Router /metrics [get]
+func Metrics(pools map[string]*sql.DB, token string) http.HandlerFunc {
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			apperror.Write(w, r, apperror.ErrUnauthorized)
+			return
+		}
+
+		stats := make([]sql.DBStats, len(names))
+		for i, name := range names {
+			stats[i] = pools[name].Stats()
+		}
+
+		var b strings.Builder
+		for _, m := range dbPoolMetrics {
+			fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+			for i, name := range names {
+				fmt.Fprintf(&b, "%s{pool=%q} %g\n", m.name, name, m.value(stats[i]))
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(b.String()))
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/dataBase"
+	"Cloud/models"
+	"Cloud/utils"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+// GetNotificationPreferences возвращает настройки уведомлений пользователя.
+// @Summary Настройки уведомлений
+// @Description Возвращает, какие письма о событиях безопасности получает пользователь. Доступно самому пользователю и администраторам.
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} models.NotificationPreferences "Настройки уведомлений"
+// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+// @Failure 404 {object} apperror.ErrorResponse "Пользователь не найден"
+// @Router /user/{id}/notifications [get]
+func GetNotificationPreferences(db *sql.DB, users dataBase.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := selfOrAdmin(users, r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		prefs, err := dataBase.DBGetNotificationPreferences(r.Context(), db, userID)
+		if err != nil {
+			apperror.Write(w, r, fmt.Errorf("failed to get notification preferences: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(prefs)
+	}
+}
+
+// UpdateNotificationPreferences изменяет настройки уведомлений пользователя.
+// Поля, отсутствующие в запросе, сохраняют текущие значения.
+// @Summary Изменение настроек уведомлений
+// @Description Включает или отключает письма о событиях безопасности. Доступно самому пользователю и администраторам.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param preferences body models.NotificationPreferences true "Настройки уведомлений"
+// @Success 200 {object} models.NotificationPreferences "Сохранённые настройки"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный запрос"
+// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+// @Router /user/{id}/notifications [put]
+func UpdateNotificationPreferences(db *sql.DB, users dataBase.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := selfOrAdmin(users, r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		prefs, err := dataBase.DBGetNotificationPreferences(r.Context(), db, userID)
+		if err != nil {
+			apperror.Write(w, r, fmt.Errorf("failed to get notification preferences: %w", err))
+			return
+		}
+
+		// Декодируем поверх текущих настроек
+		if err := json.NewDecoder(r.Body).Decode(prefs); err != nil {
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
+			return
+		}
+		prefs.UserID = userID
+
+		if err := dataBase.DBSaveNotificationPreferences(r.Context(), db, prefs); err != nil {
+			apperror.Write(w, r, fmt.Errorf("failed to save notification preferences: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(prefs)
+	}
+}
+
+// selfOrAdmin возвращает ID пользователя из пути, если запрос сделан им самим или администратором
+func selfOrAdmin(users dataBase.UserRepository, r *http.Request) (int, error) {
+	userID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return 0, apperror.ErrInvalidID.Wrap(err)
+	}
+
+	currentID, ok := utils.UserIDFromContext(r.Context())
+	if !ok {
+		return 0, apperror.ErrUnauthorized
+	}
+	if currentID == userID {
+		return userID, nil
+	}
+
+	role, err := users.GetRole(r.Context(), currentID)
+	if err != nil {
+		return 0, err
+	}
+	if role != models.RoleAdmin {
+		return 0, apperror.ErrForbidden.Wrap(fmt.Errorf("user %d cannot access user %d", currentID, userID))
+	}
+
+	// Администратор может работать только с существующими пользователями
+	if _, err := users.Get(r.Context(), userID); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/dataBase"
+	"Cloud/i18n"
+	"Cloud/models"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+// ListOutboxMessages возвращает письма из очереди исходящей почты.
+// @Summary Список писем в очереди
+// @Description Возвращает письма из очереди исходящей почты с фильтром по статусу (pending, sending, sent, dead). Только для администраторов.
+// @Tags admin
+// @Produce json
+// @Param status query string false "Статус письма"
+// @Param page query int false "Номер страницы"
+// @Param limit query int false "Количество писем на странице"
+// @Success 200 {array} models.OutboxMessage "Письма"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный статус"
+// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+// @Router /admin/emails [get]
+func ListOutboxMessages(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := r.URL.Query().Get("status")
+		switch status {
+		case "", models.OutboxPending, models.OutboxSending, models.OutboxSent, models.OutboxDead:
+		default:
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.outbox_status_invalid", status)))
+			return
+		}
+
+		page, limit := pagination(r)
+		messages, err := dataBase.DBListOutbox(r.Context(), db, status, limit, (page-1)*limit)
+		if err != nil {
+			apperror.Write(w, r, fmt.Errorf("failed to list outbox: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(messages)
+	}
+}
+
+// RetryOutboxMessage возвращает неотправленное письмо в очередь.
+// @Summary Повторная отправка письма
+// @Description Сбрасывает счётчик попыток письма и ставит его в очередь на немедленную отправку. Только для администраторов.
+// @Tags admin
+// @Param id path int true "ID письма"
+// @Success 204 "Письмо возвращено в очередь"
+// @Failure 404 {object} apperror.ErrorResponse "Письмо не найдено"
+// @Failure 409 {object} apperror.ErrorResponse "Письмо уже отправлено"
+// @Router /admin/emails/{id}/retry [post]
+func RetryOutboxMessage(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+		if err != nil {
+			apperror.Write(w, r, apperror.ErrInvalidID.Wrap(err))
+			return
+		}
+
+		if err := dataBase.DBRetryOutboxMessage(r.Context(), db, id); err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// pagination читает параметры page и limit: по умолчанию первая страница по 10 записей, не больше 100 на странице
+func pagination(r *http.Request) (page, limit int) {
+	page, limit = 1, 10
+
+	if p, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && p > 0 {
+		page = p
+	}
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 {
+		limit = min(l, 100)
+	}
+	return page, limit
+}
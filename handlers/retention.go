@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/retention"
+	"encoding/json"
+	"net/http"
+)
+
+// GetLogRetention возвращает сроки хранения логов, размеры коллекций и результаты последней архивации.
+// @Summary Хранение логов
+// @Description Сроки хранения логов запросов и событий безопасности, TTL-индексы, размер коллекций и последняя архивация.
+// @Tags logs
+// @Produce json
+// @Success 200 {object} models.RetentionStatus "Состояние хранения логов"
+// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+// @Router /admin/logs/retention [get]
+func GetLogRetention(manager *retention.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status, err := manager.Status(r.Context())
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(status)
+	}
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/audit"
+	"Cloud/dataBase"
+	"Cloud/i18n"
+	"Cloud/models"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+// RoleRequest — новая роль пользователя
+type RoleRequest struct {
+	Role string `json:"role"` // user, admin или auditor
+}
+
+// SetUserRole меняет роль пользователя.
+// @Summary Изменение роли пользователя
+// @Description Только для администраторов. Изменение записывается в журнал аудита.
+// @Tags admin
+// @Accept json
+// @Param id path int true "ID пользователя"
+// @Param role body RoleRequest true "Новая роль"
+// @Success 204 "Роль изменена"
+// @Failure 400 {object} apperror.ErrorResponse "Неизвестная роль"
+// @Failure 404 {object} apperror.ErrorResponse "Пользователь не найден"
+// @Router /admin/users/{id}/role [put]
+func SetUserRole(users dataBase.UserRepository, transactor dataBase.Transactor, auditor *audit.Auditor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			apperror.Write(w, r, apperror.ErrInvalidID.Wrap(err))
+			return
+		}
+
+		var req RoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
+			return
+		}
+		if !models.IsValidRole(req.Role) {
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.role_invalid", req.Role)))
+			return
+		}
+
+		// Прежняя роль читается в той же транзакции, что и изменение, чтобы журнал аудита не разошёлся с фактом
+		err = transactor.WithTx(r.Context(), func(tx *dataBase.Tx) error {
+			previous, err := tx.Users.GetRole(r.Context(), userID)
+			if err != nil {
+				return err
+			}
+			if err := tx.Users.SetRole(r.Context(), userID, req.Role); err != nil {
+				return fmt.Errorf("failed to set user role: %w", err)
+			}
+			if previous == req.Role {
+				return nil
+			}
+			return auditor.RecordTx(r, tx, audit.Event{
+				Action:   models.AuditUserRoleChange,
+				TargetID: userID,
+				Changes:  map[string]models.FieldChange{"role": {Before: previous, After: req.Role}},
+			})
+		})
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
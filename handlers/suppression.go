@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/dataBase"
+	"Cloud/i18n"
+	"Cloud/logger"
+	"Cloud/models"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/gorilla/mux"
+	"net/http"
+	"os"
+)
+
+// WebhookSecretHeader — заголовок с общим секретом, которым почтовый провайдер подписывает вебхуки
+const WebhookSecretHeader = "X-Webhook-Secret"
+
+// Типы событий вебхука
+const (
+	EmailEventBounce    = "bounce"
+	EmailEventComplaint = "complaint"
+)
+
+// EmailEvent — событие о доставке письма от почтового провайдера
+type EmailEvent struct {
+	Type       string `json:"type"`        // bounce или complaint
+	Email      string `json:"email"`       // адрес получателя
+	BounceType string `json:"bounce_type"` // hard или soft; учитывается только для bounce
+	Detail     string `json:"detail"`      // ответ почтового сервера или текст жалобы
+	Source     string `json:"source"`      // имя провайдера
+}
+
+// EmailEventsRequest — тело вебхука: пакет событий
+type EmailEventsRequest struct {
+	Events []EmailEvent `json:"events"`
+}
+
+// EmailEventsResponse — результат обработки вебхука
+type EmailEventsResponse struct {
+	Suppressed int `json:"suppressed"` // адресов добавлено в список подавления
+	Ignored    int `json:"ignored"`    // событий без последствий (временные отказы)
+}
+
+// EmailEventsWebhook принимает события об отказах доставки и жалобах и пополняет список подавления.
+// Временные отказы (soft bounce) игнорируются: почтовый сервер получателя может снова начать принимать письма.
+// @Summary Вебхук событий доставки писем
+// @Description Принимает пакет событий bounce/complaint в общем JSON-формате. Запрос должен содержать заголовок X-Webhook-Secret со значением MAIL_WEBHOOK_SECRET.
+// @Tags email
+// @Accept json
+// @Produce json
+// @Param events body EmailEventsRequest true "События"
+// @Success 200 {object} EmailEventsResponse "Результат обработки"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректное событие"
+// @Failure 401 {object} apperror.ErrorResponse "Неверный секрет"
+// @Router /webhooks/email [post]
+func EmailEventsWebhook(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Без настроенного секрета вебхук отключён, чтобы кто угодно не мог подавлять чужие адреса
+		secret := os.Getenv("MAIL_WEBHOOK_SECRET")
+		if secret == "" || subtle.ConstantTimeCompare([]byte(r.Header.Get(WebhookSecretHeader)), []byte(secret)) != 1 {
+			apperror.Write(w, r, apperror.ErrUnauthorized.Wrap(fmt.Errorf("неверный секрет вебхука")))
+			return
+		}
+
+		var request EmailEventsRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
+			return
+		}
+
+		// Проверяем весь пакет до изменений, чтобы не применить его частично
+		for i, event := range request.Events {
+			if event.Email == "" {
+				apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.webhook_email_missing", i+1)))
+				return
+			}
+			if event.Type != EmailEventBounce && event.Type != EmailEventComplaint {
+				apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.webhook_type_invalid", i+1, event.Type)))
+				return
+			}
+		}
+
+		var response EmailEventsResponse
+		for _, event := range request.Events {
+			reason := models.SuppressionComplaint
+			if event.Type == EmailEventBounce {
+				if event.BounceType == "soft" {
+					response.Ignored++
+					continue
+				}
+				reason = models.SuppressionBounce
+			}
+
+			suppression := &models.Suppression{Email: event.Email, Reason: reason, Detail: event.Detail, Source: event.Source}
+			if err := dataBase.DBAddSuppression(r.Context(), db, suppression); err != nil {
+				apperror.Write(w, r, fmt.Errorf("failed to add suppression: %w", err))
+				return
+			}
+			logger.InfoContext(r.Context(), "Адрес добавлен в список подавления", "email", suppression.Email, "reason", reason)
+			response.Suppressed++
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// ListSuppressions возвращает список подавления.
+// @Summary Список подавления
+// @Description Возвращает адреса, на которые не отправляются письма, с фильтром по причине (bounce, complaint, manual). Только для администраторов.
+// @Tags admin
+// @Produce json
+// @Param reason query string false "Причина"
+// @Param page query int false "Номер страницы"
+// @Param limit query int false "Количество записей на странице"
+// @Success 200 {array} models.Suppression "Записи списка подавления"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректная причина"
+// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+// @Router /admin/suppressions [get]
+func ListSuppressions(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		reason := r.URL.Query().Get("reason")
+		switch reason {
+		case "", models.SuppressionBounce, models.SuppressionComplaint, models.SuppressionManual:
+		default:
+			apperror.Write(w, r, apperror.Validation(i18n.NewError("validation.suppression_reason_invalid", reason)))
+			return
+		}
+
+		page, limit := pagination(r)
+		suppressions, err := dataBase.DBListSuppressions(r.Context(), db, reason, limit, (page-1)*limit)
+		if err != nil {
+			apperror.Write(w, r, fmt.Errorf("failed to list suppressions: %w", err))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(suppressions)
+	}
+}
+
+// DeleteSuppression убирает адрес из списка подавления, и письма на него снова отправляются.
+// @Summary Удаление адреса из списка подавления
+// @Description Только для администраторов.
+// @Tags admin
+// @Param email path string true "Адрес электронной почты"
+// @Success 204 "Адрес удалён из списка"
+// @Failure 404 {object} apperror.ErrorResponse "Адреса нет в списке"
+// @Router /admin/suppressions/{email} [delete]
+func DeleteSuppression(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		address := mux.Vars(r)["email"]
+
+		if err := dataBase.DBDeleteSuppression(r.Context(), db, address); err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+		logger.InfoContext(r.Context(), "Адрес удалён из списка подавления", "email", address)
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
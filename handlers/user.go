@@ -1,18 +1,21 @@
 package handlers
 
 import (
+	"Cloud/apperror"
+	"Cloud/audit"
 	"Cloud/dataBase"
-	"Cloud/logger"
+	"Cloud/i18n"
 	"Cloud/models"
+	"Cloud/notify"
 	"Cloud/utils"
 	"context"
-	"database/sql"
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -24,25 +27,24 @@ import (
 // @Produce json
 // @Param user body models.User true "User data"
 // @Success 201 {string} string "User created successfully"
-// @Failure 400 {string} string "Invalid request format"
-// @Failure 500 {string} string "Internal server error"
+// @Failure 400 {object} apperror.ErrorResponse "Invalid request format"
+// @Failure 500 {object} apperror.ErrorResponse "Internal server error"
+// @Failure 409 {object} apperror.ErrorResponse "Email or phone already taken"
 // @Router /users [post]
-func CreateUser(db *sql.DB) http.HandlerFunc {
+func CreateUser(users dataBase.UserRepository, auditor *audit.Auditor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var user models.User
 
 		//Декодирование JSON из тела запроса
 		err := json.NewDecoder(r.Body).Decode(&user)
 		if err != nil {
-			logger.Error("Ошибка декодирования JSON: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
 			return
 		}
 
 		//Валидация данных пользователя
 		if err := utils.ValidateUserForCreate(user); err != nil {
-			logger.Error("User validation failed!" + err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apperror.Write(w, r, apperror.Validation(err))
 			return
 		}
 
@@ -53,22 +55,26 @@ func CreateUser(db *sql.DB) http.HandlerFunc {
 		// Хеширование пароля перед сохранением
 		user.Password, err = utils.HashPassword(user.Password)
 		if err != nil {
-			logger.Error("Failed to hash password: " + err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			apperror.Write(w, r, fmt.Errorf("failed to hash password: %w", err))
 			return
 		}
 
 		//Запрос к базе данных
-		err = dataBase.DBCreateUser(db, &user)
+		err = users.Create(r.Context(), &user)
 		if err != nil {
-			logger.Error("Failed to create user: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, fmt.Errorf("failed to create user: %w", err))
 			return
 		}
 
+		auditor.Record(r, audit.Event{
+			Action:   models.AuditUserCreate,
+			TargetID: user.ID,
+			Changes:  audit.UserChanges(nil, &user, false),
+		})
+
 		// Успешный ответ
-		w.WriteHeader(http.StatusCreated)                                                  // устанавливается статус ответа 201 Created
-		json.NewEncoder(w).Encode("User " + user.Name + " has been successfully created!") // сериализует объект "user" в JSON и отправляет в "w"(ответ)
+		w.WriteHeader(http.StatusCreated)                                                           // устанавливается статус ответа 201 Created
+		json.NewEncoder(w).Encode(i18n.T(i18n.FromContext(r.Context()), "user.created", user.Name)) // сериализует сообщение в JSON и отправляет в "w"(ответ)
 	}
 }
 
@@ -78,28 +84,40 @@ func CreateUser(db *sql.DB) http.HandlerFunc {
 // @Tags users
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-None-Match header string false "ETag of the cached version"
 // @Success 200 {object} models.User "User data"
-// @Failure 400 {string} string "Invalid ID"
-// @Failure 404 {string} string "User not found"
+// @Success 304 "The cached version is current"
+// @Header 200,304 {string} ETag "Version of the user"
+// @Failure 400 {object} apperror.ErrorResponse "Invalid ID"
+// @Failure 404 {object} apperror.ErrorResponse "User not found"
 // @Router /users/{id} [get]
-func GetUser(db *sql.DB) http.HandlerFunc {
+func GetUser(users dataBase.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		params := mux.Vars(r)
 		userID, err := strconv.Atoi(params["id"])
 		if err != nil {
-			logger.Error("Failed to parse id in 'GetUser': " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, apperror.ErrInvalidID.Wrap(err))
 			return
 		}
 
 		//Запрос к базе данных
-		user, err := dataBase.DBGetUser(db, userID)
+		user, err := users.Get(r.Context(), userID)
 		if err != nil {
-			logger.Error("Failed to get user: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, fmt.Errorf("failed to get user: %w", err))
+			return
+		}
+
+		// Клиент уже знает текущую версию — тело не нужно
+		etag := userETag(user.Version)
+		w.Header().Set("ETag", etag)
+		if ifNoneMatch(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
 			return
 		}
 
+		// Хеш пароля клиенту не нужен
+		user.Password = ""
+
 		// Успешный ответ
 		json.NewEncoder(w).Encode(user)
 	}
@@ -116,9 +134,9 @@ func GetUser(db *sql.DB) http.HandlerFunc {
 // @Param email query string false "Фильтр по email"
 // @Param phone query string false "Фильтр по телефону"
 // @Success 200 {array} models.User "Список пользователей"
-// @Failure 400 {string} string "Некорректный запрос"
+// @Failure 400 {object} apperror.ErrorResponse "Некорректный запрос"
 // @Router /users [get]
-func GetAllUsers(db *sql.DB) http.HandlerFunc {
+func GetAllUsers(users dataBase.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		pageStr := r.URL.Query().Get("page")
 		limitStr := r.URL.Query().Get("limit")
@@ -166,17 +184,21 @@ func GetAllUsers(db *sql.DB) http.HandlerFunc {
 		}
 
 		// Получаем пользователей из базы данных с учётом фильтров и постраничности
-		users, err := dataBase.DBGetAllUsers(db, filter, limit, offset)
+		list, err := users.List(r.Context(), filter, limit, offset)
 		if err != nil {
-			logger.Error("Failed to get all users: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, fmt.Errorf("failed to get all users: %w", err))
 			return
 		}
 
+		// Хеши паролей клиенту не нужны
+		for _, user := range list {
+			user.Password = ""
+		}
+
 		// Возвращаем пользователей в формате JSON
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(users)
+		json.NewEncoder(w).Encode(list)
 	}
 }
 
@@ -187,36 +209,61 @@ func GetAllUsers(db *sql.DB) http.HandlerFunc {
 // @Accept json
 // @Produce json
 // @Param id path int true "User ID"
+// @Param If-Match header string true "ETag of the version being modified"
 // @Param user body models.User true "User data"
 // @Success 204 "User updated successfully"
-// @Failure 400 {string} string "Invalid request"
-// @Failure 404 {string} string "User not found"
+// @Header 204 {string} ETag "New version of the user"
+// @Failure 400 {object} apperror.ErrorResponse "Invalid request"
+// @Failure 401 {object} apperror.ErrorResponse "Authorization required"
+// @Failure 403 {object} apperror.ErrorResponse "The caller cannot change this user or field"
+// @Failure 404 {object} apperror.ErrorResponse "User not found"
+// @Failure 409 {object} apperror.ErrorResponse "Email or phone taken by another user"
+// @Failure 412 {object} apperror.ErrorResponse "The user was modified by another request"
+// @Failure 428 {object} apperror.ErrorResponse "If-Match header is missing"
 // @Router /users/{id} [put]
-func UpdateUser(db *sql.DB) http.HandlerFunc {
+func UpdateUser(users dataBase.UserRepository, transactor dataBase.Transactor, notifier *notify.Notifier, auditor *audit.Auditor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := mux.Vars(r)
-		userID, err := strconv.Atoi(params["id"])
+		userID, role, err := userAccess(users, r)
 		if err != nil {
-			logger.Error("Failed to parse id in 'UpdateUser': " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, err)
+			return
+		}
+
+		// Без If-Match изменение отклоняется до разбора тела
+		if r.Header.Get("If-Match") == "" {
+			apperror.Write(w, r, apperror.ErrPreconditionRequired)
 			return
 		}
 
 		var user models.User
 		err = json.NewDecoder(r.Body).Decode(&user)
 		if err != nil {
-			logger.Error("Failed to decode user: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, apperror.ErrInvalidJSON.Wrap(err))
 			return
 		}
 
 		user.ID = userID
 
+		// PUT меняет только непустые поля и true, поэтому проверяются права именно на них — как и в PATCH
+		var fields []string
+		for field, set := range map[string]bool{
+			"name": user.Name != "", "phone": user.Phone != "", "email": user.Email != "", "password": user.Password != "",
+			"locale": user.Locale != "", "isDeleted": user.IsDeleted, "isBanned": user.IsBanned,
+		} {
+			if set {
+				fields = append(fields, field)
+			}
+		}
+		slices.Sort(fields)
+		if err := checkUserFields(role, fields...); err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
 		user.FromDateUpdate = time.Now().Format(time.RFC3339)
 
 		if err := utils.ValidateUserForUpdate(user); err != nil {
-			logger.Error("User validation failed!" + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, apperror.Validation(err))
 			return
 		}
 
@@ -224,89 +271,144 @@ func UpdateUser(db *sql.DB) http.HandlerFunc {
 		if user.Password != "" {
 			user.Password, err = utils.HashPassword(user.Password)
 			if err != nil {
-				logger.Error("Failed to hash password: " + err.Error())
-				http.Error(w, err.Error(), http.StatusInternalServerError)
+				apperror.Write(w, r, fmt.Errorf("failed to hash password: %w", err))
 				return
 			}
 		}
 
-		// Обновление данных пользователя в базе
-		err = dataBase.DBUpdateUser(db, &user)
+		// Прежние данные нужны, чтобы понять, о каких изменениях предупредить пользователя
+		before, err := users.Get(r.Context(), userID)
 		if err != nil {
-			logger.Error("Failed to update user: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, err)
+			return
+		}
+		if err := checkIfMatch(r, userETag(before.Version)); err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		// Обновление применяется только к прочитанной версии: правка, сделанная другим запросом
+		// между чтением и записью, тоже приведёт к 412, а не будет перезаписана
+		after, err := saveUserChange(r, transactor, notifier, auditor, before, user.Password != "", func(tx dataBase.UserRepository) error {
+			// Update записывает в Version новую версию, поэтому при повторе транзакции изменяется копия
+			change := user
+			change.Version = before.Version
+			if err := tx.Update(r.Context(), &change); err != nil {
+				return fmt.Errorf("failed to update user: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			apperror.Write(w, r, err)
 			return
 		}
 
 		// Ответ без тела (204 No Content)
+		w.Header().Set("ETag", userETag(after.Version))
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
+// saveUserChange выполняет write и записывает изменение в журнал аудита в одной транзакции,
+// а после её фиксации отправляет уведомления о событиях безопасности. Возвращает сохранённые данные пользователя.
+// write может выполниться несколько раз, если транзакцию прервал конфликт с параллельной.
+func saveUserChange(r *http.Request, transactor dataBase.Transactor, notifier *notify.Notifier, auditor *audit.Auditor,
+	before *models.User, passwordChanged bool, write func(tx dataBase.UserRepository) error) (*models.User, error) {
+	var after *models.User
+	err := transactor.WithTx(r.Context(), func(tx *dataBase.Tx) error {
+		if err := write(tx.Users); err != nil {
+			return err
+		}
+
+		// В журнал попадают фактически сохранённые значения, поэтому пользователь перечитывается после обновления
+		var err error
+		after, err = tx.Users.Get(r.Context(), before.ID)
+		if err != nil {
+			return err
+		}
+		action := models.AuditUserUpdate
+		switch {
+		case after.IsBanned && !before.IsBanned:
+			action = models.AuditUserBan
+		case !after.IsBanned && before.IsBanned:
+			action = models.AuditUserUnban
+		case after.IsDeleted && !before.IsDeleted:
+			action = models.AuditUserDelete
+		}
+		return auditor.RecordTx(r, tx, audit.Event{
+			Action:   action,
+			TargetID: before.ID,
+			Changes:  audit.UserChanges(before, after, passwordChanged),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Уведомления о событиях безопасности; письма уходят на прежний адрес почты
+	ctx := context.WithoutCancel(r.Context())
+	if passwordChanged {
+		notifier.PasswordChanged(ctx, before)
+	}
+	if !strings.EqualFold(after.Email, before.Email) {
+		notifier.EmailChanged(ctx, before, after.Email)
+	}
+	if after.IsBanned && !before.IsBanned {
+		notifier.AccountBanned(ctx, before)
+	}
+	if after.IsDeleted && !before.IsDeleted {
+		notifier.AccountDeleted(ctx, before)
+	}
+	return after, nil
+}
+
 // DeleteUser removes a user by ID.
 // @Summary Delete a user by ID
 // @Description Remove a user using their ID
 // @Tags users
 // @Param id path int true "User ID"
 // @Success 204 "User deleted successfully"
-// @Failure 400 {string} string "Invalid ID"
-// @Failure 404 {string} string "User not found"
+// @Failure 400 {object} apperror.ErrorResponse "Invalid ID"
+// @Failure 401 {object} apperror.ErrorResponse "Authorization required"
+// @Failure 403 {object} apperror.ErrorResponse "The caller cannot delete this user"
+// @Failure 404 {object} apperror.ErrorResponse "User not found"
 // @Router /users/{id} [delete]
-func DeleteUser(db *sql.DB) http.HandlerFunc {
+func DeleteUser(users dataBase.UserRepository, transactor dataBase.Transactor, notifier *notify.Notifier, auditor *audit.Auditor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		params := mux.Vars(r)
-		userID, err := strconv.Atoi(params["id"])
+		// Удалить учётную запись может её владелец или администратор
+		userID, err := selfOrAdmin(users, r)
 		if err != nil {
-			logger.Error("Failed to parse id in 'DeleteUser': " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, err)
 			return
 		}
 
-		err = dataBase.DBDeleteUser(db, userID)
+		user, err := users.Get(r.Context(), userID)
 		if err != nil {
-			logger.Error("Failed to delete user: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, err)
 			return
 		}
 
-		w.WriteHeader(http.StatusNoContent)
-	}
-}
-
-// GetAllRequestLogs возвращает все записи логов из MongoDB
-func GetAllRequestLogs(client *mongo.Client) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Получение коллекции
-		collection := client.Database("Cloud").Collection("logs")
-
-		// Чтение всех документов
-		cursor, err := collection.Find(context.TODO(), bson.M{})
+		// Удаление и запись о нём в журнале аудита фиксируются вместе
+		err = transactor.WithTx(r.Context(), func(tx *dataBase.Tx) error {
+			if err := tx.Users.Delete(r.Context(), userID); err != nil {
+				return fmt.Errorf("failed to delete user: %w", err)
+			}
+			return auditor.RecordTx(r, tx, audit.Event{
+				Action:   models.AuditUserDelete,
+				TargetID: userID,
+				Changes:  map[string]models.FieldChange{"is_deleted": {Before: user.IsDeleted, After: true}},
+			})
+		})
 		if err != nil {
-			logger.Error("Failed to get logs: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			apperror.Write(w, r, err)
 			return
 		}
-		defer cursor.Close(context.TODO())
-
-		// Определяем срез для хранения логов
-		var logs []models.RequestLog
 
-		// Выполняем запрос к коллекции
-		for cursor.Next(context.TODO()) {
-			var log models.RequestLog
-			if err = cursor.Decode(&log); err != nil {
-				logger.Error("Failed to decode log: " + err.Error())
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			logs = append(logs, log)
+		// Повторное удаление уже удалённого пользователя не порождает нового письма
+		if !user.IsDeleted {
+			notifier.AccountDeleted(context.WithoutCancel(r.Context()), user)
 		}
 
-		err = utils.SendExcelWithLogs(logs, w)
-		if err != nil {
-			logger.Error("Failed to save log in Excel file: " + err.Error())
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
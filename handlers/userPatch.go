@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/audit"
+	"Cloud/dataBase"
+	"Cloud/i18n"
+	"Cloud/models"
+	"Cloud/notify"
+	"Cloud/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"slices"
+	"time"
+)
+
+// userPatchFields — поля, которые могут изменить PUT и PATCH /user/{id}, по роли того, кто изменяет.
+// Роль меняется отдельным запросом PUT /admin/users/{id}/role, остальные поля доступны только для чтения.
+var userPatchFields = map[string][]string{
+	models.RoleUser:    {"name", "phone", "email", "password", "locale"},
+	models.RoleAuditor: {"name", "phone", "email", "password", "locale"},
+	models.RoleAdmin:   {"name", "phone", "email", "password", "locale", "isDeleted", "isBanned"},
+}
+
+// requiredPatchFields — поля, которые нельзя очистить значением null или пустой строкой
+var requiredPatchFields = []string{"name", "email", "password"}
+
+// PatchUser частично изменяет пользователя.
+// @Summary Partially update a user
+// @Description Apply a JSON Merge Patch (RFC 7386) or a JSON Patch (RFC 6902) to a user. null clears phone and locale and resets flags to false.
+// @Description Users may change their own name, phone, email, password and locale; admins may also change isDeleted and isBanned of any user.
+// @Tags users
+// @Accept application/merge-patch+json
+// @Accept application/json-patch+json
+// @Produce json
+// @Param id path int true "User ID"
+// @Param If-Match header string true "ETag of the version being modified"
+// @Success 200 {object} models.User "Updated user"
+// @Header 200 {string} ETag "New version of the user"
+// @Failure 400 {object} apperror.ErrorResponse "Invalid patch or field value"
+// @Failure 403 {object} apperror.ErrorResponse "The field cannot be changed by the caller"
+// @Failure 404 {object} apperror.ErrorResponse "User not found"
+// @Failure 409 {object} apperror.ErrorResponse "Email or phone taken, or a test operation failed"
+// @Failure 412 {object} apperror.ErrorResponse "The user was modified by another request"
+// @Failure 415 {object} apperror.ErrorResponse "Unsupported patch format"
+// @Failure 428 {object} apperror.ErrorResponse "If-Match header is missing"
+// @Router /users/{id} [patch]
+func PatchUser(users dataBase.UserRepository, transactor dataBase.Transactor, notifier *notify.Notifier, auditor *audit.Auditor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, role, err := userAccess(users, r)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		if r.Header.Get("If-Match") == "" {
+			apperror.Write(w, r, apperror.ErrPreconditionRequired)
+			return
+		}
+
+		contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if contentType != utils.MergePatchContentType && contentType != utils.JSONPatchContentType {
+			w.Header().Set("Accept-Patch", utils.MergePatchContentType+", "+utils.JSONPatchContentType)
+			apperror.Write(w, r, apperror.ErrUnsupportedMediaType.Wrap(fmt.Errorf("Content-Type %q", r.Header.Get("Content-Type"))))
+			return
+		}
+
+		before, err := users.Get(r.Context(), userID)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+		if err := checkIfMatch(r, userETag(before.Version)); err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		doc, err := userDocument(before)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+		patched, err := applyUserPatch(r, contentType, doc)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		patch, err := userPatchFromDocument(doc, patched, role)
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+		patch.ID = userID
+		patch.FromDateUpdate = time.Now().Format(time.RFC3339)
+
+		// Хеширование пароля если он был изменён
+		if patch.Password != nil {
+			hashed, err := utils.HashPassword(*patch.Password)
+			if err != nil {
+				apperror.Write(w, r, fmt.Errorf("failed to hash password: %w", err))
+				return
+			}
+			patch.Password = &hashed
+		}
+
+		// Как и PUT, изменение применяется только к прочитанной версии
+		after, err := saveUserChange(r, transactor, notifier, auditor, before, patch.Password != nil, func(tx dataBase.UserRepository) error {
+			// Patch записывает в Version новую версию, поэтому при повторе транзакции изменяется копия
+			change := *patch
+			change.Version = before.Version
+			if err := tx.Patch(r.Context(), &change); err != nil {
+				return fmt.Errorf("failed to patch user: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			apperror.Write(w, r, err)
+			return
+		}
+
+		// Хеш пароля клиенту не нужен
+		after.Password = ""
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", userETag(after.Version))
+		json.NewEncoder(w).Encode(after)
+	}
+}
+
+// userAccess проверяет, что запрос изменяет самого вызывающего или его выполняет администратор,
+// и возвращает ID изменяемого пользователя и роль вызывающего
+func userAccess(users dataBase.UserRepository, r *http.Request) (int, string, error) {
+	userID, err := selfOrAdmin(users, r)
+	if err != nil {
+		return 0, "", err
+	}
+	currentID, _ := utils.UserIDFromContext(r.Context())
+	role, err := users.GetRole(r.Context(), currentID)
+	if err != nil {
+		return 0, "", err
+	}
+	return userID, role, nil
+}
+
+// checkUserFields возвращает ErrFieldForbidden, если роль role не может менять одно из полей fields
+func checkUserFields(role string, fields ...string) error {
+	for _, field := range fields {
+		if !slices.Contains(userPatchFields[role], field) {
+			return apperror.ErrFieldForbidden.WithArgs(field).Wrap(fmt.Errorf("роль %s не может менять поле %s", role, field))
+		}
+	}
+	return nil
+}
+
+// userDocument возвращает JSON-представление пользователя, к которому применяется патч.
+// Пароля в документе нет: его можно задать (add в JSON Patch или поле в Merge Patch), но нельзя прочитать или проверить операцией test.
+func userDocument(user *models.User) (map[string]any, error) {
+	body, err := json.Marshal(user)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "password")
+	delete(doc, "token_expires_at")
+	return doc, nil
+}
+
+// applyUserPatch разбирает тело запроса в формате contentType и применяет его к doc
+func applyUserPatch(r *http.Request, contentType string, doc map[string]any) (map[string]any, error) {
+	if contentType == utils.JSONPatchContentType {
+		var ops []utils.PatchOperation
+		if err := json.NewDecoder(r.Body).Decode(&ops); err != nil {
+			return nil, apperror.ErrInvalidJSON.Wrap(err)
+		}
+		patched, err := utils.ApplyJSONPatch(doc, ops)
+		if errors.Is(err, utils.ErrPatchTestFailed) {
+			return nil, apperror.ErrPatchTestFailed.Wrap(err)
+		}
+		if err != nil {
+			return nil, apperror.Validation(err)
+		}
+		return patched, nil
+	}
+
+	var patch any
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return nil, apperror.ErrInvalidJSON.Wrap(err)
+	}
+	patched, err := utils.ApplyMergePatch(doc, patch)
+	if err != nil {
+		return nil, apperror.Validation(err)
+	}
+	return patched, nil
+}
+
+// userPatchFromDocument сравнивает документ до и после патча и собирает изменения полей,
+// проверяя, что роль role может менять каждое из них, а новые значения корректны.
+// Удалённое патчем поле (null в Merge Patch, remove в JSON Patch) очищается.
+func userPatchFromDocument(before, after map[string]any, role string) (*models.UserPatch, error) {
+	patch := &models.UserPatch{}
+	stringFields := map[string]**string{
+		"name": &patch.Name, "phone": &patch.Phone, "email": &patch.Email,
+		"password": &patch.Password, "locale": &patch.Locale,
+	}
+	boolFields := map[string]**bool{"isDeleted": &patch.IsDeleted, "isBanned": &patch.IsBanned}
+
+	// Поля перебираются по порядку, чтобы при нескольких ошибках ответ не зависел от порядка обхода map
+	fields := make([]string, 0, len(before)+len(after))
+	for field := range before {
+		fields = append(fields, field)
+	}
+	for field := range after {
+		if _, ok := before[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	slices.Sort(fields)
+
+	var changed models.User
+	for _, field := range fields {
+		value := after[field]
+		if reflect.DeepEqual(before[field], value) {
+			continue
+		}
+
+		str, isString := stringFields[field]
+		flag, isBool := boolFields[field]
+		if !isString && !isBool {
+			return nil, apperror.Validation(i18n.NewError("validation.patch_field_readonly", field))
+		}
+		if err := checkUserFields(role, field); err != nil {
+			return nil, err
+		}
+
+		if isBool {
+			b, ok := value.(bool)
+			if !ok && value != nil {
+				return nil, apperror.Validation(i18n.NewError("validation.patch_field_type", field))
+			}
+			*flag = &b
+			continue
+		}
+
+		s, ok := value.(string)
+		if !ok && value != nil {
+			return nil, apperror.Validation(i18n.NewError("validation.patch_field_type", field))
+		}
+		if s == "" && slices.Contains(requiredPatchFields, field) {
+			return nil, apperror.Validation(i18n.NewError("validation.patch_field_required", field))
+		}
+		*str = &s
+	}
+
+	// Непустые значения проверяются теми же правилами, что и при PUT
+	for _, field := range []struct {
+		dst *string
+		src *string
+	}{
+		{&changed.Name, patch.Name}, {&changed.Phone, patch.Phone}, {&changed.Email, patch.Email},
+		{&changed.Password, patch.Password}, {&changed.Locale, patch.Locale},
+	} {
+		if field.src != nil {
+			*field.dst = *field.src
+		}
+	}
+	if err := utils.ValidateUserForUpdate(changed); err != nil {
+		return nil, apperror.Validation(err)
+	}
+	return patch, nil
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"Cloud/apperror"
+	"Cloud/models"
+	"errors"
+	"testing"
+)
+
+// Патч не может менять поля вне белого списка даже у администратора
+func TestUserPatchFromDocument(t *testing.T) {
+	user := &models.User{ID: 1, Name: "alice", Email: "alice@example.com", Phone: "+79990000001", Role: models.RoleUser, Version: 3}
+
+	cases := []struct {
+		name    string
+		role    string
+		field   string
+		value   any
+		wantKey string // пустая строка — изменение допустимо
+	}{
+		{"role", models.RoleAdmin, "role", models.RoleAdmin, "validation.patch_field_readonly"},
+		{"version", models.RoleAdmin, "version", float64(4), "validation.patch_field_readonly"},
+		{"id", models.RoleAdmin, "id", float64(2), "validation.patch_field_readonly"},
+		{"удаление id", models.RoleAdmin, "id", nil, "validation.patch_field_readonly"},
+		{"неизвестное поле", models.RoleAdmin, "isAdmin", true, "validation.patch_field_readonly"},
+		{"блокировка пользователем", models.RoleUser, "isBanned", true, "error.field_forbidden"},
+		{"блокировка администратором", models.RoleAdmin, "isBanned", true, ""},
+		{"имя", models.RoleUser, "name", "alice2", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			before, err := userDocument(user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := userDocument(user)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.value == nil {
+				delete(after, tc.field)
+			} else {
+				after[tc.field] = tc.value
+			}
+
+			patch, err := userPatchFromDocument(before, after, tc.role)
+			if tc.wantKey == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			var appErr *apperror.AppError
+			if !errors.As(err, &appErr) || appErr.Key != tc.wantKey {
+				t.Fatalf("ошибка %v, ожидался ключ %s; патч %+v", err, tc.wantKey, patch)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"Cloud/dataBase"
+	"Cloud/email"
+	"Cloud/i18n"
+	"Cloud/models"
+	"Cloud/notify"
+	"Cloud/utils"
+	"context"
+	"encoding/json"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// Пользователи тестового хранилища: 1 и 2 — обычные, 3 — администратор, 4 — заблокированный
+func newTestUsers(t *testing.T) *dataBase.MemoryUserRepository {
+	t.Helper()
+	users := dataBase.NewMemoryUserRepository()
+	for _, user := range []*models.User{
+		{Name: "alice", Email: "alice@example.com", Phone: "+79990000001", Password: "hash"},
+		{Name: "bob", Email: "bob@example.com", Phone: "+79990000002", Password: "hash"},
+		{Name: "admin", Email: "admin@example.com", Password: "hash", Role: models.RoleAdmin},
+		{Name: "banned", Email: "banned@example.com", Password: "hash", IsBanned: true},
+	} {
+		if err := users.Create(context.Background(), user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return users
+}
+
+// serveUser выполняет запрос к /user/{id} от имени пользователя caller (0 — без авторизации)
+func serveUser(handler http.HandlerFunc, method string, callerID, targetID int, headers map[string]string, body string) *httptest.ResponseRecorder {
+	router := mux.NewRouter()
+	router.Handle("/user/{id}", handler).Methods(method)
+
+	req := httptest.NewRequest(method, "/user/"+strconv.Itoa(targetID), strings.NewReader(body))
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if callerID != 0 {
+		req = req.WithContext(utils.WithUserID(req.Context(), callerID))
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// errorCode возвращает код ошибки из JSON-конверта ответа
+func errorCode(w *httptest.ResponseRecorder) string {
+	var resp struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Error.Code
+}
+
+func TestUserHandlers(t *testing.T) {
+	const (
+		mergePatch = "application/merge-patch+json"
+		jsonPatch  = "application/json-patch+json"
+	)
+	cases := []struct {
+		name           string
+		method         string
+		caller, target int
+		headers        map[string]string
+		body           string
+		wantStatus     int
+		wantCode       string
+		wantETag       string
+	}{
+		{"GET без If-None-Match", http.MethodGet, 0, 1, nil, "", http.StatusOK, "", `"1"`},
+		{"GET с актуальным ETag", http.MethodGet, 0, 1, map[string]string{"If-None-Match": `"1"`}, "", http.StatusNotModified, "", `"1"`},
+		{"GET со слабым ETag", http.MethodGet, 0, 1, map[string]string{"If-None-Match": `W/"1"`}, "", http.StatusNotModified, "", `"1"`},
+		{"GET с устаревшим ETag", http.MethodGet, 0, 1, map[string]string{"If-None-Match": `"0"`}, "", http.StatusOK, "", `"1"`},
+
+		{"PUT без авторизации", http.MethodPut, 0, 1, map[string]string{"If-Match": `"1"`}, `{"name":"alice2"}`, http.StatusUnauthorized, "unauthorized", ""},
+		{"PUT без If-Match", http.MethodPut, 1, 1, nil, `{"name":"alice2"}`, http.StatusPreconditionRequired, "precondition_required", ""},
+		{"PUT устаревшей версии", http.MethodPut, 1, 1, map[string]string{"If-Match": `"7"`}, `{"name":"alice2"}`, http.StatusPreconditionFailed, "precondition_failed", ""},
+		{"PUT своего имени", http.MethodPut, 1, 1, map[string]string{"If-Match": `"1"`}, `{"name":"alice2"}`, http.StatusNoContent, "", `"2"`},
+		{"PUT чужого пользователя", http.MethodPut, 2, 1, map[string]string{"If-Match": `"1"`}, `{"name":"alice2"}`, http.StatusForbidden, "forbidden", ""},
+		{"PUT блокировки пользователем", http.MethodPut, 1, 1, map[string]string{"If-Match": `"1"`}, `{"isBanned":true}`, http.StatusForbidden, "field_forbidden", ""},
+		{"PUT занятого email", http.MethodPut, 1, 1, map[string]string{"If-Match": `"1"`}, `{"email":"BOB@example.com"}`, http.StatusConflict, "email_taken", ""},
+
+		{"PATCH без If-Match", http.MethodPatch, 1, 1, map[string]string{"Content-Type": mergePatch}, `{"name":"alice2"}`, http.StatusPreconditionRequired, "precondition_required", ""},
+		{"PATCH устаревшей версии", http.MethodPatch, 1, 1, map[string]string{"Content-Type": mergePatch, "If-Match": `"7"`}, `{"name":"alice2"}`, http.StatusPreconditionFailed, "precondition_failed", ""},
+		{"PATCH в формате JSON", http.MethodPatch, 1, 1, map[string]string{"Content-Type": "application/json", "If-Match": `"1"`}, `{"name":"alice2"}`, http.StatusUnsupportedMediaType, "unsupported_media_type", ""},
+		{"PATCH своего имени", http.MethodPatch, 1, 1, map[string]string{"Content-Type": mergePatch, "If-Match": `"1"`}, `{"name":"alice2"}`, http.StatusOK, "", `"2"`},
+		{"PATCH блокировки пользователем", http.MethodPatch, 1, 1, map[string]string{"Content-Type": mergePatch, "If-Match": `"1"`}, `{"isBanned":true}`, http.StatusForbidden, "field_forbidden", ""},
+		{"PATCH разблокировки администратором", http.MethodPatch, 3, 4, map[string]string{"Content-Type": mergePatch, "If-Match": `"1"`}, `{"isBanned":false}`, http.StatusOK, "", `"2"`},
+		{"PATCH роли", http.MethodPatch, 3, 1, map[string]string{"Content-Type": jsonPatch, "If-Match": `"1"`}, `[{"op":"replace","path":"/role","value":"admin"}]`, http.StatusBadRequest, "validation_failed", ""},
+		{"PATCH с очисткой имени", http.MethodPatch, 1, 1, map[string]string{"Content-Type": mergePatch, "If-Match": `"1"`}, `{"name":null}`, http.StatusBadRequest, "validation_failed", ""},
+		{"PATCH занятого телефона", http.MethodPatch, 1, 1, map[string]string{"Content-Type": mergePatch, "If-Match": `"1"`}, `{"phone":"+79990000002"}`, http.StatusConflict, "phone_taken", ""},
+		{"PATCH с невыполненным test", http.MethodPatch, 1, 1, map[string]string{"Content-Type": jsonPatch, "If-Match": `"1"`}, `[{"op":"test","path":"/name","value":"bob"},{"op":"replace","path":"/name","value":"alice2"}]`, http.StatusConflict, "patch_test_failed", ""},
+
+		{"DELETE чужого пользователя", http.MethodDelete, 2, 1, nil, "", http.StatusForbidden, "forbidden", ""},
+		{"DELETE своей учётной записи", http.MethodDelete, 1, 1, nil, "", http.StatusNoContent, "", ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			users := newTestUsers(t)
+			handler := map[string]http.HandlerFunc{
+				http.MethodGet:    GetUser(users),
+				http.MethodPut:    UpdateUser(users, users, nil, nil),
+				http.MethodPatch:  PatchUser(users, users, nil, nil),
+				http.MethodDelete: DeleteUser(users, users, nil, nil),
+			}[tc.method]
+
+			w := serveUser(handler, tc.method, tc.caller, tc.target, tc.headers, tc.body)
+			if w.Code != tc.wantStatus {
+				t.Fatalf("статус %d, ожидался %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+			if code := errorCode(w); code != tc.wantCode {
+				t.Errorf("код ошибки %q, ожидался %q", code, tc.wantCode)
+			}
+			if etag := w.Header().Get("ETag"); etag != tc.wantETag {
+				t.Errorf("ETag %s, ожидался %s", etag, tc.wantETag)
+			}
+			if strings.Contains(w.Body.String(), `"password"`) && strings.Contains(w.Body.String(), "hash") {
+				t.Errorf("ответ содержит хеш пароля: %s", w.Body)
+			}
+
+			// Отклонённый запрос не должен менять пользователя
+			if tc.wantStatus >= http.StatusBadRequest {
+				if user, _ := users.Get(context.Background(), tc.target); user.Version != 1 {
+					t.Errorf("версия пользователя %d после отклонённого запроса", user.Version)
+				}
+			}
+		})
+	}
+}
+
+// Изменения учётной записи, о которых пользователь должен узнать, отправляют письмо на прежний адрес
+func TestUserNotifications(t *testing.T) {
+	t.Setenv("MAIL_FROM", "noreply@example.com")
+	const mergePatch = "application/merge-patch+json"
+
+	cases := []struct {
+		name           string
+		method         string
+		caller, target int
+		headers        map[string]string
+		body           string
+		wantStatus     int
+		wantTo         string
+		wantSubject    string
+	}{
+		{"DELETE своей учётной записи", http.MethodDelete, 1, 1, nil, "", http.StatusNoContent, "alice@example.com", "email.account_deletion.subject"},
+		{"DELETE администратором", http.MethodDelete, 3, 2, nil, "", http.StatusNoContent, "bob@example.com", "email.account_deletion.subject"},
+		{"PATCH блокировки администратором", http.MethodPatch, 3, 1, map[string]string{"Content-Type": mergePatch, "If-Match": `"1"`}, `{"isBanned":true}`, http.StatusOK, "alice@example.com", "email.account_banned.subject"},
+		{"PUT блокировки администратором", http.MethodPut, 3, 2, map[string]string{"If-Match": `"1"`}, `{"isBanned":true}`, http.StatusNoContent, "bob@example.com", "email.account_banned.subject"},
+		{"PATCH смены email", http.MethodPatch, 1, 1, map[string]string{"Content-Type": mergePatch, "If-Match": `"1"`}, `{"email":"alice2@example.com"}`, http.StatusOK, "alice@example.com", "email.email_changed.subject"},
+		{"PUT смены email", http.MethodPut, 1, 1, map[string]string{"If-Match": `"1"`}, `{"email":"alice2@example.com"}`, http.StatusNoContent, "alice@example.com", "email.email_changed.subject"},
+		{"PATCH имени без уведомления", http.MethodPatch, 1, 1, map[string]string{"Content-Type": mergePatch, "If-Match": `"1"`}, `{"name":"alice2"}`, http.StatusOK, "", ""},
+		{"отклонённый PATCH без уведомления", http.MethodPatch, 1, 1, map[string]string{"Content-Type": mergePatch, "If-Match": `"7"`}, `{"email":"alice2@example.com"}`, http.StatusPreconditionFailed, "", ""},
+		{"DELETE заблокированного пользователя", http.MethodDelete, 3, 4, nil, "", http.StatusNoContent, "banned@example.com", "email.account_deletion.subject"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			users := newTestUsers(t)
+			mailer := email.NewMemoryMailer()
+			notifier := notify.New(nil, users, mailer, nil)
+			handler := map[string]http.HandlerFunc{
+				http.MethodPut:    UpdateUser(users, users, notifier, nil),
+				http.MethodPatch:  PatchUser(users, users, notifier, nil),
+				http.MethodDelete: DeleteUser(users, users, notifier, nil),
+			}[tc.method]
+
+			w := serveUser(handler, tc.method, tc.caller, tc.target, tc.headers, tc.body)
+			if w.Code != tc.wantStatus {
+				t.Fatalf("статус %d, ожидался %d: %s", w.Code, tc.wantStatus, w.Body)
+			}
+
+			sent := mailer.Messages()
+			if tc.wantTo == "" {
+				if len(sent) != 0 {
+					t.Fatalf("отправлено писем: %d, ожидалось 0", len(sent))
+				}
+				return
+			}
+			if len(sent) != 1 {
+				t.Fatalf("отправлено писем: %d, ожидалось 1", len(sent))
+			}
+			if to := sent[0].Message.To; len(to) != 1 || to[0] != tc.wantTo {
+				t.Errorf("письмо на %v, ожидалось на %s", to, tc.wantTo)
+			}
+			if subject := i18n.T(i18n.Default, tc.wantSubject); sent[0].Message.Subject != subject {
+				t.Errorf("тема письма %q, ожидалась %q", sent[0].Message.Subject, subject)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Поддерживаемые языки
+const (
+	RU = "ru"
+	EN = "en"
+
+	// Default — язык, используемый, если клиент не указал поддерживаемый язык
+	Default = RU
+)
+
+//go:embed locales/*.json
+var localesFS embed.FS
+
+// catalog хранит переводы сообщений: язык -> ключ -> текст
+var catalog = mustLoadCatalog()
+
+// mustLoadCatalog загружает встроенные файлы переводов
+func mustLoadCatalog() map[string]map[string]string {
+	entries, err := localesFS.ReadDir("locales")
+	if err != nil {
+		panic("i18n: не удалось прочитать каталог сообщений: " + err.Error())
+	}
+
+	result := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		data, err := localesFS.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			panic("i18n: не удалось прочитать " + entry.Name() + ": " + err.Error())
+		}
+
+		messages := map[string]string{}
+		if err := json.Unmarshal(data, &messages); err != nil {
+			panic("i18n: некорректный файл " + entry.Name() + ": " + err.Error())
+		}
+		result[strings.TrimSuffix(entry.Name(), ".json")] = messages
+	}
+	return result
+}
+
+// IsSupported сообщает, есть ли переводы для указанного языка
+func IsSupported(lang string) bool {
+	_, ok := catalog[lang]
+	return ok
+}
+
+// T возвращает перевод сообщения с подстановкой аргументов.
+// Если перевода на указанный язык нет, используется язык по умолчанию, а затем сам ключ.
+func T(lang, key string, args ...any) string {
+	msg, ok := catalog[lang][key]
+	if !ok {
+		msg, ok = catalog[Default][key]
+	}
+	if !ok {
+		msg = key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// Message — ошибка с ключом каталога, которую можно перевести на язык клиента
+type Message struct {
+	Key  string
+	Args []any
+}
+
+// NewError создаёт ошибку с ключом каталога
+func NewError(key string, args ...any) *Message {
+	return &Message{Key: key, Args: args}
+}
+
+// Error возвращает текст ошибки на языке по умолчанию
+func (m *Message) Error() string {
+	return T(Default, m.Key, m.Args...)
+}
+
+// Localize возвращает текст ошибки на указанном языке
+func (m *Message) Localize(lang string) string {
+	return T(lang, m.Key, m.Args...)
+}
+
+// Negotiate выбирает язык ответа по заголовку Accept-Language.
+// Учитываются веса q, региональные варианты ("en-US") сводятся к базовому языку.
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q <= 0 {
+			continue
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		candidates = append(candidates, candidate{lang: base, q: q})
+	}
+
+	// Стабильная сортировка сохраняет порядок клиента при равных весах
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		if IsSupported(c.lang) {
+			return c.lang
+		}
+	}
+	return Default
+}
+
+// langKey — ключ контекста для языка ответа
+type langKey struct{}
+
+// WithLang сохраняет язык ответа в контексте
+func WithLang(ctx context.Context, lang string) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// FromContext возвращает язык ответа из контекста или язык по умолчанию
+func FromContext(ctx context.Context) string {
+	if lang, ok := ctx.Value(langKey{}).(string); ok && lang != "" {
+		return lang
+	}
+	return Default
+}
@@ -1,10 +1,27 @@
 package internal
 
-import "Cloud/logger"
+import (
+	"Cloud/audit"
+	"Cloud/dataBase"
+	"Cloud/email"
+	"Cloud/exports"
+	"Cloud/logger"
+	"Cloud/notify"
+	"Cloud/retention"
+	"database/sql"
+)
 
 // App представляет собой структуру приложения, содержащую необходимые зависимости
 type App struct {
-	RequestLogger *logger.RequestLogger // Логгер запросов
+	Users         dataBase.UserRepository // Хранилище пользователей
+	Transactor    dataBase.Transactor     // Транзакции над несколькими хранилищами
+	RequestLogger *logger.RequestLogger   // Логгер запросов
+	Mailer        email.Mailer            // Транспорт для отправки писем
+	Notifier      *notify.Notifier        // Уведомления о событиях безопасности
+	Exports       *exports.Manager        // Фоновые выгрузки логов
+	Retention     *retention.Manager      // Сроки хранения и архивация логов
+	Audit         *audit.Auditor          // Журнал аудита действий с пользователями
+	DBPools       map[string]*sql.DB      // Пулы соединений PostgreSQL по именам, для метрик
 }
 
 //internal представляет собой компонент вашего приложения и организует его зависимости.
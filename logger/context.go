@@ -0,0 +1,50 @@
+package logger
+
+import (
+	"Cloud/utils"
+	"context"
+	"log/slog"
+)
+
+// attrsKey — ключ контекста для дополнительных полей логов
+type attrsKey struct{}
+
+// WithAttrs добавляет поля, которые попадут во все записи лога с этим контекстом
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// WithRoute добавляет в контекст шаблон маршрута запроса
+func WithRoute(ctx context.Context, route string) context.Context {
+	return WithAttrs(ctx, slog.String("route", route))
+}
+
+// contextHandler дополняет записи полями запроса: идентификатором, ID пользователя и полями из WithAttrs
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := utils.RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String("request_id", requestID))
+	}
+	if userID, ok := utils.UserIDFromContext(ctx); ok {
+		record.AddAttrs(slog.Int("user_id", userID))
+	}
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -1,40 +1,163 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"os"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Форматы вывода логов
+const (
+	FormatJSON = "json"
+	FormatText = "text"
 )
 
 var (
-	infoLog    *log.Logger // Логгер для информационных сообщений
-	warningLog *log.Logger // Логгер для предупреждающих сообщений
-	errorLog   *log.Logger // Логгер для сообщений об ошибках
-	LogFile    *os.File    // Файл для записи логов
+	level = new(slog.LevelVar) // текущий уровень; меняется на лету через SetLevel
+	base  = newLogger(os.Stdout, FormatText)
+	// LogFile — файл для записи логов
+	LogFile *RotatingFile
 )
 
+// newLogger создаёт slog.Logger с общим уровнем и полями из контекста запроса
+func newLogger(w io.Writer, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{AddSource: true, Level: level}
+
+	var handler slog.Handler
+	if format == FormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+// DefaultLogFileRotation — ротация файла логов по умолчанию: раз в сутки или по достижении 100 МБ, неделя сжатых копий
+var DefaultLogFileRotation = RotateConfig{
+	MaxSize:    100 << 20,
+	Interval:   24 * time.Hour,
+	MaxBackups: 7,
+	Compress:   true,
+}
+
 // Logging инициализирует логгеры и открывает файл для записи логов.
+// Уровень задаётся переменной LOG_LEVEL (debug, info, warn, error), формат — LOG_FORMAT (json или text),
+// путь к файлу — LOG_FILE (по умолчанию Cloud.log), ротация — переменными LOG_FILE_* (см. RotateConfigFromEnv).
+// Ошибка означает, что приложение нельзя запускать: логи некуда писать.
 // @Summary Инициализация логгирования
 // @Description Открывает файл для записи логов и инициализирует логгеры для разных уровней
 // @Tags logger
 // @Success 200 "Logging initialized successfully"
 // @Failure 500 "Failed to initialize logging"
 // @Router /logger/init [post]
-func Logging() {
+func Logging() error {
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if err := SetLevel(value); err != nil {
+			return err
+		}
+	}
+
+	format := strings.ToLower(os.Getenv("LOG_FORMAT"))
+	switch format {
+	case "":
+		format = FormatText
+	case FormatJSON, FormatText:
+	default:
+		return fmt.Errorf("неизвестный формат логов LOG_FORMAT: %q", format)
+	}
+
+	path := os.Getenv("LOG_FILE")
+	if path == "" {
+		path = "Cloud.log"
+	}
+	rotation, err := RotateConfigFromEnv("LOG_FILE", DefaultLogFileRotation)
+	if err != nil {
+		return err
+	}
+
 	// Открываем файл для записи логов
-	logFile, err := os.OpenFile("Cloud.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	logFile, err := OpenRotatingFile(path, rotation)
 	if err != nil {
-		fmt.Println("Не удалось открыть файл для логов!", err)
+		return fmt.Errorf("не удалось открыть файл логов %s: %w", path, err)
 	}
+	LogFile = logFile
+
+	// Пишем в файл и в консоль
+	base = newLogger(io.MultiWriter(os.Stdout, logFile), format)
+	slog.SetDefault(base)
+	return nil
+}
 
-	// Создаем MultiWriter для записи в файл и в консоль
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
+// ParseLevel разбирает название уровня: debug, info, warn (warning), error
+func ParseLevel(name string) (slog.Level, error) {
+	var lvl slog.Level
+	if strings.EqualFold(name, "warning") {
+		name = "warn"
+	}
+	if err := lvl.UnmarshalText([]byte(name)); err != nil {
+		return lvl, fmt.Errorf("неизвестный уровень логирования %q", name)
+	}
+	return lvl, nil
+}
 
-	// Инициализируем логгеры для разных уровней
-	infoLog = log.New(multiWriter, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	warningLog = log.New(multiWriter, "WARNING: ", log.Ldate|log.Ltime|log.Lshortfile)
-	errorLog = log.New(multiWriter, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+// SetLevel меняет уровень логирования без перезапуска
+func SetLevel(name string) error {
+	lvl, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(lvl)
+	return nil
+}
+
+// Level возвращает текущий уровень логирования в нижнем регистре
+func Level() string {
+	return strings.ToLower(level.Level().String())
+}
+
+// write пишет запись с местом вызова снаружи пакета logger
+func write(ctx context.Context, lvl slog.Level, msg string, args ...any) {
+	if !base.Enabled(ctx, lvl) {
+		return
+	}
+
+	// Пропускаем runtime.Callers, write и функцию-обёртку
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:])
+
+	record := slog.NewRecord(time.Now(), lvl, msg, pcs[0])
+	record.Add(args...)
+	base.Handler().Handle(ctx, record)
+}
+
+// DebugContext записывает отладочное сообщение с полями запроса из ctx и парами ключ-значение args
+func DebugContext(ctx context.Context, msg string, args ...any) {
+	write(ctx, slog.LevelDebug, msg, args...)
+}
+
+// InfoContext записывает информационное сообщение с полями запроса из ctx и парами ключ-значение args
+func InfoContext(ctx context.Context, msg string, args ...any) {
+	write(ctx, slog.LevelInfo, msg, args...)
+}
+
+// WarningContext записывает предупреждение с полями запроса из ctx и парами ключ-значение args
+func WarningContext(ctx context.Context, msg string, args ...any) {
+	write(ctx, slog.LevelWarn, msg, args...)
+}
+
+// ErrorContext записывает ошибку с полями запроса из ctx и парами ключ-значение args
+func ErrorContext(ctx context.Context, msg string, args ...any) {
+	write(ctx, slog.LevelError, msg, args...)
+}
+
+// Debug записывает отладочное сообщение в лог
+func Debug(msg string) {
+	write(context.Background(), slog.LevelDebug, msg)
 }
 
 // Info записывает информационное сообщение в лог.
@@ -46,11 +169,7 @@ func Logging() {
 // @Failure 500 "Failed to log message"
 // @Router /logger/info [post]
 func Info(msg string) {
-	if infoLog != nil {
-		infoLog.Println(msg)
-	} else {
-		fmt.Println("INFO LOGGING NOT INITIALIZED")
-	}
+	write(context.Background(), slog.LevelInfo, msg)
 }
 
 // Warning записывает предупреждающее сообщение в лог.
@@ -62,11 +181,7 @@ func Info(msg string) {
 // @Failure 500 "Failed to log message"
 // @Router /logger/warning [post]
 func Warning(msg string) {
-	if warningLog != nil {
-		warningLog.Println(msg)
-	} else {
-		fmt.Println("WARNING LOGGING NOT INITIALIZED")
-	}
+	write(context.Background(), slog.LevelWarn, msg)
 }
 
 // Error записывает сообщение об ошибке в лог.
@@ -78,9 +193,5 @@ func Warning(msg string) {
 // @Failure 500 "Failed to log message"
 // @Router /logger/error [post]
 func Error(msg string) {
-	if errorLog != nil {
-		errorLog.Println(msg)
-	} else {
-		fmt.Println("ERROR LOGGING NOT INITIALIZED")
-	}
+	write(context.Background(), slog.LevelError, msg)
 }
@@ -2,34 +2,394 @@ package logger
 
 import (
 	"Cloud/models"
+	"bufio"
 	"context"
-	"go.mongodb.org/mongo-driver/mongo"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// RequestLogger представляет собой структуру для логирования запросов
+// Поведение RequestLogger при переполнении буфера
+const (
+	OverflowDrop  = "drop"  // запись отбрасывается, увеличивается счётчик Dropped
+	OverflowSpill = "spill" // запись дописывается в файл на диске и позже переносится в хранилище
+)
+
+// RequestLoggerConfig — параметры записи логов запросов
+type RequestLoggerConfig struct {
+	BufferSize    int           // ёмкость буфера записей
+	BatchSize     int           // максимальный размер одной вставки InsertMany
+	FlushInterval time.Duration // как часто сбрасывается неполная пачка
+	WriteTimeout  time.Duration // тайм-аут одной вставки
+	Overflow      string        // OverflowDrop или OverflowSpill
+	SpillPath     string        // файл для записей, не поместившихся в буфер (для OverflowSpill)
+}
+
+// DefaultRequestLoggerConfig возвращает параметры записи логов запросов по умолчанию
+func DefaultRequestLoggerConfig() RequestLoggerConfig {
+	return RequestLoggerConfig{
+		BufferSize:    10000,
+		BatchSize:     500,
+		FlushInterval: time.Second,
+		WriteTimeout:  10 * time.Second,
+		Overflow:      OverflowDrop,
+		SpillPath:     "request_logs.spill.ndjson",
+	}
+}
+
+// RequestLoggerConfigFromEnv читает параметры из переменных окружения REQUEST_LOG_*
+func RequestLoggerConfigFromEnv() (RequestLoggerConfig, error) {
+	cfg := DefaultRequestLoggerConfig()
+
+	ints := []struct {
+		name string
+		dst  *int
+	}{
+		{"REQUEST_LOG_BUFFER_SIZE", &cfg.BufferSize},
+		{"REQUEST_LOG_BATCH_SIZE", &cfg.BatchSize},
+	}
+	for _, v := range ints {
+		if value := os.Getenv(v.name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return cfg, fmt.Errorf("некорректное значение %s: %q", v.name, value)
+			}
+			*v.dst = n
+		}
+	}
+
+	durations := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"REQUEST_LOG_FLUSH_INTERVAL", &cfg.FlushInterval},
+		{"REQUEST_LOG_WRITE_TIMEOUT", &cfg.WriteTimeout},
+	}
+	for _, v := range durations {
+		if value := os.Getenv(v.name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				return cfg, fmt.Errorf("некорректное значение %s: %q", v.name, value)
+			}
+			*v.dst = d
+		}
+	}
+
+	if value := os.Getenv("REQUEST_LOG_OVERFLOW"); value != "" {
+		if value != OverflowDrop && value != OverflowSpill {
+			return cfg, fmt.Errorf("некорректное значение REQUEST_LOG_OVERFLOW: %q", value)
+		}
+		cfg.Overflow = value
+	}
+	if value := os.Getenv("REQUEST_LOG_SPILL_PATH"); value != "" {
+		cfg.SpillPath = value
+	}
+
+	return cfg, nil
+}
+
+// RequestLoggerStats — счётчики RequestLogger с момента запуска
+type RequestLoggerStats struct {
+	Queued  int   `json:"queued"`  // записей в буфере сейчас
+	Written int64 `json:"written"` // записано в хранилище
+	Dropped int64 `json:"dropped"` // потеряно: буфер переполнен или хранилище недоступно
+	Spilled int64 `json:"spilled"` // сохранено на диск до восстановления хранилища
+}
+
+// RequestLogSink — хранилище логов запросов (MongoDB, таблица PostgreSQL, файл и т.п.)
+type RequestLogSink interface {
+	// Name возвращает имя хранилища для сообщений в логе
+	Name() string
+	// Write сохраняет пачку записей; ошибка означает, что пачку нужно записать повторно
+	Write(ctx context.Context, batch []models.RequestLog) error
+	// Close освобождает ресурсы хранилища
+	Close() error
+}
+
+// RequestLogger представляет собой структуру для логирования запросов.
+// Log не ждёт хранилище: записи попадают в буфер, а фоновый обработчик сохраняет их пачками.
 type RequestLogger struct {
-	collection *mongo.Collection
+	sink    RequestLogSink
+	cfg     RequestLoggerConfig
+	entries chan models.RequestLog
+	done    chan struct{}
+
+	spillMu sync.Mutex // защищает файл cfg.SpillPath
+
+	written atomic.Int64
+	dropped atomic.Int64
+	spilled atomic.Int64
+
+	// closed защищён closeMu: Log проверяет его под RLock перед отправкой в entries, а Close закрывает
+	// entries под Lock, поэтому отправка в закрытый канал невозможна
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// NewRequestLogger создаёт RequestLogger и запускает фоновую запись в sink
+func NewRequestLogger(sink RequestLogSink, cfg RequestLoggerConfig) *RequestLogger {
+	rl := &RequestLogger{
+		sink:    sink,
+		cfg:     cfg,
+		entries: make(chan models.RequestLog, cfg.BufferSize),
+		done:    make(chan struct{}),
+	}
+	go rl.run()
+	return rl
 }
 
-func NewRequestLogger(client *mongo.Client, dbName, collectionName string) *RequestLogger {
-	collection := client.Database(dbName).Collection(collectionName)
-	return &RequestLogger{collection: collection}
+// Log ставит лог запроса в очередь на запись и никогда не блокирует обработку запроса.
+// Если время не задано, используется текущее. После Close запись обрабатывается как при переполненном буфере:
+// так бывает, если запрос не успел завершиться за время остановки сервера.
+func (rl *RequestLogger) Log(logEntry models.RequestLog) {
+	if logEntry.Time.IsZero() {
+		logEntry.Time = time.Now()
+	}
+
+	// Запись на диск в overflow идёт уже без блокировки, чтобы не задерживать Close
+	queued := false
+	rl.closeMu.RLock()
+	if !rl.closed {
+		select {
+		case rl.entries <- logEntry:
+			queued = true
+		default:
+		}
+	}
+	rl.closeMu.RUnlock()
+
+	if !queued {
+		rl.overflow([]models.RequestLog{logEntry})
+	}
 }
 
-// Log записывает лог запроса в MongoDB
-func (rl *RequestLogger) Log(method, endpoint, userID, ip, userAgent string, statusCode int, duration time.Duration) error {
-	logEntry := models.RequestLog{
-		Method:     method,
-		Endpoint:   endpoint,
-		UserID:     userID,
-		IP:         ip,
-		UserAgent:  userAgent,
-		Time:       time.Now(),
-		StatusCode: statusCode,
-		Duration:   time.Duration(duration.Milliseconds()),
+// Stats возвращает текущие счётчики
+func (rl *RequestLogger) Stats() RequestLoggerStats {
+	return RequestLoggerStats{
+		Queued:  len(rl.entries),
+		Written: rl.written.Load(),
+		Dropped: rl.dropped.Load(),
+		Spilled: rl.spilled.Load(),
 	}
+}
 
-	_, err := rl.collection.InsertOne(context.Background(), logEntry)
-	return err
+// Close прекращает приём записей, дожидается записи буфера и закрывает хранилище.
+// Если ctx завершится раньше, оставшиеся записи будут потеряны (или сохранены на диск в режиме OverflowSpill).
+// Вызывается после остановки HTTP-сервера; повторный вызов только ждёт записи буфера.
+func (rl *RequestLogger) Close(ctx context.Context) error {
+	rl.closeMu.Lock()
+	if !rl.closed {
+		rl.closed = true
+		close(rl.entries)
+	}
+	rl.closeMu.Unlock()
+
+	select {
+	case <-rl.done:
+		return rl.sink.Close()
+	case <-ctx.Done():
+		return fmt.Errorf("буфер логов запросов не записан полностью: %w", ctx.Err())
+	}
+}
+
+// run — фоновый обработчик: собирает пачки и записывает их по заполнению или по таймеру
+func (rl *RequestLogger) run() {
+	defer close(rl.done)
+
+	ticker := time.NewTicker(rl.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.RequestLog, 0, rl.cfg.BatchSize)
+	var reportedDropped int64
+
+	for {
+		select {
+		case entry, ok := <-rl.entries:
+			if !ok {
+				// Буфер закрыт и прочитан до конца
+				rl.flush(batch)
+				return
+			}
+			batch = append(batch, entry)
+			if len(batch) >= rl.cfg.BatchSize {
+				rl.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				rl.flush(batch)
+				batch = batch[:0]
+			}
+			rl.replaySpill()
+
+			// Сообщаем о потерях не чаще одного раза за интервал
+			if dropped := rl.dropped.Load(); dropped > reportedDropped {
+				Warning(fmt.Sprintf("Потеряно логов запросов: %d (всего %d)", dropped-reportedDropped, dropped))
+				reportedDropped = dropped
+			}
+		}
+	}
+}
+
+// flush записывает пачку в хранилище; при ошибке пачка уходит в overflow
+func (rl *RequestLogger) flush(batch []models.RequestLog) {
+	if len(batch) == 0 {
+		return
+	}
+
+	if err := rl.insert(batch); err != nil {
+		Error(fmt.Sprintf("Ошибка записи %d логов запросов: %s", len(batch), err.Error()))
+		rl.overflow(batch)
+	}
+}
+
+// insert сохраняет пачку в хранилище
+func (rl *RequestLogger) insert(batch []models.RequestLog) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rl.cfg.WriteTimeout)
+	defer cancel()
+
+	if err := rl.sink.Write(ctx, batch); err != nil {
+		return fmt.Errorf("%s: %w", rl.sink.Name(), err)
+	}
+	rl.written.Add(int64(len(batch)))
+	return nil
+}
+
+// overflow отбрасывает записи или сохраняет их на диск, в зависимости от настроек
+func (rl *RequestLogger) overflow(entries []models.RequestLog) {
+	if rl.cfg.Overflow == OverflowSpill {
+		err := rl.spill(entries)
+		if err == nil {
+			rl.spilled.Add(int64(len(entries)))
+			return
+		}
+		Error("Не удалось сохранить логи запросов на диск: " + err.Error())
+	}
+	rl.dropped.Add(int64(len(entries)))
+}
+
+// spill дописывает записи в файл на диске, по одной JSON-строке на запись
+func (rl *RequestLogger) spill(entries []models.RequestLog) error {
+	rl.spillMu.Lock()
+	defer rl.spillMu.Unlock()
+
+	file, err := os.OpenFile(rl.cfg.SpillPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for i := range entries {
+		if err := encoder.Encode(&entries[i]); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// replaySpill переносит записи, сохранённые на диск, в хранилище.
+// Файл удаляется только после успешной вставки всех записей.
+func (rl *RequestLogger) replaySpill() {
+	if rl.cfg.Overflow != OverflowSpill {
+		return
+	}
+
+	rl.spillMu.Lock()
+	defer rl.spillMu.Unlock()
+
+	file, err := os.Open(rl.cfg.SpillPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err != nil {
+		Error("Не удалось открыть файл сохранённых логов запросов: " + err.Error())
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var replayed int64
+	batch := make([]models.RequestLog, 0, rl.cfg.BatchSize)
+	insertBatch := func() bool {
+		if err := rl.insert(batch); err != nil {
+			// Хранилище всё ещё недоступно — попробуем в следующий раз.
+			// Уже перенесённые пачки из файла убираются, чтобы не вставить их повторно
+			Warning("Перенос сохранённых логов запросов отложен: " + err.Error())
+			return false
+		}
+		replayed += int64(len(batch))
+		batch = batch[:0]
+		return true
+	}
+
+	for scanner.Scan() {
+		var entry models.RequestLog
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			rl.dropped.Add(1)
+			continue
+		}
+		batch = append(batch, entry)
+		if len(batch) >= rl.cfg.BatchSize && !insertBatch() {
+			break
+		}
+	}
+	if len(batch) > 0 && scanner.Err() == nil {
+		insertBatch()
+	}
+
+	if len(batch) > 0 || scanner.Err() != nil {
+		// Не всё перенесено: оставляем в файле только непрочитанные и неотправленные записи
+		rl.rewriteSpill(file, scanner, batch)
+	} else if err := os.Remove(rl.cfg.SpillPath); err != nil {
+		Error("Не удалось удалить файл сохранённых логов запросов: " + err.Error())
+	}
+
+	if replayed > 0 {
+		Info(fmt.Sprintf("Перенесено в хранилище сохранённых логов запросов: %d", replayed))
+	}
+}
+
+// rewriteSpill заменяет файл сохранённых логов на записи, которые ещё не перенесены в хранилище
+func (rl *RequestLogger) rewriteSpill(file *os.File, scanner *bufio.Scanner, pending []models.RequestLog) {
+	tmpPath := rl.cfg.SpillPath + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		Error("Не удалось переписать файл сохранённых логов запросов: " + err.Error())
+		return
+	}
+
+	writer := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for i := range pending {
+		encoder.Encode(&pending[i])
+	}
+	for scanner.Scan() {
+		writer.Write(scanner.Bytes())
+		writer.WriteByte('\n')
+	}
+
+	if err := writer.Flush(); err != nil {
+		tmp.Close()
+		Error("Не удалось переписать файл сохранённых логов запросов: " + err.Error())
+		return
+	}
+	tmp.Close()
+	file.Close()
+
+	if err := os.Rename(tmpPath, rl.cfg.SpillPath); err != nil {
+		Error("Не удалось переписать файл сохранённых логов запросов: " + err.Error())
+	}
 }
@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"Cloud/models"
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memorySink сохраняет записанные логи в памяти
+type memorySink struct {
+	mu      sync.Mutex
+	entries []models.RequestLog
+}
+
+func (s *memorySink) Name() string { return "memory" }
+
+func (s *memorySink) Write(_ context.Context, batch []models.RequestLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, batch...)
+	return nil
+}
+
+func (s *memorySink) Close() error { return nil }
+
+// Запрос, не завершившийся за время остановки сервера, пишет лог уже после Close: это не должно приводить к панике.
+// Запускайте с -race.
+func TestRequestLoggerLogAfterClose(t *testing.T) {
+	sink := &memorySink{}
+	rl := NewRequestLogger(sink, DefaultRequestLoggerConfig())
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				rl.Log(models.RequestLog{Method: "GET", Endpoint: "/"})
+			}
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := rl.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	rl.Log(models.RequestLog{Method: "GET", Endpoint: "/late"})
+	if err := rl.Close(ctx); err != nil {
+		t.Fatalf("повторный Close: %v", err)
+	}
+
+	stats := rl.Stats()
+	if total := stats.Written + stats.Dropped + stats.Spilled; total != 801 {
+		t.Fatalf("учтено записей %d (%+v), ожидалось 801", total, stats)
+	}
+}
@@ -0,0 +1,356 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateConfig — условия ротации файла
+type RotateConfig struct {
+	MaxSize    int64         // размер в байтах, после которого файл ротируется; 0 — без ограничения
+	Interval   time.Duration // как часто файл ротируется независимо от размера; 0 — только по размеру
+	MaxBackups int           // сколько старых файлов хранить; 0 — старые файлы удаляются сразу
+	Compress   bool          // сжимать старые файлы в gzip
+}
+
+// backupTimeFormat — метка времени в имени старого файла: Cloud.log.20261019T153409.000[-N][.gz].
+// Суффикс -N различает копии, созданные в одну миллисекунду.
+const backupTimeFormat = "20060102T150405.000"
+
+// renameFile переименовывает файл при ротации; тесты подменяют её, чтобы проверить восстановление после ошибки
+var renameFile = os.Rename
+
+// RotatingFile — файл, который по размеру или по времени переименовывается в path.<время> (и при необходимости сжимается),
+// а запись продолжается в новый файл. Хранится не больше MaxBackups старых файлов.
+type RotatingFile struct {
+	path string
+	cfg  RotateConfig
+
+	mu       sync.Mutex
+	file     *os.File // nil — файл не удалось открыть заново, следующая запись попробует ещё раз
+	closed   bool
+	size     int64
+	openedAt time.Time
+
+	compressing sync.WaitGroup // фоновое сжатие старых файлов
+	maintenance sync.Mutex     // сжатие и удаление старых копий выполняются по одному
+}
+
+var (
+	openFilesMu sync.Mutex
+	openFiles   = map[*RotatingFile]struct{}{} // открытые файлы для ReopenFiles
+)
+
+// OpenRotatingFile открывает файл path для дозаписи
+func OpenRotatingFile(path string, cfg RotateConfig) (*RotatingFile, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, err
+		}
+	}
+
+	f := &RotatingFile{path: path, cfg: cfg}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+
+	openFilesMu.Lock()
+	openFiles[f] = struct{}{}
+	openFilesMu.Unlock()
+	return f, nil
+}
+
+// ReopenFiles переоткрывает все открытые RotatingFile по их путям.
+// Вызывается по SIGHUP, когда внешний logrotate переименовал файлы.
+func ReopenFiles() error {
+	openFilesMu.Lock()
+	defer openFilesMu.Unlock()
+
+	var errs []error
+	for f := range openFiles {
+		if err := f.Reopen(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.path, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// open открывает файл и запоминает его текущий размер
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = time.Now()
+	return nil
+}
+
+// Write дописывает p в файл; если p не помещается в MaxSize или истёк Interval, файл предварительно ротируется.
+// Одна запись не разбивается между файлами. Если ротировать не удалось, запись продолжается в прежний файл.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.size > 0 && f.needsRotation(len(p)) {
+		if err := f.rotate(); err != nil {
+			// Сообщение ушло бы в этот же файл, поэтому пишем только в stderr
+			fmt.Fprintf(os.Stderr, "Не удалось ротировать %s: %v\n", f.path, err)
+			if f.file == nil {
+				return 0, err
+			}
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// needsRotation проверяет условия ротации перед записью n байт
+func (f *RotatingFile) needsRotation(n int) bool {
+	if f.cfg.MaxSize > 0 && f.size+int64(n) > f.cfg.MaxSize {
+		return true
+	}
+	return f.cfg.Interval > 0 && time.Since(f.openedAt) >= f.cfg.Interval
+}
+
+// Reopen закрывает файл и открывает его заново по тому же пути.
+// Если открыть не удалось, следующая запись попробует ещё раз.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file != nil {
+		f.file.Close()
+		f.file = nil
+	}
+	return f.open()
+}
+
+// rotate переименовывает текущий файл, открывает новый и в фоне сжимает старый и удаляет лишние копии.
+// Если переименовать не удалось, прежний файл открывается снова, и запись продолжается в него.
+func (f *RotatingFile) rotate() error {
+	f.file.Close()
+	f.file = nil
+
+	backup := f.backupName()
+	if err := renameFile(f.path, backup); err != nil {
+		if openErr := f.open(); openErr != nil {
+			return errors.Join(err, openErr)
+		}
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.compressing.Add(1)
+	go func() {
+		defer f.compressing.Done()
+
+		// Иначе удаление лишних копий после одной ротации может удалить файл, который ещё сжимается после другой
+		f.maintenance.Lock()
+		defer f.maintenance.Unlock()
+
+		if f.cfg.Compress && f.cfg.MaxBackups > 0 {
+			if err := compressFile(backup); err != nil {
+				// Сообщение уйдёт в этот же файл, поэтому пишем только в stderr
+				fmt.Fprintf(os.Stderr, "Не удалось сжать %s: %v\n", backup, err)
+			}
+		}
+		f.prune()
+	}()
+	return nil
+}
+
+// backupName возвращает имя для старой копии, не занятое ни сжатой, ни несжатой копией
+func (f *RotatingFile) backupName() string {
+	base := f.path + "." + time.Now().UTC().Format(backupTimeFormat)
+	name := base
+	for i := 1; fileExists(name) || fileExists(name+".gz"); i++ {
+		name = base + "-" + strconv.Itoa(i)
+	}
+	return name
+}
+
+// fileExists сообщает, есть ли файл с таким именем
+func fileExists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// backupOrder разбирает имя старой копии (без пути к файлу и .gz) на метку времени и номер копии в эту миллисекунду
+func backupOrder(stamp string) (time.Time, int, bool) {
+	stamp, counter, hasCounter := strings.Cut(stamp, "-")
+	t, err := time.Parse(backupTimeFormat, stamp)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+	n := 0
+	if hasCounter {
+		if n, err = strconv.Atoi(counter); err != nil || n < 1 {
+			return time.Time{}, 0, false
+		}
+	}
+	return t, n, true
+}
+
+// compressFile сжимает файл в path.gz и удаляет исходный
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// prune удаляет самые старые копии сверх MaxBackups
+func (f *RotatingFile) prune() {
+	backups := f.backups()
+	for len(backups) > f.cfg.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+// backups возвращает старые копии файла от самой старой к самой новой
+func (f *RotatingFile) backups() []string {
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil
+	}
+
+	// Оставляем только копии с меткой времени и упорядочиваем их по времени и номеру копии
+	type backup struct {
+		name  string
+		time  time.Time
+		count int
+	}
+	var backups []backup
+	for _, name := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, f.path+"."), ".gz")
+		if t, n, ok := backupOrder(stamp); ok {
+			backups = append(backups, backup{name: name, time: t, count: n})
+		}
+	}
+	slices.SortFunc(backups, func(a, b backup) int {
+		if c := a.time.Compare(b.time); c != 0 {
+			return c
+		}
+		return a.count - b.count
+	})
+
+	names := make([]string, len(backups))
+	for i, b := range backups {
+		names[i] = b.name
+	}
+	return names
+}
+
+// Close закрывает файл и дожидается сжатия старых копий
+func (f *RotatingFile) Close() error {
+	openFilesMu.Lock()
+	delete(openFiles, f)
+	openFilesMu.Unlock()
+
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.closed = true
+	f.mu.Unlock()
+
+	f.compressing.Wait()
+	return err
+}
+
+// RotateConfigFromEnv читает условия ротации из переменных окружения с префиксом prefix:
+// <prefix>_MAX_SIZE (байт), <prefix>_ROTATE_INTERVAL, <prefix>_MAX_BACKUPS и <prefix>_COMPRESS.
+// Незаданные переменные берутся из defaults.
+func RotateConfigFromEnv(prefix string, defaults RotateConfig) (RotateConfig, error) {
+	cfg := defaults
+
+	if value := os.Getenv(prefix + "_MAX_SIZE"); value != "" {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("некорректное значение %s_MAX_SIZE: %q", prefix, value)
+		}
+		cfg.MaxSize = n
+	}
+	if value := os.Getenv(prefix + "_ROTATE_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return cfg, fmt.Errorf("некорректное значение %s_ROTATE_INTERVAL: %q", prefix, value)
+		}
+		cfg.Interval = d
+	}
+	if value := os.Getenv(prefix + "_MAX_BACKUPS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return cfg, fmt.Errorf("некорректное значение %s_MAX_BACKUPS: %q", prefix, value)
+		}
+		cfg.MaxBackups = n
+	}
+	if value := os.Getenv(prefix + "_COMPRESS"); value != "" {
+		compress, err := strconv.ParseBool(value)
+		if err != nil {
+			return cfg, fmt.Errorf("некорректное значение %s_COMPRESS: %q", prefix, value)
+		}
+		cfg.Compress = compress
+	}
+
+	return cfg, nil
+}
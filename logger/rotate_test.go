@@ -0,0 +1,215 @@
+package logger
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readFile возвращает содержимое файла, распаковывая .gz
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func writeString(t *testing.T, f *RotatingFile, s string) {
+	t.Helper()
+	if _, err := f.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := OpenRotatingFile(path, RotateConfig{MaxSize: 10, MaxBackups: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Запись не разбивается между файлами: третья строка не помещается и уходит в новый файл
+	writeString(t, f, "line1\n")
+	writeString(t, f, "l2\n")
+	writeString(t, f, "line3\n")
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := f.backups()
+	if len(names) != 1 {
+		t.Fatalf("старых копий %d, ожидалась 1: %v", len(names), names)
+	}
+	if got := readFile(t, names[0]); got != "line1\nl2\n" {
+		t.Errorf("старая копия %q", got)
+	}
+	if got := readFile(t, path); got != "line3\n" {
+		t.Errorf("текущий файл %q", got)
+	}
+}
+
+func TestRotateByInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := OpenRotatingFile(path, RotateConfig{Interval: time.Hour, MaxBackups: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	writeString(t, f, "old\n")
+	writeString(t, f, "still old\n")
+	f.mu.Lock()
+	f.openedAt = time.Now().Add(-2 * time.Hour)
+	f.mu.Unlock()
+	writeString(t, f, "new\n")
+
+	names := f.backups()
+	if len(names) != 1 || readFile(t, names[0]) != "old\nstill old\n" {
+		t.Fatalf("старые копии %v", names)
+	}
+	if got := readFile(t, path); got != "new\n" {
+		t.Errorf("текущий файл %q", got)
+	}
+}
+
+// Ротации в одну миллисекунду не перезаписывают друг друга, а лишние копии удаляются начиная со старых
+func TestRotateCompressAndPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := OpenRotatingFile(path, RotateConfig{MaxSize: 1, MaxBackups: 3, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"1", "2", "3", "4", "5", "6"} {
+		writeString(t, f, line)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	names := f.backups()
+	var contents []string
+	for _, name := range names {
+		if !strings.HasSuffix(name, ".gz") {
+			t.Errorf("копия %s не сжата", name)
+		}
+		contents = append(contents, readFile(t, name))
+	}
+	if !slices.Equal(contents, []string{"3", "4", "5"}) {
+		t.Errorf("в старых копиях %v, ожидались 3, 4, 5", contents)
+	}
+	if got := readFile(t, path); got != "6" {
+		t.Errorf("текущий файл %q", got)
+	}
+	if tmp, _ := filepath.Glob(path + ".*.tmp"); len(tmp) != 0 {
+		t.Errorf("остались временные файлы %v", tmp)
+	}
+}
+
+func TestRotateWithoutBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := OpenRotatingFile(path, RotateConfig{MaxSize: 1, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeString(t, f, "1")
+	writeString(t, f, "2")
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if names := f.backups(); len(names) != 0 {
+		t.Errorf("старые копии не удалены: %v", names)
+	}
+}
+
+// Если переименовать файл не удалось, запись продолжается в прежний файл
+func TestRotateRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := OpenRotatingFile(path, RotateConfig{MaxSize: 1, MaxBackups: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	t.Cleanup(func() { renameFile = os.Rename })
+	renameFile = func(string, string) error { return errors.New("диск недоступен") }
+	writeString(t, f, "1")
+	writeString(t, f, "2")
+	renameFile = os.Rename
+	writeString(t, f, "3")
+
+	names := f.backups()
+	if len(names) != 1 || readFile(t, names[0]) != "12" {
+		t.Fatalf("старые копии %v", names)
+	}
+	if got := readFile(t, path); got != "3" {
+		t.Errorf("текущий файл %q", got)
+	}
+}
+
+// После logrotate, переименовавшего файл, ReopenFiles направляет запись в новый файл по прежнему пути
+func TestReopenFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := OpenRotatingFile(path, RotateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	writeString(t, f, "before\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	writeString(t, f, "still moved\n")
+	if err := ReopenFiles(); err != nil {
+		t.Fatal(err)
+	}
+	writeString(t, f, "after\n")
+
+	if got := readFile(t, path+".1"); got != "before\nstill moved\n" {
+		t.Errorf("переименованный файл %q", got)
+	}
+	if got := readFile(t, path); got != "after\n" {
+		t.Errorf("новый файл %q", got)
+	}
+}
+
+func TestRotatingFileClosed(t *testing.T) {
+	f, err := OpenRotatingFile(filepath.Join(t.TempDir(), "app.log"), RotateConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("x")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("запись после Close: %v", err)
+	}
+	if err := f.Reopen(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Reopen после Close: %v", err)
+	}
+}
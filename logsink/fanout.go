@@ -0,0 +1,76 @@
+package logsink
+
+import (
+	"Cloud/logger"
+	"Cloud/models"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Fanout пишет каждую пачку во все хранилища параллельно.
+// Пачка считается записанной, если её сохранило хотя бы одно хранилище: недоступность одного бэкенда
+// не останавливает логирование, а записи, не попавшие в упавшее хранилище, теряются только в нём.
+type Fanout struct {
+	sinks []logger.RequestLogSink
+}
+
+// NewFanout создаёт хранилище, распределяющее записи по sinks
+func NewFanout(sinks ...logger.RequestLogSink) *Fanout {
+	return &Fanout{sinks: sinks}
+}
+
+// Name возвращает имена хранилищ через запятую
+func (s *Fanout) Name() string {
+	names := make([]string, len(s.sinks))
+	for i, sink := range s.sinks {
+		names[i] = sink.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Write записывает пачку во все хранилища и возвращает ошибку, только если не справилось ни одно
+func (s *Fanout) Write(ctx context.Context, batch []models.RequestLog) error {
+	errs := make([]error, len(s.sinks))
+
+	var wg sync.WaitGroup
+	for i, sink := range s.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sink.Write(ctx, batch); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", sink.Name(), err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == len(s.sinks) {
+		return errors.Join(errs...)
+	}
+	for _, err := range errs {
+		if err != nil {
+			logger.Error(fmt.Sprintf("Потеряно %d логов запросов в хранилище %s", len(batch), err.Error()))
+		}
+	}
+	return nil
+}
+
+// Close закрывает все хранилища
+func (s *Fanout) Close() error {
+	var errs []error
+	for _, sink := range s.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,75 @@
+package logsink
+
+import (
+	"Cloud/logger"
+	"Cloud/models"
+	"bufio"
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// FileConfig — параметры файлового хранилища логов
+type FileConfig struct {
+	Path     string              // путь к файлу JSON Lines
+	Rotation logger.RotateConfig // условия ротации файла
+}
+
+// DefaultFileConfig возвращает параметры файлового хранилища по умолчанию
+func DefaultFileConfig() FileConfig {
+	return FileConfig{
+		Path:     "request_logs.ndjson",
+		Rotation: logger.RotateConfig{MaxSize: 100 << 20, MaxBackups: 5, Compress: true},
+	}
+}
+
+// File пишет логи запросов в файл по одному JSON-объекту на строку с ротацией
+type File struct {
+	mu   sync.Mutex
+	file *logger.RotatingFile
+}
+
+// NewFile открывает файловое хранилище логов
+func NewFile(cfg FileConfig) (*File, error) {
+	file, err := logger.OpenRotatingFile(cfg.Path, cfg.Rotation)
+	if err != nil {
+		return nil, err
+	}
+	return &File{file: file}, nil
+}
+
+// Name возвращает имя хранилища
+func (s *File) Name() string {
+	return SinkFile
+}
+
+// Write дописывает пачку в файл. Каждая запись передаётся в файл целиком, поэтому ротация не разрывает строки.
+func (s *File) Write(ctx context.Context, batch []models.RequestLog) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writer := bufio.NewWriter(s.file)
+	for i := range batch {
+		line, err := json.Marshal(&batch[i])
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+
+		// Если строка не помещается в буфер, буфер сбрасывается заранее, чтобы строка не делилась между файлами
+		if writer.Available() < len(line) {
+			if err := writer.Flush(); err != nil {
+				return err
+			}
+		}
+		if _, err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// Close закрывает файл
+func (s *File) Close() error {
+	return s.file.Close()
+}
@@ -0,0 +1,88 @@
+package logsink
+
+import (
+	"Cloud/dataBase"
+	"Cloud/logger"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+	"os"
+	"strings"
+)
+
+// Имена хранилищ для REQUEST_LOG_SINKS
+const (
+	SinkMongo    = "mongo"
+	SinkPostgres = "postgres"
+	SinkFile     = "file"
+)
+
+// FromEnv собирает хранилище логов запросов из списка REQUEST_LOG_SINKS, например "mongo,file".
+// По умолчанию используется MongoDB, если она подключена (client != nil), иначе файл.
+// Параметры файла: REQUEST_LOG_FILE и условия ротации REQUEST_LOG_FILE_* (см. logger.RotateConfigFromEnv).
+func FromEnv(db *sql.DB, client *mongo.Client) (logger.RequestLogSink, error) {
+	names := os.Getenv("REQUEST_LOG_SINKS")
+	if names == "" {
+		names = SinkFile
+		if client != nil {
+			names = SinkMongo
+		}
+	}
+
+	var sinks []logger.RequestLogSink
+	closeAll := func() {
+		for _, sink := range sinks {
+			sink.Close()
+		}
+	}
+
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case SinkMongo:
+			if client == nil {
+				closeAll()
+				return nil, errors.New("хранилище логов mongo выбрано, но MongoDB не подключена")
+			}
+			sinks = append(sinks, NewMongo(dataBase.RequestLogCollection(client), dataBase.SecurityEventCollection(client)))
+		case SinkPostgres:
+			sinks = append(sinks, NewPostgres(db))
+		case SinkFile:
+			cfg, err := fileConfigFromEnv()
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			sink, err := NewFile(cfg)
+			if err != nil {
+				closeAll()
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		default:
+			closeAll()
+			return nil, fmt.Errorf("неизвестное хранилище логов в REQUEST_LOG_SINKS: %q", name)
+		}
+	}
+
+	if len(sinks) == 1 {
+		return sinks[0], nil
+	}
+	return NewFanout(sinks...), nil
+}
+
+// fileConfigFromEnv читает параметры файлового хранилища из переменных окружения
+func fileConfigFromEnv() (FileConfig, error) {
+	cfg := DefaultFileConfig()
+
+	if path := os.Getenv("REQUEST_LOG_FILE"); path != "" {
+		cfg.Path = path
+	}
+	rotation, err := logger.RotateConfigFromEnv("REQUEST_LOG_FILE", cfg.Rotation)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Rotation = rotation
+
+	return cfg, nil
+}
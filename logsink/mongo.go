@@ -0,0 +1,53 @@
+package logsink
+
+import (
+	"Cloud/dataBase"
+	"Cloud/logger"
+	"Cloud/models"
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Mongo пишет логи запросов в коллекцию MongoDB, а события безопасности дополнительно копирует в отдельную коллекцию
+type Mongo struct {
+	logs           *mongo.Collection
+	securityEvents *mongo.Collection
+}
+
+// NewMongo создаёт хранилище логов в MongoDB
+func NewMongo(logs, securityEvents *mongo.Collection) *Mongo {
+	return &Mongo{logs: logs, securityEvents: securityEvents}
+}
+
+// Name возвращает имя хранилища
+func (s *Mongo) Name() string {
+	return SinkMongo
+}
+
+// Write вставляет пачку в коллекцию логов и копии событий безопасности в их коллекцию.
+// Пачка уже записана в основную коллекцию, поэтому ошибка копирования только логируется: повтор создал бы дубли.
+func (s *Mongo) Write(ctx context.Context, batch []models.RequestLog) error {
+	if err := dataBase.DBInsertRequestLogs(ctx, s.logs, batch); err != nil {
+		return err
+	}
+
+	var events []models.RequestLog
+	for i := range batch {
+		if batch[i].IsSecurityEvent() {
+			events = append(events, batch[i])
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	if err := dataBase.DBInsertRequestLogs(ctx, s.securityEvents, events); err != nil {
+		logger.Error(fmt.Sprintf("Ошибка записи %d событий безопасности: %s", len(events), err.Error()))
+	}
+	return nil
+}
+
+// Close ничего не делает: клиентом MongoDB владеет main
+func (s *Mongo) Close() error {
+	return nil
+}
@@ -0,0 +1,33 @@
+package logsink
+
+import (
+	"Cloud/dataBase"
+	"Cloud/models"
+	"context"
+	"database/sql"
+)
+
+// Postgres пишет логи запросов в таблицу request_logs
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres создаёт хранилище логов в PostgreSQL
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// Name возвращает имя хранилища
+func (s *Postgres) Name() string {
+	return SinkPostgres
+}
+
+// Write вставляет пачку в таблицу request_logs
+func (s *Postgres) Write(ctx context.Context, batch []models.RequestLog) error {
+	return dataBase.DBInsertRequestLogsSQL(ctx, s.db, batch)
+}
+
+// Close ничего не делает: подключением к PostgreSQL владеет main
+func (s *Postgres) Close() error {
+	return nil
+}
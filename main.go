@@ -5,17 +5,29 @@
 package main
 
 import (
+	"Cloud/audit"
+	"Cloud/auth"
 	"Cloud/dataBase"
 	_ "Cloud/docs"
+	"Cloud/email"
+	"Cloud/exports"
 	"Cloud/internal"
 	"Cloud/logger"
+	"Cloud/logsink"
+	"Cloud/notify"
+	"Cloud/retention"
 	"Cloud/routes"
+	"Cloud/utils"
 	"context"
+	"errors"
 	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/mongo"
 	"log"
 	"net/http"
 	"os"
-	"sync"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 // @Summary Основная точка входа приложения.
@@ -26,8 +38,6 @@ import (
 // @Router / [get]
 func main() {
 
-	wg := sync.WaitGroup{}
-
 	// Загрузка переменных
 	err := godotenv.Load()
 	if err != nil {
@@ -35,19 +45,149 @@ func main() {
 	}
 
 	// Инициализация логирования
-	logger.Logging()
+	if err := logger.Logging(); err != nil {
+		log.Fatal("Ошибка инициализации логирования: ", err)
+	}
+	defer logger.LogFile.Close()
+
+	// По SIGHUP файлы логов открываются заново, чтобы внешний logrotate мог их переименовать
+	reopen := make(chan os.Signal, 1)
+	signal.Notify(reopen, syscall.SIGHUP)
+	go func() {
+		for range reopen {
+			if err := logger.ReopenFiles(); err != nil {
+				logger.Error("Не удалось заново открыть файлы логов: " + err.Error())
+			} else {
+				logger.Info("Файлы логов открыты заново")
+			}
+		}
+	}()
+
+	// IP клиента берётся из заголовков X-Forwarded-For/X-Real-IP только от этих прокси
+	if err := utils.SetTrustedProxies(os.Getenv("TRUSTED_PROXIES")); err != nil {
+		log.Fatal("Ошибка настройки доверенных прокси: ", err)
+	}
+
+	// Подключение переопределённых шаблонов писем
+	if err := email.LoadTemplates(os.Getenv("MAIL_TEMPLATES_DIR")); err != nil {
+		log.Fatal("Ошибка загрузки шаблонов писем: ", err)
+	}
 
-	// Подключение к PostgresSQL
-	db := dataBase.ConnectPostgresDB()
-	defer db.Close()
+	// Подключение к PostgresSQL; параметры пула, TLS и реплики задаются переменными POSTGRES_*
+	postgresConfig, err := dataBase.PostgresConfigFromEnv()
+	if err != nil {
+		log.Fatal("Ошибка настройки подключения к PostgreSQL: ", err)
+	}
+	postgres, err := dataBase.ConnectPostgresDB(context.Background(), postgresConfig)
+	if err != nil {
+		log.Fatal("Ошибка подключения к PostgreSQL: ", err)
+	}
+	defer postgres.Close()
+	db := postgres.Primary
 
-	// Подключение к MongoDB
-	client := dataBase.ConnectMongoDB()
-	defer client.Disconnect(context.Background())
+	// Подкоманда migrate управляет схемой базы данных и завершает работу, не запуская сервер
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), db, os.Args[2:]); err != nil {
+			log.Fatal("Ошибка миграции: ", err)
+		}
+		return
+	}
+
+	// Миграции применяются при запуске; экземпляры приложения ждут друг друга на advisory-блокировке
+	if err := migrateOnStart(context.Background(), db); err != nil {
+		log.Fatal("Ошибка миграции: ", err)
+	}
+
+	// MongoDB нужна только для API логов, выгрузок и хранения логов; без MONGO_HOST приложение работает без неё
+	var client *mongo.Client
+	var retentionManager *retention.Manager
+	if os.Getenv("MONGO_HOST") != "" {
+		client = dataBase.ConnectMongoDB()
+		defer client.Disconnect(context.Background())
+
+		// Индексы для фильтров API логов; без них запросы работают, но медленнее
+		indexCtx, cancelIndex := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := dataBase.EnsureRequestLogIndexes(indexCtx, dataBase.RequestLogCollection(client)); err != nil {
+			logger.Warning("Не удалось создать индексы логов запросов: " + err.Error())
+		}
+		if err := dataBase.EnsureRequestLogIndexes(indexCtx, dataBase.SecurityEventCollection(client)); err != nil {
+			logger.Warning("Не удалось создать индексы событий безопасности: " + err.Error())
+		}
+		cancelIndex()
+
+		// Сроки хранения логов: TTL-индексы и архивация перед удалением
+		retentionConfig, err := retention.ConfigFromEnv()
+		if err != nil {
+			log.Fatal("Ошибка настройки хранения логов: ", err)
+		}
+		retentionManager, err = retention.NewManager(dataBase.RequestLogCollection(client), dataBase.SecurityEventCollection(client), retentionConfig)
+		if err != nil {
+			log.Fatal("Ошибка настройки хранения логов: ", err)
+		}
+		ttlCtx, cancelTTL := context.WithTimeout(context.Background(), 30*time.Second)
+		if err := retentionManager.EnsureIndexes(ttlCtx); err != nil {
+			logger.Warning("Не удалось настроить сроки хранения логов: " + err.Error())
+		}
+		cancelTTL()
+		retentionManager.Start(context.Background())
+		defer retentionManager.Stop()
+	} else {
+		logger.Warning("MONGO_HOST не задан: API логов и выгрузки недоступны")
+	}
 
-	// Создаем экземпляр App с логгером запросов
+	// Транспорт для отправки писем выбирается переменной MAIL_TRANSPORT
+	transport, err := email.NewMailerFromEnv()
+	if err != nil {
+		log.Fatal("Ошибка настройки отправки писем: ", err)
+	}
+
+	// Письма отправляются асинхронно через очередь в PostgreSQL
+	outboxConfig, err := email.OutboxConfigFromEnv()
+	if err != nil {
+		log.Fatal("Ошибка настройки очереди писем: ", err)
+	}
+	outbox := email.NewOutbox(db, transport, outboxConfig)
+	outbox.Start(context.Background())
+	defer outbox.Stop()
+
+	// Фоновые выгрузки логов запросов
+	var exportManager *exports.Manager
+	if client != nil {
+		exportConfig, err := exports.ConfigFromEnv()
+		if err != nil {
+			log.Fatal("Ошибка настройки выгрузок: ", err)
+		}
+		exportManager, err = exports.NewManager(db, dataBase.RequestLogCollection(client), exportConfig)
+		if err != nil {
+			log.Fatal("Ошибка настройки выгрузок: ", err)
+		}
+		exportManager.Start(context.Background())
+		defer exportManager.Stop()
+	}
+
+	// Логи запросов пишутся пачками в фоне в хранилища из REQUEST_LOG_SINKS
+	requestLogConfig, err := logger.RequestLoggerConfigFromEnv()
+	if err != nil {
+		log.Fatal("Ошибка настройки логов запросов: ", err)
+	}
+	requestLogSink, err := logsink.FromEnv(db, client)
+	if err != nil {
+		log.Fatal("Ошибка настройки хранилища логов запросов: ", err)
+	}
+	requestLogger := logger.NewRequestLogger(requestLogSink, requestLogConfig)
+
+	// Создаем экземпляр App с зависимостями обработчиков
+	users := dataBase.NewPostgresUserRepository(db, postgres.Replica)
 	app := &internal.App{
-		RequestLogger: logger.NewRequestLogger(client, "Cloud", "logs"),
+		Users:         users,
+		Transactor:    dataBase.NewPostgresTransactor(db),
+		RequestLogger: requestLogger,
+		Mailer:        outbox,
+		Notifier:      notify.New(db, users, outbox, auth.SessionRevokeURL),
+		Exports:       exportManager,
+		Retention:     retentionManager,
+		Audit:         audit.New(db),
+		DBPools:       postgres.Pools(),
 	}
 
 	// Инициализация маршрутов
@@ -56,20 +196,39 @@ func main() {
 	// Инициализация порта сервера
 	portAPI := os.Getenv("SERVER_PORT")
 
+	server := &http.Server{Addr: ":" + portAPI, Handler: router}
+
+	// Сервер останавливается по SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	// Запуск сервера на порту 8081
+	serverErr := make(chan error, 1)
 	go func() {
-		wg.Add(1)
-		defer wg.Done()
-		if err := http.ListenAndServe(":"+portAPI, router); err != nil {
-			logger.Error("Failed to start server: " + err.Error())
-		}
+		serverErr <- server.ListenAndServe()
 	}()
 
 	// Логируем запуск сервера
 	logger.Info("Server started on port " + portAPI)
 
-	// Ожидаем завершения всех горутин
-	wg.Wait()
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("Failed to start server: " + err.Error())
+		}
+	case <-ctx.Done():
+	}
+
+	// Дожидаемся завершения текущих запросов, затем записываем оставшиеся логи запросов
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelShutdown()
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Ошибка остановки сервера: " + err.Error())
+	}
+	if err := requestLogger.Close(shutdownCtx); err != nil {
+		logger.Error(err.Error())
+	}
 
 	// Логируем остановку сервера
 	logger.Error("Server has stopped.")
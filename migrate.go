@@ -0,0 +1,120 @@
+package main
+
+import (
+	"Cloud/dataBase"
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+)
+
+// migrateUsage — справка по подкоманде migrate
+const migrateUsage = `Использование: Cloud migrate [команда]
+
+Команды:
+  up          применить все неприменённые миграции (по умолчанию)
+  down [N]    откатить N последних миграций (по умолчанию 1)
+  status      показать применённые и ожидающие миграции`
+
+// runMigrate выполняет подкоманду migrate с аргументами args
+func runMigrate(ctx context.Context, db *sql.DB, args []string) error {
+	migrator, err := dataBase.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("Схема базы данных актуальна")
+		}
+		for _, m := range applied {
+			fmt.Printf("Применена миграция %d_%s\n", m.Version, m.Name)
+		}
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return fmt.Errorf("некорректное количество миграций для отката: %q", args[1])
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		if len(rolledBack) == 0 {
+			fmt.Println("Нет применённых миграций")
+		}
+		for _, m := range rolledBack {
+			fmt.Printf("Откачена миграция %d_%s\n", m.Version, m.Name)
+		}
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Local().Format(time.RFC3339)
+			}
+			if s.Unknown {
+				appliedAt += " (нет в этой сборке)"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+
+	default:
+		return fmt.Errorf("неизвестная команда migrate %q\n\n%s", command, migrateUsage)
+	}
+	return nil
+}
+
+// migrateOnStart применяет миграции при запуске, если это не отключено переменной DB_MIGRATE_ON_START=false.
+// Когда миграции отключены, возвращает ошибку, если схема базы данных старше, чем ожидает приложение.
+func migrateOnStart(ctx context.Context, db *sql.DB) error {
+	enabled := true
+	if value := os.Getenv("DB_MIGRATE_ON_START"); value != "" {
+		var err error
+		if enabled, err = strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("некорректное значение DB_MIGRATE_ON_START: %q", value)
+		}
+	}
+
+	migrator, err := dataBase.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		_, err := migrator.Up(ctx)
+		return err
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			return fmt.Errorf("миграция %d_%s не применена; выполните Cloud migrate up", s.Version, s.Name)
+		}
+	}
+	return nil
+}
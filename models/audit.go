@@ -0,0 +1,74 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Действия, записываемые в журнал аудита
+const (
+	AuditUserCreate     = "user.create"
+	AuditUserUpdate     = "user.update"
+	AuditUserDelete     = "user.delete"
+	AuditUserBan        = "user.ban"
+	AuditUserUnban      = "user.unban"
+	AuditUserRoleChange = "user.role_change"
+	AuditLogin          = "auth.login"
+	AuditLoginFailed    = "auth.login_failed"
+	AuditLogout         = "auth.logout"
+	AuditSessionRevoke  = "auth.session_revoke"
+)
+
+// FieldChange — значение поля до и после изменения; nil — поля не было
+type FieldChange struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+// AuditEntry — запись журнала аудита.
+// Записи только добавляются; каждая содержит хеш предыдущей, поэтому изменение или удаление записи обнаруживается проверкой цепочки.
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	Time      time.Time              `json:"time"`
+	ActorID   int                    `json:"actor_id"` // кто выполнил действие; 0 — анонимный запрос
+	ActorIP   string                 `json:"actor_ip"`
+	Action    string                 `json:"action"`
+	TargetID  int                    `json:"target_id"` // над каким пользователем; 0 — пользователь неизвестен
+	Changes   map[string]FieldChange `json:"changes,omitempty"`
+	RequestID string                 `json:"request_id,omitempty"`
+	PrevHash  string                 `json:"prev_hash"`
+	Hash      string                 `json:"hash"`
+}
+
+// ComputeHash считает SHA-256 от предыдущего хеша и содержимого записи (без ID и собственного хеша).
+// Изменения приводятся к каноническому JSON, чтобы хеш совпадал после чтения из jsonb.
+func (e *AuditEntry) ComputeHash() string {
+	var changes any
+	if len(e.Changes) > 0 {
+		raw, _ := json.Marshal(e.Changes)
+		json.Unmarshal(raw, &changes)
+	}
+
+	payload, _ := json.Marshal([]any{
+		e.PrevHash,
+		e.Time.UTC().Format(time.RFC3339Nano),
+		e.ActorID,
+		e.ActorIP,
+		e.Action,
+		e.TargetID,
+		changes,
+		e.RequestID,
+	})
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditVerification — результат проверки цепочки хешей журнала аудита
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Checked  int64  `json:"checked"`             // проверено записей
+	BrokenAt int64  `json:"broken_at,omitempty"` // ID первой записи, на которой цепочка нарушена
+	Reason   string `json:"reason,omitempty"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Статусы задания на выгрузку логов
+const (
+	ExportQueued  = "queued"  // ожидает обработчика
+	ExportRunning = "running" // выполняется
+	ExportDone    = "done"    // файл готов к скачиванию
+	ExportFailed  = "failed"  // выгрузка завершилась ошибкой
+	ExportExpired = "expired" // файл удалён по истечении срока хранения
+)
+
+// ExportJob — задание на фоновую выгрузку логов запросов (таблица export_jobs)
+type ExportJob struct {
+	ID         string          `json:"id"`
+	Status     string          `json:"status"`
+	Format     string          `json:"format"`
+	Filter     json.RawMessage `json:"filter" swaggertype:"object"`
+	RowsDone   int64           `json:"rows_done"`
+	RowsTotal  int64           `json:"rows_total"`
+	FilePath   string          `json:"-"`
+	FileSize   int64           `json:"file_size,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	StartedAt  *time.Time      `json:"started_at,omitempty"`
+	FinishedAt *time.Time      `json:"finished_at,omitempty"`
+	ExpiresAt  *time.Time      `json:"expires_at,omitempty"` // когда файл будет удалён
+}
+
+// Progress возвращает долю выполненной работы от 0 до 1
+func (j *ExportJob) Progress() float64 {
+	switch {
+	case j.Status == ExportDone:
+		return 1
+	case j.RowsTotal <= 0:
+		return 0
+	default:
+		return min(float64(j.RowsDone)/float64(j.RowsTotal), 1)
+	}
+}
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+// EndpointBucketStat — количество запросов к endpoint за интервал времени
+type EndpointBucketStat struct {
+	Endpoint string    `json:"endpoint"`
+	Bucket   time.Time `json:"bucket"` // начало интервала (UTC)
+	Requests int64     `json:"requests"`
+}
+
+// LatencyStat — перцентили длительности запросов в миллисекундах
+type LatencyStat struct {
+	Endpoint string  `json:"endpoint,omitempty"` // пусто, если статистика по всем запросам
+	Requests int64   `json:"requests"`
+	AvgMs    float64 `json:"avg_ms"`
+	P50Ms    float64 `json:"p50_ms"`
+	P95Ms    float64 `json:"p95_ms"`
+	P99Ms    float64 `json:"p99_ms"`
+	MaxMs    float64 `json:"max_ms"`
+}
+
+// StatusClassStat — количество и доля ответов класса 1xx–5xx
+type StatusClassStat struct {
+	Class    string  `json:"class"` // например, "5xx"
+	Requests int64   `json:"requests"`
+	Rate     float64 `json:"rate"` // доля от всех запросов, от 0 до 1
+}
+
+// TopStat — значение (пользователь или IP-адрес) и количество его запросов
+type TopStat struct {
+	Value    string `json:"value"`
+	Requests int64  `json:"requests"`
+}
+
+// DailyUsersStat — количество уникальных пользователей за день
+type DailyUsersStat struct {
+	Day   time.Time `json:"day"`
+	Users int64     `json:"users"`
+}
+
+// LogSummary — сводка по логам запросов для выгрузки
+type LogSummary struct {
+	ByEndpoint    []EndpointBucketStat `json:"by_endpoint"`
+	Latency       []LatencyStat        `json:"latency"`
+	StatusClasses []StatusClassStat    `json:"status_classes"`
+	TopUsers      []TopStat            `json:"top_users"`
+	TopIPs        []TopStat            `json:"top_ips"`
+	UniqueUsers   []DailyUsersStat     `json:"unique_users"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// NotificationPreferences — какие уведомления о событиях безопасности получает пользователь.
+// Если пользователь не менял настройки, все уведомления включены.
+type NotificationPreferences struct {
+	UserID          int  `json:"-"`
+	NewLogin        bool `json:"new_login"`        // вход с нового устройства или IP-адреса
+	PasswordChange  bool `json:"password_change"`  // смена пароля
+	EmailChange     bool `json:"email_change"`     // смена адреса электронной почты
+	AccountBan      bool `json:"account_ban"`      // блокировка учётной записи
+	AccountDeletion bool `json:"account_deletion"` // удаление учётной записи
+}
+
+// DefaultNotificationPreferences возвращает настройки по умолчанию: все уведомления включены
+func DefaultNotificationPreferences(userID int) *NotificationPreferences {
+	return &NotificationPreferences{
+		UserID:          userID,
+		NewLogin:        true,
+		PasswordChange:  true,
+		EmailChange:     true,
+		AccountBan:      true,
+		AccountDeletion: true,
+	}
+}
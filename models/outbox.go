@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Статусы письма в очереди исходящей почты
+const (
+	OutboxPending = "pending" // ожидает отправки или повторной попытки
+	OutboxSending = "sending" // забрано обработчиком
+	OutboxSent    = "sent"    // успешно отправлено
+	OutboxDead    = "dead"    // исчерпаны попытки, требуется ручной повтор
+)
+
+// OutboxMessage — письмо в очереди исходящей почты (таблица email_outbox)
+type OutboxMessage struct {
+	ID            int64      `json:"id"`
+	From          string     `json:"from"`
+	Recipient     string     `json:"recipient"`
+	Subject       string     `json:"subject"`
+	Text          string     `json:"-"`
+	HTML          string     `json:"-"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	MaxAttempts   int        `json:"max_attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+}
@@ -1,15 +1,47 @@
 package models
 
-import "time"
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"strings"
+	"time"
+)
 
 // RequestLog представляет собой структуру для логов запросов
 type RequestLog struct {
-	Method     string        `json:"method"`
-	Endpoint   string        `json:"endpoint"`
-	UserID     string        `json:"user_id"`
-	IP         string        `json:"ip"`
-	UserAgent  string        `json:"user_agent"`
-	Time       time.Time     `json:"time"`
-	StatusCode int           `json:"status_code"` // Статус ответа
-	Duration   time.Duration `json:"duration"`    // Время выполнения запроса
+	ID         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	RequestID  string             `json:"request_id" bson:"requestid"` // Идентификатор запроса из заголовка X-Request-ID
+	Method     string             `json:"method" bson:"method"`
+	Endpoint   string             `json:"endpoint" bson:"endpoint"`               // Фактический путь, например /user/42
+	Route      string             `json:"route" bson:"route"`                     // Шаблон маршрута, например /user/{id}; пустой, если маршрут не найден
+	Query      string             `json:"query,omitempty" bson:"query,omitempty"` // Параметры запроса; значения секретов заменены на REDACTED
+	UserID     string             `json:"user_id" bson:"userid"`
+	IP         string             `json:"ip" bson:"ip"` // IP клиента с учётом доверенных прокси
+	UserAgent  string             `json:"user_agent" bson:"useragent"`
+	Time       time.Time          `json:"time" bson:"time"`
+	StatusCode int                `json:"status_code" bson:"statuscode"`                   // Статус ответа
+	ErrorCode  string             `json:"error_code,omitempty" bson:"errorcode,omitempty"` // Код ошибки из ответа apperror
+	BytesIn    int64              `json:"bytes_in" bson:"bytesin"`                         // Прочитано байт тела запроса
+	BytesOut   int64              `json:"bytes_out" bson:"bytesout"`                       // Записано байт тела ответа
+	DurationMs float64            `json:"duration_ms" bson:"duration"`                     // Время выполнения запроса в миллисекундах
+}
+
+// securityRoutes — маршруты входа, регистрации и управления сессиями
+var securityRoutes = map[string]bool{
+	"/login":               true,
+	"/logout":              true,
+	"/register":            true,
+	"/confirm-email":       true,
+	"/resend-confirmation": true,
+	"/refresh-token":       true,
+	"/sessions/revoke":     true,
+}
+
+// IsSecurityEvent сообщает, относится ли запрос к событиям безопасности: вход и сессии,
+// административные маршруты и отказы в доступе. Такие записи хранятся дольше обычных логов.
+func (l *RequestLog) IsSecurityEvent() bool {
+	switch l.StatusCode {
+	case 401, 403, 429:
+		return true
+	}
+	return securityRoutes[l.Route] || l.Route == "/admin" || strings.HasPrefix(l.Route, "/admin/")
 }
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// CollectionRetention — срок хранения и размер одной коллекции логов
+type CollectionRetention struct {
+	Name         string     `json:"name"`
+	Retention    string     `json:"retention"`              // настроенный срок хранения; "0s" — без ограничения
+	TTLSeconds   int64      `json:"ttl_seconds"`            // expireAfterSeconds TTL-индекса в MongoDB; 0 — индекса нет
+	Documents    int64      `json:"documents"`              // количество записей
+	SizeBytes    int64      `json:"size_bytes"`             // размер данных без сжатия
+	StorageBytes int64      `json:"storage_bytes"`          // место на диске под данные
+	IndexBytes   int64      `json:"index_bytes"`            // место на диске под индексы
+	Oldest       *time.Time `json:"oldest,omitempty"`       // время самой старой записи
+	LastArchive  *Archive   `json:"last_archive,omitempty"` // последний архив коллекции
+}
+
+// Archive — результат одного прохода архивации
+type Archive struct {
+	Time    time.Time `json:"time"`
+	File    string    `json:"file,omitempty"` // файл gzip NDJSON; пустой, если записей для архивации не было
+	Records int64     `json:"records"`
+	Bytes   int64     `json:"bytes"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// RetentionStatus — состояние хранения логов
+type RetentionStatus struct {
+	ArchiveEnabled bool                  `json:"archive_enabled"`
+	ArchiveDir     string                `json:"archive_dir,omitempty"`
+	Collections    []CollectionRetention `json:"collections"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Причины, по которым адрес попадает в список подавления
+const (
+	SuppressionBounce    = "bounce"    // постоянный отказ доставки (hard bounce)
+	SuppressionComplaint = "complaint" // получатель пожаловался на спам
+	SuppressionManual    = "manual"    // добавлен администратором
+)
+
+// Suppression — адрес, на который письма не отправляются (таблица email_suppressions)
+type Suppression struct {
+	Email      string    `json:"email"`
+	Reason     string    `json:"reason"`
+	Detail     string    `json:"detail,omitempty"` // ответ почтового сервера или текст жалобы
+	Source     string    `json:"source,omitempty"` // кто сообщил о событии, например имя почтового провайдера
+	EventCount int       `json:"event_count"`      // сколько раз приходили события по этому адресу
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"sync"
+	"time"
+)
 
 // Структура для хранения кода подтверждения, данных пользователя и времени создания
 type ConfirmationData struct {
@@ -9,5 +12,79 @@ type ConfirmationData struct {
 	CreatedAt time.Time
 }
 
+// ClaimStatus — результат попытки использовать код подтверждения
+type ClaimStatus int
+
+const (
+	ClaimOK      ClaimStatus = iota // код верный, запись забрана из хранилища
+	ClaimMissing                    // для email нет незавершённой регистрации
+	ClaimExpired                    // код просрочен; запись удалена
+	ClaimInvalid                    // код не совпал; запись осталась
+)
+
+// ConfirmationStore хранит незавершённые регистрации. Безопасен для одновременного использования.
+type ConfirmationStore struct {
+	mu      sync.Mutex
+	entries map[string]ConfirmationData
+}
+
+// NewConfirmationStore создаёт пустое хранилище
+func NewConfirmationStore() *ConfirmationStore {
+	return &ConfirmationStore{entries: map[string]ConfirmationData{}}
+}
+
+// Put сохраняет регистрацию для email, заменяя прежнюю
+func (s *ConfirmationStore) Put(email string, data ConfirmationData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[email] = data
+}
+
+// Renew заменяет код незавершённой регистрации и отсчитывает срок действия заново.
+// Возвращает false, если регистрации для email нет.
+func (s *ConfirmationStore) Renew(email, code string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.entries[email]
+	if !ok {
+		return false
+	}
+	data.Code = code
+	data.CreatedAt = time.Now()
+	s.entries[email] = data
+	return true
+}
+
+// Claim проверяет код и срок действия и, если код верный, забирает запись из хранилища.
+// Проверка и удаление выполняются под одной блокировкой, поэтому один код может использовать только один запрос.
+func (s *ConfirmationStore) Claim(email, code string, ttl time.Duration) (ConfirmationData, ClaimStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, ok := s.entries[email]
+	switch {
+	case !ok:
+		return ConfirmationData{}, ClaimMissing
+	case time.Since(data.CreatedAt) > ttl:
+		delete(s.entries, email)
+		return ConfirmationData{}, ClaimExpired
+	case data.Code != code:
+		return ConfirmationData{}, ClaimInvalid
+	}
+	delete(s.entries, email)
+	return data, ClaimOK
+}
+
+// Restore возвращает забранную Claim запись, если пользователя создать не удалось.
+// Новую регистрацию, сохранённую за это время, запись не перезаписывает.
+func (s *ConfirmationStore) Restore(email string, data ConfirmationData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.entries[email]; !ok {
+		s.entries[email] = data
+	}
+}
+
 // Временное хранилище для подтверждения email
-var TemporaryStore = make(map[string]ConfirmationData)
+var TemporaryStore = NewConfirmationStore()
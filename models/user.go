@@ -2,6 +2,22 @@ package models
 
 import "time"
 
+// Роли пользователей
+const (
+	RoleUser    = "user"    // обычный пользователь
+	RoleAdmin   = "admin"   // администратор: доступ к /admin
+	RoleAuditor = "auditor" // аудитор: только чтение журнала аудита
+)
+
+// IsValidRole проверяет, существует ли роль
+func IsValidRole(role string) bool {
+	switch role {
+	case RoleUser, RoleAdmin, RoleAuditor:
+		return true
+	}
+	return false
+}
+
 // User представляет пользователя в системе.
 // @Description Модель пользователя с основными полями.
 // @Title User
@@ -43,5 +59,32 @@ type User struct {
 	// @Example false
 	IsBanned bool `json:"isBanned"`
 
+	// @Description Язык сообщений и писем для пользователя ("ru" или "en")
+	// @Example "ru"
+	Locale string `json:"locale"`
+
+	// @Description Роль пользователя ("user", "admin" или "auditor")
+	// @Example "user"
+	Role string `json:"role"`
+
+	// @Description Версия записи; увеличивается при каждом изменении и передаётся в ETag
+	// @Example 1
+	Version int64 `json:"version"`
+
 	TokenExpiresAt time.Time `json:"token_expires_at"`
 }
+
+// UserPatch — частичное изменение пользователя. nil — поле не меняется;
+// пустая строка и false записываются как есть, поэтому PATCH может очистить телефон или снять блокировку.
+type UserPatch struct {
+	ID             int
+	Version        int64 // обязательна: изменение применяется только к этой версии; после применения — новая версия
+	Name           *string
+	Phone          *string
+	Email          *string
+	Password       *string // уже захешированный
+	Locale         *string
+	IsDeleted      *bool
+	IsBanned       *bool
+	FromDateUpdate string
+}
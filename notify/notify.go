@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"Cloud/dataBase"
+	"Cloud/email"
+	"Cloud/i18n"
+	"Cloud/logger"
+	"Cloud/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// RevokeURLFunc строит ссылку, по которой пользователь может завершить сеанс с временем истечения sessionExpiresAt
+type RevokeURLFunc func(userID int, sessionExpiresAt time.Time) (string, error)
+
+// Notifier отправляет пользователям письма о событиях безопасности: входе с нового устройства,
+// смене пароля или адреса почты, блокировке и удалении учётной записи.
+// Уведомления не должны мешать основной операции, поэтому ошибки отправки только логируются.
+// nil Notifier ничего не отправляет, а Notifier без базы данных не запоминает устройства входа и считает,
+// что у всех пользователей настройки уведомлений по умолчанию; это позволяет тестировать обработчики без базы данных.
+type Notifier struct {
+	db        *sql.DB
+	users     dataBase.UserRepository
+	mailer    email.Mailer
+	revokeURL RevokeURLFunc
+}
+
+// New создаёт Notifier; revokeURL подписывает ссылки на завершение сеанса
+func New(db *sql.DB, users dataBase.UserRepository, mailer email.Mailer, revokeURL RevokeURLFunc) *Notifier {
+	return &Notifier{db: db, users: users, mailer: mailer, revokeURL: revokeURL}
+}
+
+// Login запоминает устройство и IP-адрес входа и, если хотя бы одно из них новое, предупреждает пользователя.
+// Первый вход после регистрации не считается подозрительным.
+func (n *Notifier) Login(ctx context.Context, user *models.User, ip, userAgent string, sessionExpiresAt time.Time) {
+	if n == nil || n.db == nil {
+		return
+	}
+	origin, err := dataBase.DBRecordLogin(ctx, n.db, user.ID, ip, userAgent)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось сохранить устройство входа пользователя", "target_id", user.ID, "error", err.Error())
+		return
+	}
+	if origin.FirstLogin || (!origin.NewIP && !origin.NewDevice) {
+		return
+	}
+
+	n.send(ctx, user, email.TemplateLoginAlert, func(p *models.NotificationPreferences) bool { return p.NewLogin },
+		func(revokeURL string) any {
+			return email.LoginAlertData{Time: time.Now(), IP: ip, UserAgent: userAgent, RevokeURL: revokeURL}
+		}, sessionExpiresAt)
+}
+
+// PasswordChanged сообщает о смене пароля
+func (n *Notifier) PasswordChanged(ctx context.Context, user *models.User) {
+	if n == nil {
+		return
+	}
+	n.send(ctx, user, email.TemplatePasswordChanged, func(p *models.NotificationPreferences) bool { return p.PasswordChange },
+		func(revokeURL string) any {
+			return email.PasswordChangedData{Name: user.Name, Time: time.Now(), RevokeURL: revokeURL}
+		}, n.activeSession(ctx, user.ID))
+}
+
+// EmailChanged сообщает о смене адреса почты; письмо уходит на прежний адрес, чтобы владелец мог заметить подмену
+func (n *Notifier) EmailChanged(ctx context.Context, user *models.User, newEmail string) {
+	if n == nil {
+		return
+	}
+	n.send(ctx, user, email.TemplateEmailChanged, func(p *models.NotificationPreferences) bool { return p.EmailChange },
+		func(revokeURL string) any {
+			return email.EmailChangedData{Name: user.Name, Time: time.Now(), NewEmail: newEmail, RevokeURL: revokeURL}
+		}, n.activeSession(ctx, user.ID))
+}
+
+// AccountBanned сообщает о блокировке учётной записи
+func (n *Notifier) AccountBanned(ctx context.Context, user *models.User) {
+	if n == nil {
+		return
+	}
+	n.send(ctx, user, email.TemplateAccountBanned, func(p *models.NotificationPreferences) bool { return p.AccountBan },
+		func(revokeURL string) any {
+			return email.AccountBannedData{Name: user.Name, Time: time.Now(), RevokeURL: revokeURL}
+		}, n.activeSession(ctx, user.ID))
+}
+
+// AccountDeleted сообщает об удалении учётной записи
+func (n *Notifier) AccountDeleted(ctx context.Context, user *models.User) {
+	if n == nil {
+		return
+	}
+	n.send(ctx, user, email.TemplateAccountDeletion, func(p *models.NotificationPreferences) bool { return p.AccountDeletion },
+		func(revokeURL string) any {
+			return email.AccountDeletionData{Name: user.Name, Time: time.Now(), RevokeURL: revokeURL}
+		}, n.activeSession(ctx, user.ID))
+}
+
+// activeSession возвращает время истечения текущего сеанса пользователя; нулевое время, если активного сеанса нет
+func (n *Notifier) activeSession(ctx context.Context, userID int) time.Time {
+	expiresAt, err := n.users.GetTokenExpiration(ctx, userID)
+	if err != nil || !expiresAt.After(time.Now()) {
+		return time.Time{}
+	}
+	return expiresAt
+}
+
+// preferences возвращает настройки уведомлений пользователя; без базы данных — настройки по умолчанию
+func (n *Notifier) preferences(ctx context.Context, userID int) (*models.NotificationPreferences, error) {
+	if n.db == nil {
+		return models.DefaultNotificationPreferences(userID), nil
+	}
+	return dataBase.DBGetNotificationPreferences(ctx, n.db, userID)
+}
+
+// send проверяет настройки пользователя, строит ссылку на завершение сеанса и ставит письмо в очередь
+func (n *Notifier) send(ctx context.Context, user *models.User, template string, enabled func(*models.NotificationPreferences) bool,
+	data func(revokeURL string) any, sessionExpiresAt time.Time) {
+	prefs, err := n.preferences(ctx, user.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось получить настройки уведомлений пользователя", "target_id", user.ID, "error", err.Error())
+		return
+	}
+	if !enabled(prefs) {
+		return
+	}
+
+	// Ссылка на завершение сеанса нужна, только если сеанс ещё действует
+	var revokeURL string
+	if !sessionExpiresAt.IsZero() && n.revokeURL != nil {
+		revokeURL, err = n.revokeURL(user.ID, sessionExpiresAt)
+		if err != nil {
+			logger.ErrorContext(ctx, "Не удалось подписать ссылку на завершение сеанса", "target_id", user.ID, "error", err.Error())
+		}
+	}
+
+	lang := user.Locale
+	if !i18n.IsSupported(lang) {
+		lang = i18n.Default
+	}
+
+	msg, err := email.Compose(template, lang, user.Email, data(revokeURL))
+	if err != nil {
+		logger.ErrorContext(ctx, "Не удалось собрать письмо", "template", template, "target_id", user.ID, "error", err.Error())
+		return
+	}
+	if err := n.mailer.Send(ctx, msg); email.IsSuppressed(err) {
+		logger.InfoContext(ctx, "Письмо не отправлено", "template", template, "target_id", user.ID, "error", err.Error())
+	} else if err != nil {
+		logger.ErrorContext(ctx, "Не удалось отправить письмо", "template", template, "target_id", user.ID, "error", err.Error())
+	}
+}
@@ -0,0 +1,288 @@
+package retention
+
+import (
+	"Cloud/dataBase"
+	"Cloud/logger"
+	"Cloud/models"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Config — сроки хранения логов и параметры архивации
+type Config struct {
+	Logs           time.Duration // срок хранения логов запросов; 0 — без ограничения
+	SecurityEvents time.Duration // срок хранения событий безопасности; 0 — без ограничения
+	ArchiveDir     string        // каталог архивов gzip NDJSON; пустой — архивация выключена
+	Interval       time.Duration // как часто запускается архивация
+	ArchiveGrace   time.Duration // запас TTL-индекса при включённой архивации, чтобы MongoDB не удалила записи раньше архивации
+}
+
+// DefaultConfig возвращает параметры хранения логов по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		Logs:           30 * 24 * time.Hour,
+		SecurityEvents: 365 * 24 * time.Hour,
+		Interval:       time.Hour,
+		ArchiveGrace:   24 * time.Hour,
+	}
+}
+
+// ConfigFromEnv читает параметры из переменных окружения LOG_RETENTION, SECURITY_LOG_RETENTION и LOG_ARCHIVE_*.
+// Значение 0 у сроков хранения отключает удаление.
+func ConfigFromEnv() (Config, error) {
+	cfg := DefaultConfig()
+
+	cfg.ArchiveDir = os.Getenv("LOG_ARCHIVE_DIR")
+
+	durations := []struct {
+		name     string
+		dst      *time.Duration
+		zeroOkay bool
+	}{
+		{"LOG_RETENTION", &cfg.Logs, true},
+		{"SECURITY_LOG_RETENTION", &cfg.SecurityEvents, true},
+		{"LOG_ARCHIVE_INTERVAL", &cfg.Interval, false},
+		{"LOG_ARCHIVE_GRACE", &cfg.ArchiveGrace, true},
+	}
+	for _, v := range durations {
+		if value := os.Getenv(v.name); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil || d < 0 || (d == 0 && !v.zeroOkay) {
+				return cfg, fmt.Errorf("некорректное значение %s: %q", v.name, value)
+			}
+			*v.dst = d
+		}
+	}
+
+	return cfg, nil
+}
+
+// collection — коллекция логов со своим сроком хранения
+type collection struct {
+	coll        *mongo.Collection
+	retention   time.Duration
+	lastArchive *models.Archive
+}
+
+// Manager поддерживает сроки хранения логов: TTL-индексы в MongoDB и, если задан каталог, архивацию перед удалением.
+// При включённой архивации TTL-индекс срабатывает на ArchiveGrace позже срока хранения и служит страховкой:
+// записи удаляются архиватором сразу после записи архива.
+type Manager struct {
+	cfg         Config
+	collections []*collection
+
+	mu     sync.Mutex // защищает lastArchive
+	wg     sync.WaitGroup
+	cancel context.CancelFunc
+}
+
+// NewManager создаёт Manager для коллекций логов запросов и событий безопасности
+func NewManager(logs, securityEvents *mongo.Collection, cfg Config) (*Manager, error) {
+	if cfg.ArchiveDir != "" {
+		if err := os.MkdirAll(cfg.ArchiveDir, 0o750); err != nil {
+			return nil, fmt.Errorf("не удалось создать каталог архивов логов: %w", err)
+		}
+	}
+
+	return &Manager{
+		cfg: cfg,
+		collections: []*collection{
+			{coll: logs, retention: cfg.Logs},
+			{coll: securityEvents, retention: cfg.SecurityEvents},
+		},
+	}, nil
+}
+
+// EnsureIndexes приводит TTL-индексы коллекций в соответствие с настройками
+func (m *Manager) EnsureIndexes(ctx context.Context) error {
+	for _, c := range m.collections {
+		if err := dataBase.DBEnsureLogTTL(ctx, c.coll, m.ttl(c)); err != nil {
+			return fmt.Errorf("TTL-индекс %s: %w", c.coll.Name(), err)
+		}
+	}
+	return nil
+}
+
+// ttl возвращает срок TTL-индекса коллекции с учётом запаса на архивацию
+func (m *Manager) ttl(c *collection) time.Duration {
+	if c.retention == 0 {
+		return 0
+	}
+	if m.cfg.ArchiveDir != "" {
+		return c.retention + m.cfg.ArchiveGrace
+	}
+	return c.retention
+}
+
+// Status возвращает сроки хранения, размеры коллекций и результаты последней архивации
+func (m *Manager) Status(ctx context.Context) (*models.RetentionStatus, error) {
+	status := &models.RetentionStatus{
+		ArchiveEnabled: m.cfg.ArchiveDir != "",
+		ArchiveDir:     m.cfg.ArchiveDir,
+		Collections:    make([]models.CollectionRetention, 0, len(m.collections)),
+	}
+
+	for _, c := range m.collections {
+		stats := models.CollectionRetention{Name: c.coll.Name(), Retention: c.retention.String()}
+
+		ttl, err := dataBase.DBGetLogTTL(ctx, c.coll)
+		if err != nil {
+			return nil, err
+		}
+		stats.TTLSeconds = ttl
+
+		if err := dataBase.DBLogCollectionStats(ctx, c.coll, &stats); err != nil {
+			return nil, err
+		}
+
+		m.mu.Lock()
+		stats.LastArchive = c.lastArchive
+		m.mu.Unlock()
+
+		status.Collections = append(status.Collections, stats)
+	}
+	return status, nil
+}
+
+// Start запускает периодическую архивацию, если она включена
+func (m *Manager) Start(ctx context.Context) {
+	if m.cfg.ArchiveDir == "" {
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		ticker := time.NewTicker(m.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			m.archiveAll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	logger.Info("Архивация логов запущена, архивы в " + m.cfg.ArchiveDir)
+}
+
+// Stop останавливает архивацию; незавершённый архив удаляется, записи остаются в MongoDB
+func (m *Manager) Stop() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+}
+
+// archiveAll архивирует устаревшие записи всех коллекций с ограниченным сроком хранения
+func (m *Manager) archiveAll(ctx context.Context) {
+	for _, c := range m.collections {
+		if c.retention == 0 || ctx.Err() != nil {
+			continue
+		}
+
+		archive := m.archive(ctx, c)
+		if ctx.Err() != nil {
+			return
+		}
+		if archive.Error != "" {
+			logger.Error(fmt.Sprintf("Ошибка архивации %s: %s", c.coll.Name(), archive.Error))
+		} else if archive.Records > 0 {
+			logger.Info(fmt.Sprintf("Архивировано %d записей %s в %s", archive.Records, c.coll.Name(), archive.File))
+		}
+
+		m.mu.Lock()
+		c.lastArchive = archive
+		m.mu.Unlock()
+	}
+}
+
+// archive сохраняет записи старше срока хранения в gzip NDJSON и удаляет их из MongoDB.
+// Файл пишется во временный и переименовывается только после успешной записи, поэтому записи не теряются при сбое.
+func (m *Manager) archive(ctx context.Context, c *collection) *models.Archive {
+	now := time.Now().UTC()
+	before := now.Add(-c.retention)
+	archive := &models.Archive{Time: now}
+
+	fail := func(err error) *models.Archive {
+		archive.Error = err.Error()
+		return archive
+	}
+
+	tmp, err := os.CreateTemp(m.cfg.ArchiveDir, "."+c.coll.Name()+"-*.tmp")
+	if err != nil {
+		return fail(err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	buffered := bufio.NewWriter(tmp)
+	gz := gzip.NewWriter(buffered)
+	encoder := json.NewEncoder(gz)
+
+	var maxID primitive.ObjectID
+	err = dataBase.DBEachRequestLog(ctx, c.coll, dataBase.RequestLogFilter{To: before}, func(log *models.RequestLog) error {
+		if bytes.Compare(log.ID[:], maxID[:]) > 0 {
+			maxID = log.ID
+		}
+		archive.Records++
+		return encoder.Encode(log)
+	})
+	if err != nil {
+		return fail(err)
+	}
+	if archive.Records == 0 {
+		return archive
+	}
+
+	if err := gz.Close(); err != nil {
+		return fail(err)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return fail(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fail(err)
+	}
+
+	name := fmt.Sprintf("%s-%s.ndjson.gz", c.coll.Name(), before.Format("20060102T150405Z"))
+	path := filepath.Join(m.cfg.ArchiveDir, name)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fail(err)
+	}
+	archive.File = path
+	archive.Bytes = info.Size()
+
+	// Архив уже на диске: удаление завершаем даже при остановке приложения
+	deleted, err := dataBase.DBDeleteArchivedLogs(context.WithoutCancel(ctx), c.coll, before, maxID)
+	if err != nil {
+		return fail(fmt.Errorf("архив %s записан, но записи не удалены: %w", path, err))
+	}
+	if deleted != archive.Records {
+		logger.Warning(fmt.Sprintf("Архивировано %d записей %s, удалено %d", archive.Records, c.coll.Name(), deleted))
+	}
+	return archive
+}
@@ -1,14 +1,18 @@
 package routes
 
 import (
+	"Cloud/apperror"
 	"Cloud/auth"
 	"Cloud/handlers"
+	"Cloud/i18n"
 	"Cloud/internal"
+	"Cloud/models"
 	"database/sql"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 	"go.mongodb.org/mongo-driver/mongo"
 	"net/http"
+	"os"
 )
 
 // InitializeRoutes инициализирует маршруты приложения.
@@ -17,8 +21,26 @@ import (
 func InitializeRoutes(db *sql.DB, client *mongo.Client, app *internal.App) *mux.Router {
 	r := mux.NewRouter()
 
-	// Подключаем логирующее middleware
-	r.Use(auth.LoggingMiddleware(app))
+	// Общие middleware: идентификатор запроса, язык ответа и логирование, в порядке вызова
+	middlewares := []mux.MiddlewareFunc{auth.RequestIDMiddleware, auth.LanguageMiddleware, auth.LoggingMiddleware(app)}
+	r.Use(middlewares...)
+
+	// r.Use не применяется к NotFoundHandler и MethodNotAllowedHandler, поэтому оборачиваем их сами:
+	// без этого в ответе нет request_id, сообщение не переводится, а запрос не попадает в лог
+	withMiddlewares := func(h http.Handler) http.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			h = middlewares[i](h)
+		}
+		return h
+	}
+
+	// Ответы на неизвестные маршруты и методы в едином JSON-формате
+	r.NotFoundHandler = withMiddlewares(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apperror.Write(w, r, apperror.ErrRouteNotFound)
+	}))
+	r.MethodNotAllowedHandler = withMiddlewares(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apperror.Write(w, r, apperror.ErrMethodNotAllowed)
+	}))
 
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)   // Установка статус ответа
@@ -31,53 +53,195 @@ func InitializeRoutes(db *sql.DB, client *mongo.Client, app *internal.App) *mux.
 	// @Produce json
 	// @Param user body models.User true "Пользователь"
 	// @Success 201 {string} string "Пользователь успешно создан"
-	// @Failure 400 {string} string "Ошибка валидации"
+	// @Failure 400 {object} apperror.ErrorResponse "Ошибка валидации"
+	// @Failure 409 {object} apperror.ErrorResponse "Email или телефон уже заняты"
 	// @Router /user [post]
-	r.HandleFunc("/user", handlers.CreateUser(db)).Methods("POST")
+	r.HandleFunc("/user", handlers.CreateUser(app.Users, app.Audit)).Methods("POST")
 
 	// @Summary Получение информации о пользователе
 	// @Description Получает информацию о пользователе по его уникальному идентификатору.
 	// @Produce json
 	// @Param id path int true "ID пользователя"
+	// @Param If-None-Match header string false "ETag сохранённой у клиента версии"
 	// @Success 200 {object} models.User "Информация о пользователе"
-	// @Failure 400 {string} string "Ошибка при получении пользователя"
+	// @Success 304 "Версия у клиента актуальна"
+	// @Failure 404 {object} apperror.ErrorResponse "Пользователь не найден"
 	// @Router /user/{id} [get]
-	r.HandleFunc("/user/{id}", handlers.GetUser(db)).Methods("GET")
+	r.HandleFunc("/user/{id}", handlers.GetUser(app.Users)).Methods("GET")
 
 	// @Summary Обновление пользователя
 	// @Description Обновляет информацию о пользователе.
 	// @Accept json
 	// @Produce json
 	// @Param id path int true "ID пользователя"
+	// @Param If-Match header string true "ETag изменяемой версии"
 	// @Param user body models.User true "Обновленный пользователь"
 	// @Success 204 {string} string "Пользователь успешно обновлен"
-	// @Failure 400 {string} string "Ошибка при обновлении пользователя"
+	// @Failure 400 {object} apperror.ErrorResponse "Ошибка при обновлении пользователя"
+	// @Failure 401 {object} apperror.ErrorResponse "Требуется авторизация"
+	// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав для изменения пользователя или поля"
+	// @Failure 409 {object} apperror.ErrorResponse "Email или телефон заняты другим пользователем"
+	// @Failure 412 {object} apperror.ErrorResponse "Пользователь изменён другим запросом"
+	// @Failure 428 {object} apperror.ErrorResponse "Не передан заголовок If-Match"
 	// @Router /user/{id} [put]
-	r.HandleFunc("/user/{id}", handlers.UpdateUser(db)).Methods("PUT")
+	r.Handle("/user/{id}", auth.JWTMiddleware(app.Users, handlers.UpdateUser(app.Users, app.Transactor, app.Notifier, app.Audit))).Methods("PUT")
+
+	// @Summary Частичное изменение пользователя
+	// @Description Применяет JSON Merge Patch (RFC 7386) или JSON Patch (RFC 6902). Пользователь меняет свои имя, телефон,
+	// @Description email, пароль и язык; администратор — также isDeleted и isBanned любого пользователя.
+	// @Accept application/merge-patch+json
+	// @Accept application/json-patch+json
+	// @Produce json
+	// @Param id path int true "ID пользователя"
+	// @Param If-Match header string true "ETag изменяемой версии"
+	// @Success 200 {object} models.User "Изменённый пользователь"
+	// @Failure 400 {object} apperror.ErrorResponse "Некорректный патч или значение поля"
+	// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав для изменения поля"
+	// @Failure 409 {object} apperror.ErrorResponse "Email или телефон заняты либо не выполнена операция test"
+	// @Failure 412 {object} apperror.ErrorResponse "Пользователь изменён другим запросом"
+	// @Failure 415 {object} apperror.ErrorResponse "Неподдерживаемый формат патча"
+	// @Failure 428 {object} apperror.ErrorResponse "Не передан заголовок If-Match"
+	// @Router /user/{id} [patch]
+	r.Handle("/user/{id}", auth.JWTMiddleware(app.Users, handlers.PatchUser(app.Users, app.Transactor, app.Notifier, app.Audit))).Methods("PATCH")
 
 	// @Summary Удаление пользователя
 	// @Description Удаляет пользователя из системы по его ID.
 	// @Param id path int true "ID пользователя"
 	// @Success 204 {string} string "Пользователь успешно удален"
-	// @Failure 400 {string} string "Ошибка при удалении пользователя"
+	// @Failure 400 {object} apperror.ErrorResponse "Ошибка при удалении пользователя"
+	// @Failure 401 {object} apperror.ErrorResponse "Требуется авторизация"
+	// @Failure 403 {object} apperror.ErrorResponse "Удалить пользователя может он сам или администратор"
 	// @Router /user/{id} [delete]
-	r.HandleFunc("/user/{id}", handlers.DeleteUser(db)).Methods("DELETE")
+	r.Handle("/user/{id}", auth.JWTMiddleware(app.Users, handlers.DeleteUser(app.Users, app.Transactor, app.Notifier, app.Audit))).Methods("DELETE")
+
+	// @Summary Настройки уведомлений пользователя
+	// @Description Возвращает настройки писем о событиях безопасности.
+	// @Produce json
+	// @Param id path int true "ID пользователя"
+	// @Success 200 {object} models.NotificationPreferences "Настройки уведомлений"
+	// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+	// @Router /user/{id}/notifications [get]
+	r.Handle("/user/{id}/notifications", auth.JWTMiddleware(app.Users, handlers.GetNotificationPreferences(db, app.Users))).Methods("GET")
+
+	// @Summary Изменение настроек уведомлений пользователя
+	// @Description Включает или отключает письма о событиях безопасности.
+	// @Accept json
+	// @Produce json
+	// @Param id path int true "ID пользователя"
+	// @Param preferences body models.NotificationPreferences true "Настройки уведомлений"
+	// @Success 200 {object} models.NotificationPreferences "Сохранённые настройки"
+	// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+	// @Router /user/{id}/notifications [put]
+	r.Handle("/user/{id}/notifications", auth.JWTMiddleware(app.Users, handlers.UpdateNotificationPreferences(db, app.Users))).Methods("PUT")
 
 	// @Summary Получение всех пользователей
 	// @Description Получает список всех пользователей в системе.
 	// @Produce json
 	// @Success 200 {array} models.User "Список пользователей"
-	// @Failure 400 {string} string "Ошибка при получении пользователей"
+	// @Failure 400 {object} apperror.ErrorResponse "Ошибка при получении пользователей"
 	// @Router /users [get]
-	r.HandleFunc("/users", handlers.GetAllUsers(db)).Methods("GET")
+	r.HandleFunc("/users", handlers.GetAllUsers(app.Users)).Methods("GET")
+
+	// Логи запросов содержат IP-адреса, ID пользователей, пути и user agent:
+	// API логов и выгрузки доступны только администраторам и аудиторам
+	requireLogAccess := func(sub *mux.Router) *mux.Router {
+		sub.Use(func(next http.Handler) http.Handler { return auth.JWTMiddleware(app.Users, next) })
+		sub.Use(auth.RequireRole(app.Users, models.RoleAdmin, models.RoleAuditor))
+		return sub
+	}
+	logs := requireLogAccess(r.PathPrefix("/logs").Subrouter())
+	exports := requireLogAccess(r.PathPrefix("/exports").Subrouter())
 
-	// @Summary Получение всех логов запросов
-	// @Description Получает список всех логов запросов из системы.
-	// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
-	// @Success 200 {file} file "Excel-файл с логами запросов"
-	// @Failure 400 {string} string "Ошибка при получении логов"
-	// @Router /logs [get]
-	r.HandleFunc("/logs", handlers.GetAllRequestLogs(client)).Methods("GET")
+	// API логов и выгрузки читают MongoDB; без неё эти маршруты отвечают 503
+	if client != nil {
+		// @Summary Получение логов запросов
+		// @Description Возвращает логи запросов по фильтрам с постраничной выдачей по курсору.
+		// @Produce json
+		// @Success 200 {object} handlers.RequestLogPage "Страница логов"
+		// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+		// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+		// @Router /logs [get]
+		logs.HandleFunc("", handlers.ListRequestLogs(client)).Methods("GET")
+
+		// @Summary Выгрузка логов запросов
+		// @Description Выгружает логи запросов, подходящие под фильтры, в xlsx, CSV или NDJSON (параметр format или заголовок Accept).
+		// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+		// @Produce text/csv
+		// @Produce application/x-ndjson
+		// @Success 200 {file} file "Файл с логами запросов"
+		// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр"
+		// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+		// @Router /logs/export [get]
+		logs.HandleFunc("/export", handlers.ExportRequestLogs(client)).Methods("GET")
+
+		// Статистика по логам запросов; все маршруты принимают фильтры /logs
+
+		// @Summary Запросы по endpoint и интервалам времени
+		// @Produce json
+		// @Success 200 {array} models.EndpointBucketStat "Количество запросов"
+		// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+		// @Router /logs/stats/endpoints [get]
+		logs.HandleFunc("/stats/endpoints", handlers.RequestsByEndpointStats(client)).Methods("GET")
+
+		// @Summary Перцентили длительности запросов
+		// @Produce json
+		// @Success 200 {array} models.LatencyStat "p50/p95/p99 в миллисекундах"
+		// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+		// @Router /logs/stats/latency [get]
+		logs.HandleFunc("/stats/latency", handlers.LatencyStats(client)).Methods("GET")
+
+		// @Summary Ответы по классам статусов
+		// @Produce json
+		// @Success 200 {array} models.StatusClassStat "Классы ответов"
+		// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+		// @Router /logs/stats/status [get]
+		logs.HandleFunc("/stats/status", handlers.StatusClassStats(client)).Methods("GET")
+
+		// @Summary Топ пользователей и IP-адресов
+		// @Produce json
+		// @Success 200 {array} models.TopStat "Топ"
+		// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+		// @Router /logs/stats/top [get]
+		logs.HandleFunc("/stats/top", handlers.TopStats(client)).Methods("GET")
+
+		// @Summary Уникальные пользователи по дням
+		// @Produce json
+		// @Success 200 {array} models.DailyUsersStat "Пользователи по дням"
+		// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+		// @Router /logs/stats/unique-users [get]
+		logs.HandleFunc("/stats/unique-users", handlers.UniqueUsersStats(client)).Methods("GET")
+
+		// @Summary Создание фоновой выгрузки логов
+		// @Description Ставит в очередь выгрузку логов с фильтрами /logs и возвращает ID задания.
+		// @Produce json
+		// @Success 202 {object} handlers.ExportStatus "Задание поставлено в очередь"
+		// @Failure 400 {object} apperror.ErrorResponse "Некорректный фильтр или формат"
+		// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+		// @Router /exports [post]
+		exports.HandleFunc("", handlers.CreateExport(app.Exports)).Methods("POST")
+
+		// @Summary Состояние фоновой выгрузки логов
+		// @Description Возвращает статус и прогресс выгрузки, а для готовой — подписанную ссылку на скачивание.
+		// @Produce json
+		// @Success 200 {object} handlers.ExportStatus "Состояние выгрузки"
+		// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+		// @Failure 404 {object} apperror.ErrorResponse "Выгрузка не найдена"
+		// @Router /exports/{id} [get]
+		exports.HandleFunc("/{id}", handlers.GetExport(app.Exports)).Methods("GET")
+
+		// @Summary Скачивание фоновой выгрузки логов
+		// @Description Отдаёт файл по подписанной ссылке из состояния выгрузки; кроме подписи нужен JWT администратора или аудитора.
+		// @Success 200 {file} file "Файл выгрузки"
+		// @Failure 403 {object} apperror.ErrorResponse "Ссылка недействительна, устарела или недостаточно прав"
+		// @Router /exports/{id}/download [get]
+		exports.HandleFunc("/{id}/download", handlers.DownloadExport(app.Exports)).Methods("GET")
+	} else {
+		logStorageUnavailable := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apperror.Write(w, r, apperror.ErrLogStorageUnavailable)
+		})
+		logs.PathPrefix("").Handler(logStorageUnavailable)
+		exports.PathPrefix("").Handler(logStorageUnavailable)
+	}
 
 	// @Summary Регистрация пользователя
 	// @Description Регистрирует нового пользователя в системе.
@@ -85,9 +249,9 @@ func InitializeRoutes(db *sql.DB, client *mongo.Client, app *internal.App) *mux.
 	// @Produce json
 	// @Param user body models.User true "Пользователь"
 	// @Success 201 {string} string "Пользователь успешно зарегистрирован"
-	// @Failure 400 {string} string "Ошибка валидации"
+	// @Failure 400 {object} apperror.ErrorResponse "Ошибка валидации"
 	// @Router /register [post]
-	r.HandleFunc("/register", auth.RegisterUser(db)).Methods("POST")
+	r.HandleFunc("/register", auth.RegisterUser(app.Users, app.Mailer)).Methods("POST")
 
 	// @Summary Вход пользователя
 	// @Description Позволяет пользователю войти в систему.
@@ -95,16 +259,35 @@ func InitializeRoutes(db *sql.DB, client *mongo.Client, app *internal.App) *mux.
 	// @Produce json
 	// @Param user body models.User true "Пользователь"
 	// @Success 200 {string} string "Пользователь успешно вошел"
-	// @Failure 400 {string} string "Ошибка при входе"
+	// @Failure 400 {object} apperror.ErrorResponse "Ошибка при входе"
 	// @Router /login [post]
-	r.HandleFunc("/login", auth.LoginUser(db)).Methods("POST")
+	r.HandleFunc("/login", auth.LoginUser(app.Users, app.Notifier, app.Audit)).Methods("POST")
 
 	// @Summary Выход пользователя
 	// @Description Позволяет пользователю выйти из системы.
 	// @Success 200 {string} string "Пользователь успешно вышел"
-	// @Failure 400 {string} string "Ошибка при выходе"
+	// @Failure 401 {object} apperror.ErrorResponse "Недействительный или завершённый токен"
 	// @Router /logout [post]
-	r.HandleFunc("/logout", auth.LogoutHandler(db)).Methods("POST")
+	r.Handle("/logout", auth.JWTMiddleware(app.Users, auth.LogoutHandler(app.Users, app.Audit))).Methods("POST")
+
+	// @Summary Подтверждение завершения сеанса по ссылке из письма
+	// @Description Показывает страницу с формой, отправляющей токен из подписанной ссылки из письма о событии безопасности. Сеанс не завершается.
+	// @Produce html
+	// @Param token query string true "Токен из ссылки"
+	// @Success 200 {string} string "Страница подтверждения"
+	// @Failure 401 {object} apperror.ErrorResponse "Недействительная ссылка"
+	// @Router /sessions/revoke [get]
+	r.HandleFunc("/sessions/revoke", auth.ConfirmRevokeSessionHandler()).Methods("GET")
+
+	// @Summary Завершение сеанса по ссылке из письма
+	// @Description Завершает сеанс, указанный в токене из страницы подтверждения.
+	// @Accept x-www-form-urlencoded
+	// @Produce html
+	// @Param token formData string true "Токен из ссылки"
+	// @Success 200 {string} string "Сеанс завершён"
+	// @Failure 401 {object} apperror.ErrorResponse "Недействительная ссылка"
+	// @Router /sessions/revoke [post]
+	r.HandleFunc("/sessions/revoke", auth.RevokeSessionHandler(app.Users, app.Audit)).Methods("POST")
 
 	// @Summary Подтверждение электронной почты
 	// @Description Подтверждает электронную почту пользователя.
@@ -113,24 +296,25 @@ func InitializeRoutes(db *sql.DB, client *mongo.Client, app *internal.App) *mux.
 	// @Param email body string true "Электронная почта пользователя"
 	// @Param code body string true "Код подтверждения"
 	// @Success 200 {string} string "Электронная почта успешно подтверждена"
-	// @Failure 400 {string} string "Ошибка при подтверждении электронной почты"
+	// @Failure 400 {object} apperror.ErrorResponse "Ошибка при подтверждении электронной почты"
+	// @Failure 409 {object} apperror.ErrorResponse "Email или телефон уже заняты"
 	// @Router /confirm-email [post]
-	r.HandleFunc("/confirm-email", auth.ConfirmEmailHandler(db)).Methods("POST")
+	r.HandleFunc("/confirm-email", auth.ConfirmEmailHandler(app.Transactor, app.Audit)).Methods("POST")
 
 	// @Summary Повторная отправка письма с подтверждением
 	// @Description Позволяет повторно отправить письмо с подтверждением на электронную почту.
 	// @Param email body string true "Электронная почта пользователя"
 	// @Success 200 {string} string "Письмо с подтверждением успешно отправлено"
-	// @Failure 400 {string} string "Ошибка при повторной отправке"
+	// @Failure 400 {object} apperror.ErrorResponse "Ошибка при повторной отправке"
 	// @Router /resend-confirmation [post]
-	r.HandleFunc("/resend-confirmation", auth.ResendConfirmationEmailHandler()).Methods("POST")
+	r.HandleFunc("/resend-confirmation", auth.ResendConfirmationEmailHandler(app.Mailer)).Methods("POST")
 
 	// @Summary Обновление access токена
 	// @Description Позволяет обновить access токен с использованием refresh токена.
 	// @Accept json
 	// @Produce json
 	// @Success 200 {string} string "Токен успешно обновлен"
-	// @Failure 401 {string} string "Недействительный токен"
+	// @Failure 401 {object} apperror.ErrorResponse "Недействительный токен"
 	// @Router /refresh [post]
 	r.HandleFunc("/refresh-token", auth.RefreshTokenHandler).Methods("POST")
 
@@ -138,9 +322,120 @@ func InitializeRoutes(db *sql.DB, client *mongo.Client, app *internal.App) *mux.
 	// @Description Позволяет доступ к защищенному ресурсу только с валидным JWT.
 	// @Produce json
 	// @Success 200 {string} string "Доступ разрешен"
-	// @Failure 401 {string} string "Недействительный токен"
+	// @Failure 401 {object} apperror.ErrorResponse "Недействительный токен"
 	// @Router /protected [get]
-	r.Handle("/protected", auth.JWTMiddleware(db, http.HandlerFunc(ProtectedHandler))).Methods("GET")
+	r.Handle("/protected", auth.JWTMiddleware(app.Users, http.HandlerFunc(ProtectedHandler))).Methods("GET")
+
+	// Административные маршруты: требуется JWT и роль администратора
+	admin := r.PathPrefix("/admin").Subrouter()
+	admin.Use(func(next http.Handler) http.Handler { return auth.JWTMiddleware(app.Users, next) })
+	admin.Use(auth.RequireRole(app.Users, models.RoleAdmin))
+
+	// @Summary Список писем в очереди исходящей почты
+	// @Description Возвращает письма из очереди с фильтром по статусу.
+	// @Produce json
+	// @Success 200 {array} models.OutboxMessage "Письма"
+	// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+	// @Router /admin/emails [get]
+	admin.HandleFunc("/emails", handlers.ListOutboxMessages(db)).Methods("GET")
+
+	// @Summary Повторная отправка письма
+	// @Description Возвращает неотправленное письмо в очередь.
+	// @Success 204 {string} string "Письмо возвращено в очередь"
+	// @Failure 404 {object} apperror.ErrorResponse "Письмо не найдено"
+	// @Router /admin/emails/{id}/retry [post]
+	admin.HandleFunc("/emails/{id}/retry", handlers.RetryOutboxMessage(db)).Methods("POST")
+
+	// @Summary Список подавления
+	// @Description Возвращает адреса, на которые не отправляются письма.
+	// @Produce json
+	// @Success 200 {array} models.Suppression "Записи списка подавления"
+	// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+	// @Router /admin/suppressions [get]
+	admin.HandleFunc("/suppressions", handlers.ListSuppressions(db)).Methods("GET")
+
+	// @Summary Удаление адреса из списка подавления
+	// @Description Письма на адрес снова будут отправляться.
+	// @Success 204 {string} string "Адрес удалён из списка"
+	// @Failure 404 {object} apperror.ErrorResponse "Адреса нет в списке"
+	// @Router /admin/suppressions/{email} [delete]
+	admin.HandleFunc("/suppressions/{email}", handlers.DeleteSuppression(db)).Methods("DELETE")
+
+	// @Summary Уровень логирования
+	// @Produce json
+	// @Success 200 {object} handlers.LogLevel "Текущий уровень"
+	// @Router /admin/log-level [get]
+	admin.HandleFunc("/log-level", handlers.GetLogLevel()).Methods("GET")
+
+	// @Summary Изменение уровня логирования
+	// @Description Меняет уровень логирования до перезапуска приложения.
+	// @Accept json
+	// @Produce json
+	// @Success 200 {object} handlers.LogLevel "Установленный уровень"
+	// @Failure 400 {object} apperror.ErrorResponse "Неизвестный уровень"
+	// @Router /admin/log-level [put]
+	admin.HandleFunc("/log-level", handlers.SetLogLevel()).Methods("PUT")
+
+	// @Summary Хранение логов
+	// @Description Возвращает сроки хранения логов, размеры коллекций и результаты архивации.
+	// @Produce json
+	// @Success 200 {object} models.RetentionStatus "Состояние хранения логов"
+	// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+	// @Router /admin/logs/retention [get]
+	if app.Retention != nil {
+		admin.HandleFunc("/logs/retention", handlers.GetLogRetention(app.Retention)).Methods("GET")
+	}
+
+	// @Summary Изменение роли пользователя
+	// @Description Назначает роль user, admin или auditor. Изменение записывается в журнал аудита.
+	// @Accept json
+	// @Success 204 "Роль изменена"
+	// @Failure 400 {object} apperror.ErrorResponse "Неизвестная роль"
+	// @Failure 404 {object} apperror.ErrorResponse "Пользователь не найден"
+	// @Router /admin/users/{id}/role [put]
+	admin.HandleFunc("/users/{id}/role", handlers.SetUserRole(app.Users, app.Transactor, app.Audit)).Methods("PUT")
+
+	// Журнал аудита: требуется JWT и роль аудитора
+	audit := r.PathPrefix("/audit").Subrouter()
+	audit.Use(func(next http.Handler) http.Handler { return auth.JWTMiddleware(app.Users, next) })
+	audit.Use(auth.RequireRole(app.Users, models.RoleAuditor))
+
+	// @Summary Журнал аудита
+	// @Description Возвращает действия с пользователями и события входа от новых к старым.
+	// @Produce json
+	// @Success 200 {object} handlers.AuditPage "Страница журнала"
+	// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+	// @Router /audit [get]
+	audit.HandleFunc("", handlers.ListAuditEntries(db)).Methods("GET")
+
+	// @Summary Проверка целостности журнала аудита
+	// @Description Пересчитывает цепочку хешей и сообщает о первой повреждённой записи.
+	// @Produce json
+	// @Success 200 {object} models.AuditVerification "Результат проверки"
+	// @Failure 403 {object} apperror.ErrorResponse "Недостаточно прав"
+	// @Router /audit/verify [get]
+	audit.HandleFunc("/verify", handlers.VerifyAuditLog(app.Audit)).Methods("GET")
+
+	// @Summary Вебхук событий доставки писем
+	// @Description Принимает от почтового провайдера события об отказах доставки и жалобах.
+	// @Accept json
+	// @Produce json
+	// @Success 200 {object} handlers.EmailEventsResponse "Результат обработки"
+	// @Failure 401 {object} apperror.ErrorResponse "Неверный секрет"
+	// @Router /webhooks/email [post]
+	r.HandleFunc("/webhooks/email", handlers.EmailEventsWebhook(db)).Methods("POST")
+
+	// Метрики раскрывают устройство базы данных, поэтому включаются только вместе с токеном METRICS_TOKEN
+
+	// @Summary Метрики приложения
+	// @Description Статистика пулов соединений с базой данных в формате Prometheus. Доступна, только если задан METRICS_TOKEN.
+	// @Produce plain
+	// @Success 200 {string} string "Метрики"
+	// @Failure 401 {object} apperror.ErrorResponse "Неверный токен"
+	// @Router /metrics [get]
+	if token := os.Getenv("METRICS_TOKEN"); token != "" {
+		r.HandleFunc("/metrics", handlers.Metrics(app.DBPools, token)).Methods("GET")
+	}
 
 	r.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 
@@ -149,5 +444,5 @@ func InitializeRoutes(db *sql.DB, client *mongo.Client, app *internal.App) *mux.
 
 func ProtectedHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK) // TODO Временная функция для проверки логики работы защищенного маршрута
-	w.Write([]byte("Доступ разрешен!"))
+	w.Write([]byte(i18n.T(i18n.FromContext(r.Context()), "protected.access_granted")))
 }
@@ -0,0 +1,12 @@
+package utils
+
+import "os"
+
+// BaseURL возвращает внешний адрес приложения для ссылок в письмах и ответах API (APP_BASE_URL).
+// Если переменная не задана, используется локальный адрес на порту SERVER_PORT.
+func BaseURL() string {
+	if baseURL := os.Getenv("APP_BASE_URL"); baseURL != "" {
+		return baseURL
+	}
+	return "http://localhost:" + os.Getenv("SERVER_PORT")
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// trustedProxies — сети прокси, заголовкам X-Forwarded-For и X-Real-IP которых можно доверять
+var trustedProxies []netip.Prefix
+
+// SetTrustedProxies задаёт доверенные прокси списком IP-адресов и подсетей через запятую,
+// например "10.0.0.0/8, 127.0.0.1". Пустая строка — заголовки прокси не учитываются.
+// Вызывается при запуске до начала обработки запросов.
+func SetTrustedProxies(list string) error {
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if strings.Contains(item, "/") {
+			prefix, err := netip.ParsePrefix(item)
+			if err != nil {
+				return fmt.Errorf("некорректная подсеть доверенного прокси %q: %w", item, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(item)
+		if err != nil {
+			return fmt.Errorf("некорректный адрес доверенного прокси %q: %w", item, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+
+	trustedProxies = prefixes
+	return nil
+}
+
+// isTrustedProxy проверяет, входит ли адрес в доверенные сети
+func isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP возвращает IP-адрес клиента без номера порта.
+// Если запрос пришёл от доверенного прокси, адрес берётся из X-Forwarded-For (первый справа недоверенный адрес)
+// или X-Real-IP. Заголовки от остальных клиентов игнорируются, иначе их можно подделать.
+func ClientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+
+	// Прокси дописывают адреса справа, поэтому идём от ближайшего к нам
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if _, err := netip.ParseAddr(hops[i]); err != nil {
+			// Мусор в заголовке: дальше по цепочке доверять нельзя
+			return remote
+		}
+		if !isTrustedProxy(hops[i]) || i == 0 {
+			return hops[i]
+		}
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		if _, err := netip.ParseAddr(realIP); err == nil {
+			return realIP
+		}
+	}
+	return remote
+}
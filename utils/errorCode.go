@@ -0,0 +1,20 @@
+package utils
+
+import "context"
+
+// errorCodeKey — ключ контекста для кода ошибки ответа
+type errorCodeKey struct{}
+
+// WithErrorCodeSlot добавляет в контекст место для кода ошибки ответа.
+// Мидлвар логирования читает код после обработки запроса, а apperror.Write заполняет его через SetErrorCode.
+func WithErrorCodeSlot(ctx context.Context) (context.Context, *string) {
+	slot := new(string)
+	return context.WithValue(ctx, errorCodeKey{}, slot), slot
+}
+
+// SetErrorCode сохраняет код ошибки ответа, если в контексте есть место для него
+func SetErrorCode(ctx context.Context, code string) {
+	if slot, ok := ctx.Value(errorCodeKey{}).(*string); ok {
+		*slot = code
+	}
+}
@@ -0,0 +1,210 @@
+package utils
+
+import (
+	"Cloud/i18n"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+)
+
+// Типы тела PATCH-запроса
+const (
+	MergePatchContentType = "application/merge-patch+json" // RFC 7386
+	JSONPatchContentType  = "application/json-patch+json"  // RFC 6902
+)
+
+// ErrPatchTestFailed — операция test JSON Patch не совпала с документом; патч не применяется целиком
+var ErrPatchTestFailed = errors.New("операция test не выполнена")
+
+// PatchOperation — операция JSON Patch (RFC 6902)
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"` // nil — значение не передано, "null" — передан null
+}
+
+// ApplyMergePatch применяет JSON Merge Patch (RFC 7386) к документу doc и возвращает новый документ; doc не меняется.
+// patch — разобранное тело запроса; null в нём удаляет поле.
+func ApplyMergePatch(doc map[string]any, patch any) (map[string]any, error) {
+	if _, ok := patch.(map[string]any); !ok {
+		return nil, i18n.NewError("validation.merge_patch_invalid")
+	}
+	return mergePatch(doc, patch).(map[string]any), nil
+}
+
+// mergePatch — алгоритм MergePatch(Target, Patch) из RFC 7386
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if ok {
+		t = maps.Clone(t)
+	} else {
+		t = map[string]any{}
+	}
+	for name, value := range p {
+		if value == nil {
+			delete(t, name)
+			continue
+		}
+		t[name] = mergePatch(t[name], value)
+	}
+	return t
+}
+
+// ApplyJSONPatch применяет операции JSON Patch (RFC 6902) к документу doc и возвращает новый документ; doc не меняется.
+// Патч применяется целиком или не применяется: ошибка в любой операции отменяет все.
+// Поддерживаются документы из вложенных объектов; пути внутрь массивов не поддерживаются.
+func ApplyJSONPatch(doc map[string]any, ops []PatchOperation) (map[string]any, error) {
+	result := deepCopyJSON(doc).(map[string]any)
+	for i, op := range ops {
+		if err := applyOperation(result, op); err != nil {
+			if errors.Is(err, ErrPatchTestFailed) {
+				return nil, fmt.Errorf("операция #%d: %w", i+1, err)
+			}
+			return nil, fmt.Errorf("%w: %v", i18n.NewError("validation.json_patch_invalid", i+1), err)
+		}
+	}
+	return result, nil
+}
+
+// applyOperation применяет одну операцию JSON Patch к doc на месте
+func applyOperation(doc map[string]any, op PatchOperation) error {
+	switch op.Op {
+	case "add":
+		value, err := operationValue(op)
+		if err != nil {
+			return err
+		}
+		parent, key, err := pointerParent(doc, op.Path)
+		if err != nil {
+			return err
+		}
+		parent[key] = value
+
+	case "remove":
+		parent, key, err := pointerParent(doc, op.Path)
+		if err != nil {
+			return err
+		}
+		if _, ok := parent[key]; !ok {
+			return fmt.Errorf("путь %s не существует", op.Path)
+		}
+		delete(parent, key)
+
+	case "replace":
+		value, err := operationValue(op)
+		if err != nil {
+			return err
+		}
+		parent, key, err := pointerParent(doc, op.Path)
+		if err != nil {
+			return err
+		}
+		if _, ok := parent[key]; !ok {
+			return fmt.Errorf("путь %s не существует", op.Path)
+		}
+		parent[key] = value
+
+	case "move", "copy":
+		fromParent, fromKey, err := pointerParent(doc, op.From)
+		if err != nil {
+			return err
+		}
+		value, ok := fromParent[fromKey]
+		if !ok {
+			return fmt.Errorf("путь %s не существует", op.From)
+		}
+		if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+			return fmt.Errorf("нельзя переместить %s внутрь самого себя", op.From)
+		}
+		parent, key, err := pointerParent(doc, op.Path)
+		if err != nil {
+			return err
+		}
+		if op.Op == "move" {
+			delete(fromParent, fromKey)
+		} else {
+			value = deepCopyJSON(value)
+		}
+		parent[key] = value
+
+	case "test":
+		expected, err := operationValue(op)
+		if err != nil {
+			return err
+		}
+		parent, key, err := pointerParent(doc, op.Path)
+		if err != nil {
+			return err
+		}
+		actual, ok := parent[key]
+		if !ok || !reflect.DeepEqual(actual, expected) {
+			return fmt.Errorf("%w: %s", ErrPatchTestFailed, op.Path)
+		}
+
+	default:
+		return fmt.Errorf("неизвестная операция %q", op.Op)
+	}
+	return nil
+}
+
+// operationValue разбирает поле value операции; его отсутствие — ошибка, null — допустимое значение
+func operationValue(op PatchOperation) (any, error) {
+	if op.Value == nil {
+		return nil, fmt.Errorf("у операции %s нет value", op.Op)
+	}
+	var value any
+	if err := json.Unmarshal(op.Value, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// pointerParent разбирает JSON Pointer (RFC 6901) и возвращает объект, в котором находится последний сегмент пути, и сам сегмент
+func pointerParent(doc map[string]any, pointer string) (map[string]any, string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, "", fmt.Errorf("некорректный путь %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	parent := doc
+	for _, token := range tokens[:len(tokens)-1] {
+		child, ok := parent[token].(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("путь %s не существует", pointer)
+		}
+		parent = child
+	}
+	return parent, tokens[len(tokens)-1], nil
+}
+
+// deepCopyJSON копирует значение, разобранное из JSON, вместе с вложенными объектами и массивами
+func deepCopyJSON(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		copied := make(map[string]any, len(v))
+		for key, item := range v {
+			copied[key] = deepCopyJSON(item)
+		}
+		return copied
+	case []any:
+		copied := make([]any, len(v))
+		for i, item := range v {
+			copied[i] = deepCopyJSON(item)
+		}
+		return copied
+	}
+	return value
+}
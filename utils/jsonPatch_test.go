@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// parseJSON разбирает документ или патч так же, как тело запроса
+func parseJSON[T any](t *testing.T, s string) T {
+	t.Helper()
+	var v T
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	const doc = `{"name":"alice","n":1,"profile":{"city":"Moscow","tags":["a"]},"a/b":1,"m~n":2,"~1":3}`
+
+	cases := []struct {
+		name     string
+		ops      string
+		want     string // пустая строка — ожидается ошибка
+		wantTest bool   // ошибка — невыполненная операция test
+	}{
+		{"replace и add", `[{"op":"replace","path":"/name","value":"bob"},{"op":"add","path":"/profile/zip","value":"101000"}]`,
+			`{"name":"bob","n":1,"profile":{"city":"Moscow","tags":["a"],"zip":"101000"},"a/b":1,"m~n":2,"~1":3}`, false},
+		{"test с числом", `[{"op":"test","path":"/n","value":1.0},{"op":"replace","path":"/n","value":2}]`,
+			`{"name":"alice","n":2,"profile":{"city":"Moscow","tags":["a"]},"a/b":1,"m~n":2,"~1":3}`, false},
+		{"test числа со строкой", `[{"op":"test","path":"/n","value":"1"}]`, "", true},
+		{"test отсутствующего поля", `[{"op":"test","path":"/missing","value":null}]`, "", true},
+		{"экранирование ~0 и ~1", `[{"op":"replace","path":"/a~1b","value":10},{"op":"remove","path":"/m~0n"},{"op":"replace","path":"/~01","value":30}]`,
+			`{"name":"alice","n":1,"profile":{"city":"Moscow","tags":["a"]},"a/b":10,"~1":30}`, false},
+		{"move", `[{"op":"move","from":"/profile/city","path":"/city"}]`,
+			`{"name":"alice","n":1,"city":"Moscow","profile":{"tags":["a"]},"a/b":1,"m~n":2,"~1":3}`, false},
+		{"move внутрь самого себя", `[{"op":"move","from":"/profile","path":"/profile/inner"}]`, "", false},
+		{"copy не связывает копии", `[{"op":"copy","from":"/profile","path":"/copy"},{"op":"replace","path":"/copy/city","value":"Kazan"}]`,
+			`{"name":"alice","n":1,"profile":{"city":"Moscow","tags":["a"]},"copy":{"city":"Kazan","tags":["a"]},"a/b":1,"m~n":2,"~1":3}`, false},
+		// Ошибка в последней операции отменяет и предыдущие
+		{"откат при ошибке", `[{"op":"replace","path":"/name","value":"bob"},{"op":"remove","path":"/profile/city"},{"op":"remove","path":"/missing"}]`, "", false},
+		{"откат при невыполненном test", `[{"op":"replace","path":"/name","value":"bob"},{"op":"test","path":"/name","value":"alice"}]`, "", true},
+		{"replace отсутствующего поля", `[{"op":"replace","path":"/missing","value":1}]`, "", false},
+		{"операция без value", `[{"op":"add","path":"/x"}]`, "", false},
+		{"неизвестная операция", `[{"op":"swap","path":"/name"}]`, "", false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := parseJSON[map[string]any](t, doc)
+			input := parseJSON[map[string]any](t, doc)
+
+			got, err := ApplyJSONPatch(input, parseJSON[[]PatchOperation](t, tc.ops))
+			if tc.want == "" {
+				if err == nil {
+					t.Fatalf("патч применён: %v", got)
+				}
+				if errors.Is(err, ErrPatchTestFailed) != tc.wantTest {
+					t.Errorf("ошибка %v, ErrPatchTestFailed ожидалась: %v", err, tc.wantTest)
+				}
+			} else {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := parseJSON[map[string]any](t, tc.want); !reflect.DeepEqual(got, want) {
+					t.Errorf("результат %v, ожидался %v", got, want)
+				}
+			}
+
+			// Исходный документ не меняется ни при успехе, ни при ошибке
+			if !reflect.DeepEqual(input, original) {
+				t.Errorf("исходный документ изменён: %v", input)
+			}
+		})
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	const doc = `{"name":"alice","phone":"+79990000001","profile":{"city":"Moscow","zip":"101000"}}`
+
+	cases := []struct {
+		name  string
+		patch string
+		want  string // пустая строка — ожидается ошибка
+	}{
+		{"замена поля", `{"name":"bob"}`, `{"name":"bob","phone":"+79990000001","profile":{"city":"Moscow","zip":"101000"}}`},
+		{"null удаляет поле", `{"phone":null}`, `{"name":"alice","profile":{"city":"Moscow","zip":"101000"}}`},
+		{"null во вложенном объекте", `{"profile":{"zip":null,"street":"Tverskaya"}}`, `{"name":"alice","phone":"+79990000001","profile":{"city":"Moscow","street":"Tverskaya"}}`},
+		{"null отсутствующего поля", `{"missing":null}`, `{"name":"alice","phone":"+79990000001","profile":{"city":"Moscow","zip":"101000"}}`},
+		{"патч не объект", `["name"]`, ""},
+		{"патч null", `null`, ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			original := parseJSON[map[string]any](t, doc)
+			input := parseJSON[map[string]any](t, doc)
+
+			got, err := ApplyMergePatch(input, parseJSON[any](t, tc.patch))
+			if tc.want == "" {
+				if err == nil {
+					t.Fatalf("патч применён: %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := parseJSON[map[string]any](t, tc.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("результат %v, ожидался %v", got, want)
+			}
+			if !reflect.DeepEqual(input, original) {
+				t.Errorf("исходный документ изменён: %v", input)
+			}
+		})
+	}
+}
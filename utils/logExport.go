@@ -0,0 +1,363 @@
+package utils
+
+import (
+	"Cloud/models"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/xuri/excelize/v2"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы выгрузки логов запросов
+const (
+	ExportXLSX   = "xlsx"
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+// exportContentTypes — MIME-типы форматов выгрузки
+var exportContentTypes = map[string]string{
+	ExportXLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	ExportCSV:    "text/csv; charset=utf-8",
+	ExportNDJSON: "application/x-ndjson",
+}
+
+// ErrUnsupportedFormat — клиент запросил формат выгрузки, которого нет
+var ErrUnsupportedFormat = errors.New("неподдерживаемый формат выгрузки")
+
+// ExportContentType возвращает MIME-тип формата выгрузки
+func ExportContentType(format string) string {
+	return exportContentTypes[format]
+}
+
+// NegotiateExportFormat выбирает формат выгрузки: параметр format важнее заголовка Accept.
+// Без явных предпочтений используется xlsx.
+func NegotiateExportFormat(formatParam, accept string) (string, error) {
+	if formatParam != "" {
+		format := strings.ToLower(formatParam)
+		if _, ok := exportContentTypes[format]; !ok {
+			return "", ErrUnsupportedFormat
+		}
+		return format, nil
+	}
+	if accept == "" {
+		return ExportXLSX, nil
+	}
+
+	type candidate struct {
+		format string
+		q      float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		switch mediaType {
+		case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", "*/*", "application/*":
+			candidates = append(candidates, candidate{ExportXLSX, q})
+		case "text/csv", "text/*":
+			candidates = append(candidates, candidate{ExportCSV, q})
+		case "application/x-ndjson", "application/ndjson", "application/jsonl":
+			candidates = append(candidates, candidate{ExportNDJSON, q})
+		}
+	}
+
+	// Стабильная сортировка сохраняет порядок клиента при равных весах
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	if len(candidates) == 0 {
+		return "", ErrUnsupportedFormat
+	}
+	return candidates[0].format, nil
+}
+
+// LogExporter пишет логи запросов в выбранном формате по одной записи, не накапливая их в памяти
+type LogExporter interface {
+	// Write добавляет запись в выгрузку
+	Write(log *models.RequestLog) error
+	// Close дописывает выгрузку; после Close писать нельзя
+	Close() error
+	// Abort освобождает ресурсы выгрузки, которую не удалось завершить
+	Abort()
+}
+
+// NewLogExporter создаёт LogExporter для формата format, пишущий в w
+func NewLogExporter(format string, w io.Writer) (LogExporter, error) {
+	switch format {
+	case ExportXLSX:
+		return newXLSXExporter(w)
+	case ExportCSV:
+		return newCSVExporter(w)
+	case ExportNDJSON:
+		return &ndjsonExporter{encoder: json.NewEncoder(w)}, nil
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// SummaryExporter — выгрузка, в которую можно добавить сводные листы; поддерживается только xlsx
+type SummaryExporter interface {
+	// AddSummary добавляет сводку; вызывается после записи всех логов и до Close
+	AddSummary(summary *models.LogSummary) error
+}
+
+// logExportHeaders — заголовки столбцов табличных форматов
+var logExportHeaders = []string{"RequestID", "Method", "Endpoint", "Route", "Query", "UserID", "IP", "UserAgent", "Time", "StatusCode", "ErrorCode", "BytesIn", "BytesOut", "DurationMs"}
+
+// logExportRow возвращает значения столбцов для записи
+func logExportRow(log *models.RequestLog) []any {
+	return []any{log.RequestID, log.Method, log.Endpoint, log.Route, log.Query, log.UserID, log.IP, log.UserAgent, log.Time.UTC().Format(time.RFC3339),
+		log.StatusCode, log.ErrorCode, log.BytesIn, log.BytesOut, log.DurationMs}
+}
+
+// xlsxMaxRows — ограничение формата xlsx на количество строк листа
+const xlsxMaxRows = 1048576
+
+// xlsxExporter пишет xlsx через StreamWriter: строки сбрасываются во временный файл, а не держатся в памяти.
+// Когда лист заполнен, выгрузка продолжается на следующем листе.
+type xlsxExporter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	sheet  int
+	row    int
+}
+
+func newXLSXExporter(w io.Writer) (*xlsxExporter, error) {
+	e := &xlsxExporter{out: w, file: excelize.NewFile()}
+	if err := e.nextSheet(); err != nil {
+		e.file.Close()
+		return nil, err
+	}
+	return e, nil
+}
+
+// nextSheet завершает текущий лист и начинает новый с заголовками
+func (e *xlsxExporter) nextSheet() error {
+	if e.stream != nil {
+		if err := e.stream.Flush(); err != nil {
+			return err
+		}
+	}
+
+	e.sheet++
+	name := "Sheet" + strconv.Itoa(e.sheet)
+	if e.sheet > 1 {
+		if _, err := e.file.NewSheet(name); err != nil {
+			return err
+		}
+	}
+
+	stream, err := e.file.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	e.stream = stream
+
+	headers := make([]any, len(logExportHeaders))
+	for i, h := range logExportHeaders {
+		headers[i] = h
+	}
+	if err := e.stream.SetRow("A1", headers); err != nil {
+		return fmt.Errorf("ошибка при установке заголовка: %w", err)
+	}
+	e.row = 1
+	return nil
+}
+
+func (e *xlsxExporter) Write(log *models.RequestLog) error {
+	if e.row == xlsxMaxRows {
+		if err := e.nextSheet(); err != nil {
+			return err
+		}
+	}
+
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	return e.stream.SetRow(cell, logExportRow(log))
+}
+
+func (e *xlsxExporter) Close() error {
+	defer e.file.Close()
+
+	if e.stream != nil {
+		if err := e.stream.Flush(); err != nil {
+			return err
+		}
+	}
+	return e.file.Write(e.out)
+}
+
+// AddSummary добавляет после листов с логами сводные листы и диаграммы
+func (e *xlsxExporter) AddSummary(summary *models.LogSummary) error {
+	if err := e.stream.Flush(); err != nil {
+		return err
+	}
+	e.stream = nil
+
+	byEndpoint := make([][]any, 0, len(summary.ByEndpoint))
+	for _, s := range summary.ByEndpoint {
+		byEndpoint = append(byEndpoint, []any{s.Bucket.UTC().Format("2006-01-02"), s.Endpoint, s.Requests})
+	}
+	latency := make([][]any, 0, len(summary.Latency))
+	for _, s := range summary.Latency {
+		latency = append(latency, []any{s.Endpoint, s.Requests, s.AvgMs, s.P50Ms, s.P95Ms, s.P99Ms, s.MaxMs})
+	}
+	statusClasses := make([][]any, 0, len(summary.StatusClasses))
+	for _, s := range summary.StatusClasses {
+		statusClasses = append(statusClasses, []any{s.Class, s.Requests, s.Rate})
+	}
+	topUsers := make([][]any, 0, len(summary.TopUsers))
+	for _, s := range summary.TopUsers {
+		topUsers = append(topUsers, []any{s.Value, s.Requests})
+	}
+	topIPs := make([][]any, 0, len(summary.TopIPs))
+	for _, s := range summary.TopIPs {
+		topIPs = append(topIPs, []any{s.Value, s.Requests})
+	}
+	uniqueUsers := make([][]any, 0, len(summary.UniqueUsers))
+	for _, s := range summary.UniqueUsers {
+		uniqueUsers = append(uniqueUsers, []any{s.Day.UTC().Format("2006-01-02"), s.Users})
+	}
+
+	sheets := []struct {
+		name    string
+		headers []any
+		rows    [][]any
+		chart   *excelize.ChartType // nil — лист без диаграммы
+	}{
+		{"Requests by endpoint", []any{"Day", "Endpoint", "Requests"}, byEndpoint, nil},
+		{"Latency", []any{"Endpoint", "Requests", "AvgMs", "P50Ms", "P95Ms", "P99Ms", "MaxMs"}, latency, nil},
+		{"Status classes", []any{"Class", "Requests", "Rate"}, statusClasses, chartOf(excelize.Pie)},
+		{"Top users", []any{"UserID", "Requests"}, topUsers, chartOf(excelize.Bar)},
+		{"Top IPs", []any{"IP", "Requests"}, topIPs, chartOf(excelize.Bar)},
+		{"Unique users", []any{"Day", "Users"}, uniqueUsers, chartOf(excelize.Line)},
+	}
+
+	for _, sheet := range sheets {
+		if err := e.writeSummarySheet(sheet.name, sheet.headers, sheet.rows); err != nil {
+			return fmt.Errorf("ошибка записи листа %q: %w", sheet.name, err)
+		}
+		if sheet.chart != nil && len(sheet.rows) > 0 {
+			if err := e.addChart(sheet.name, *sheet.chart, len(sheet.rows)); err != nil {
+				return fmt.Errorf("ошибка построения диаграммы на листе %q: %w", sheet.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func chartOf(t excelize.ChartType) *excelize.ChartType {
+	return &t
+}
+
+// writeSummarySheet записывает таблицу на новый лист
+func (e *xlsxExporter) writeSummarySheet(name string, headers []any, rows [][]any) error {
+	if _, err := e.file.NewSheet(name); err != nil {
+		return err
+	}
+	stream, err := e.file.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+
+	if err := stream.SetRow("A1", headers); err != nil {
+		return err
+	}
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return err
+		}
+		if err := stream.SetRow(cell, row); err != nil {
+			return err
+		}
+	}
+	return stream.Flush()
+}
+
+// addChart строит диаграмму по первым двум столбцам листа: подписи в A, значения в B
+func (e *xlsxExporter) addChart(sheet string, chartType excelize.ChartType, rows int) error {
+	ref := "'" + sheet + "'!"
+	return e.file.AddChart(sheet, "E2", &excelize.Chart{
+		Type: chartType,
+		Series: []excelize.ChartSeries{{
+			Name:       ref + "$B$1",
+			Categories: fmt.Sprintf("%s$A$2:$A$%d", ref, rows+1),
+			Values:     fmt.Sprintf("%s$B$2:$B$%d", ref, rows+1),
+		}},
+		Title: []excelize.RichTextRun{{Text: sheet}},
+	})
+}
+
+// Abort удаляет временные файлы StreamWriter, ничего не записывая в out
+func (e *xlsxExporter) Abort() {
+	e.file.Close()
+}
+
+// csvExporter пишет CSV с заголовком в первой строке
+type csvExporter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVExporter(w io.Writer) (*csvExporter, error) {
+	e := &csvExporter{writer: csv.NewWriter(w), record: make([]string, len(logExportHeaders))}
+	if err := e.writer.Write(logExportHeaders); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (e *csvExporter) Write(log *models.RequestLog) error {
+	for i, value := range logExportRow(log) {
+		e.record[i] = fmt.Sprint(value)
+	}
+	return e.writer.Write(e.record)
+}
+
+func (e *csvExporter) Close() error {
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvExporter) Abort() {}
+
+// ndjsonExporter пишет по одному JSON-объекту на строку
+type ndjsonExporter struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonExporter) Write(log *models.RequestLog) error {
+	return e.encoder.Encode(log)
+}
+
+func (e *ndjsonExporter) Close() error {
+	return nil
+}
+
+func (e *ndjsonExporter) Abort() {}
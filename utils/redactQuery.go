@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+)
+
+// RedactedValue подставляется вместо значений секретных параметров
+const RedactedValue = "REDACTED"
+
+// sensitiveQueryParams — параметры, значения которых не должны попадать в логи
+var sensitiveQueryParams = []string{"token", "password", "secret", "code", "sig", "signature", "key", "auth"}
+
+// isSensitiveQueryParam проверяет имя параметра без учёта регистра; access_token, api_key и т.п. тоже считаются секретными
+func isSensitiveQueryParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, sensitive := range sensitiveQueryParams {
+		if name == sensitive || strings.HasPrefix(name, sensitive+"_") || strings.HasSuffix(name, "_"+sensitive) {
+			return true
+		}
+	}
+	return strings.Contains(name, "token") || strings.Contains(name, "password") || strings.Contains(name, "secret")
+}
+
+// RedactQuery возвращает строку параметров запроса, в которой значения секретов заменены на REDACTED
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	// Некорректные пары отбрасываются, остальные параметры сохраняются
+	values, _ := url.ParseQuery(rawQuery)
+	for name, vals := range values {
+		if isSensitiveQueryParam(name) {
+			for i := range vals {
+				vals[i] = RedactedValue
+			}
+		}
+	}
+	return values.Encode()
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+)
+
+// requestIDKey — ключ контекста для идентификатора запроса
+type requestIDKey struct{}
+
+// RequestIDHeader — заголовок, в котором передаётся идентификатор запроса
+const RequestIDHeader = "X-Request-ID"
+
+// Допустимый формат идентификатора, пришедшего от клиента или прокси
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// GenRequestID генерирует случайный идентификатор запроса
+func GenRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// IsValidRequestID проверяет идентификатор запроса, полученный из заголовка
+func IsValidRequestID(id string) bool {
+	return requestIDPattern.MatchString(id)
+}
+
+// WithRequestID сохраняет идентификатор запроса в контексте
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext возвращает идентификатор запроса из контекста
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,32 @@
+package utils
+
+import "context"
+
+// userIDKey — ключ контекста для ID аутентифицированного пользователя
+type userIDKey struct{}
+
+// userIDSlotKey — ключ контекста для места, куда записывается ID проверенного пользователя
+type userIDSlotKey struct{}
+
+// WithUserID сохраняет ID аутентифицированного пользователя в контексте
+// и заполняет место из WithUserIDSlot, если оно есть
+func WithUserID(ctx context.Context, userID int) context.Context {
+	if slot, ok := ctx.Value(userIDSlotKey{}).(*int); ok {
+		*slot = userID
+	}
+	return context.WithValue(ctx, userIDKey{}, userID)
+}
+
+// UserIDFromContext возвращает ID аутентифицированного пользователя; ok = false для анонимного запроса
+func UserIDFromContext(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDKey{}).(int)
+	return userID, ok
+}
+
+// WithUserIDSlot добавляет в контекст место для ID пользователя, проверенного JWTMiddleware.
+// Контекст с самим ID виден только обработчикам ниже по цепочке, а мидлвар логирования читает его из места
+// после обработки запроса. Для анонимного запроса место остаётся нулевым.
+func WithUserIDSlot(ctx context.Context) (context.Context, *int) {
+	slot := new(int)
+	return context.WithValue(ctx, userIDSlotKey{}, slot), slot
+}
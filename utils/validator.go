@@ -1,37 +1,41 @@
 package utils
 
 import (
+	"Cloud/i18n"
 	"Cloud/models"
-	"errors"
 	"regexp"
 )
 
 // ValidateUserForCreate проверяет корректность данных пользователя при создании.
 //
 // @Summary Валидация данных пользователя для создания
-// @Description Проверяет, что имя пользователя, номер телефона, пароль и email заполнены и соответствуют требованиям.
+// @Description Проверяет, что имя пользователя, номер телефона, пароль и email заполнены и соответствуют требованиям, а язык поддерживается.
 // @Param user body models.User true "Данные пользователя"
 // @Success 200 {string} string "Данные пользователя валидны"
 // @Failure 400 {string} string "Ошибка валидации данных"
 // @Router /user [post]
 func ValidateUserForCreate(user models.User) error {
 	if user.Name == "" || !isValidUsername(user.Name) {
-		return errors.New("Username is empty!")
+		return i18n.NewError("validation.name_invalid")
 	}
 
 	if user.Phone == "" || !isValidPhone(user.Phone) {
-		return errors.New("Phone is empty!")
+		return i18n.NewError("validation.phone_invalid")
 	}
 
 	if user.Password == "" || !isValidPassword(user.Password) {
-		return errors.New("Password cannot be empty!")
+		return i18n.NewError("validation.password_invalid")
 	}
 	if len(user.Password) < 8 {
-		return errors.New("Password is too short!")
+		return i18n.NewError("validation.password_short")
 	}
 
 	if user.Email == "" || !isValidEmail(user.Email) {
-		return errors.New("Email is invalid!")
+		return i18n.NewError("validation.email_invalid")
+	}
+
+	if user.Locale != "" && !i18n.IsSupported(user.Locale) {
+		return i18n.NewError("validation.locale_unsupported", user.Locale)
 	}
 
 	return nil
@@ -48,31 +52,35 @@ func ValidateUserForCreate(user models.User) error {
 func ValidateUserForUpdate(user models.User) error {
 	if user.Name != "" {
 		if !isValidUsername(user.Name) {
-			return errors.New("Name is invalid!")
+			return i18n.NewError("validation.name_invalid")
 		}
 	}
 
 	if user.Phone != "" {
 		if !isValidPhone(user.Phone) {
-			return errors.New("Phone is invalid!")
+			return i18n.NewError("validation.phone_invalid")
 		}
 		if len(user.Phone) != 12 {
-			return errors.New("Phone length is invalid!")
+			return i18n.NewError("validation.phone_length")
 		}
 	}
 
 	if user.Password != "" {
 		if !isValidPassword(user.Password) {
-			return errors.New("Password is invalid!")
+			return i18n.NewError("validation.password_invalid")
 		}
 	}
 
 	if user.Email != "" {
 		if !isValidEmail(user.Email) {
-			return errors.New("Email is invalid!")
+			return i18n.NewError("validation.email_invalid")
 		}
 	}
 
+	if user.Locale != "" && !i18n.IsSupported(user.Locale) {
+		return i18n.NewError("validation.locale_unsupported", user.Locale)
+	}
+
 	return nil
 }
 